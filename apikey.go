@@ -0,0 +1,73 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"music-store/internal/auth"
+	"music-store/internal/tenant"
+	"music-store/utils"
+	"strings"
+)
+
+// runAPIKey implements `music-store apikey create|revoke`
+func runAPIKey(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("usage: apikey create -subject <name> [-roles a,b] [-tenant id] | apikey revoke -id <id> [-tenant id]")
+	}
+
+	flags := flag.NewFlagSet("apikey "+args[0], flag.ExitOnError)
+	subject := flags.String("subject", "", "owner of the key")
+	roles := flags.String("roles", "", "comma separated roles")
+	id := flags.String("id", "", "id of the key to revoke")
+	tenantID := flags.String("tenant", "", "tenant the key is valid for")
+	if err := flags.Parse(args[1:]); err != nil {
+		return err
+	}
+
+	settings, err := loadSettings(nil)
+	if err != nil {
+		return err
+	}
+
+	// Keys are stored in the keyspace of their tenant
+	tenants, err := tenant.Load(settings.Features.TenantsFile)
+	if err != nil {
+		return err
+	}
+	t, ok := tenants.Get(*tenantID)
+	if !ok {
+		return fmt.Errorf("unknown tenant %q, -tenant is required when tenants are configured", *tenantID)
+	}
+	ctx := tenant.WithTenant(context.Background(), t)
+
+	if err := utils.InitRedis(settings.RedisConfig()); err != nil {
+		return err
+	}
+	defer utils.CloseRedis()
+	store := auth.NewAPIKeyStore(utils.GetRedisClient())
+
+	switch args[0] {
+	case "create":
+		if *subject == "" {
+			return fmt.Errorf("-subject is required")
+		}
+		var roleList []string
+		if *roles != "" {
+			roleList = strings.Split(*roles, ",")
+		}
+		plain, key, err := store.Create(ctx, *subject, roleList)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("id:  %s\nkey: %s\n", key.ID, plain)
+		return nil
+	case "revoke":
+		if *id == "" {
+			return fmt.Errorf("-id is required")
+		}
+		return store.Revoke(ctx, *id)
+	default:
+		return fmt.Errorf("unknown apikey command %q", args[0])
+	}
+}
@@ -0,0 +1,28 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"music-store/internal/config"
+	"os"
+)
+
+// runConfig implements `music-store config print`. It takes the same flags
+// as the server and shows the configuration it would run with, secrets
+// redacted. Problems are reported after the configuration.
+func runConfig(args []string) error {
+	if len(args) == 0 || args[0] != "print" {
+		return fmt.Errorf("usage: config print [-format yaml|toml] [-config file] [flags]")
+	}
+
+	flags := flag.NewFlagSet("config print", flag.ContinueOnError)
+	format := flags.String("format", "yaml", "output format: yaml or toml")
+	settings, err := config.Load(flags, args[1:])
+	if err != nil {
+		return err
+	}
+	if err := settings.Print(os.Stdout, *format); err != nil {
+		return err
+	}
+	return settings.Validate()
+}
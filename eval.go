@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"music-store/internal/config"
+	"music-store/internal/repository"
+	"music-store/internal/service/recommender"
+	"music-store/internal/service/recommender/eval"
+	"music-store/utils"
+	"os"
+)
+
+// runEval implements `music-store eval`. The pipeline is tuned from the
+// configuration like the server's. Without -snapshot the users and songs are
+// read from Redis; -save-snapshot freezes them to a file so later runs are
+// reproducible.
+func runEval(args []string) error {
+	flags := flag.NewFlagSet("eval", flag.ExitOnError)
+	k := flags.Int("k", 10, "recommendation cutoff")
+	holdOut := flags.Int("hold-out", 1, "number of most recent likes hidden per user")
+	seed := flags.Int64("seed", 1, "seed for user sampling")
+	maxUsers := flags.Int("max-users", 0, "evaluate at most this many users (0 = all)")
+	weightsFlag := flags.String("weights", "", "weight overrides, e.g. embedding_knn:1,popularity:0.5")
+	snapshotPath := flags.String("snapshot", "", "read users and songs from this snapshot file instead of Redis")
+	saveSnapshot := flags.String("save-snapshot", "", "write the snapshot used for this run to a file")
+	format := flags.String("format", "table", "output format: table or json")
+	tenantID := flags.String("tenant", "", "tenant whose catalog and users are read from Redis")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	var weights recommender.Weights
+	if *weightsFlag != "" {
+		parsed, err := recommender.ParseWeights(*weightsFlag)
+		if err != nil {
+			return err
+		}
+		weights = parsed
+	}
+
+	settings, err := loadSettings(nil)
+	if err != nil {
+		return err
+	}
+	tuning := settings.RecommenderConfig()
+
+	snapshot, err := loadEvalSnapshot(settings, *snapshotPath, *tenantID)
+	if err != nil {
+		return err
+	}
+	if *saveSnapshot != "" {
+		if err := snapshot.Save(*saveSnapshot); err != nil {
+			return err
+		}
+	}
+
+	report, err := eval.Run(context.Background(), recommender.NewDefaultPipeline(tuning.Weights), snapshot, weights, eval.Config{
+		K:           *k,
+		HoldOut:     *holdOut,
+		Seed:        *seed,
+		MaxUsers:    *maxUsers,
+		Recommender: tuning,
+	})
+	if err != nil {
+		return err
+	}
+
+	switch *format {
+	case "json":
+		return report.WriteJSON(os.Stdout)
+	case "table":
+		return report.WriteTable(os.Stdout)
+	default:
+		return fmt.Errorf("unknown format %q", *format)
+	}
+}
+
+func loadEvalSnapshot(settings *config.Config, path, tenantID string) (*eval.Snapshot, error) {
+	if path != "" {
+		return eval.LoadSnapshot(path)
+	}
+
+	if err := utils.InitRedis(settings.RedisConfig()); err != nil {
+		return nil, err
+	}
+	defer utils.CloseRedis()
+
+	storage, err := repository.Open(settings.StorageConfig(), utils.GetRedisClient())
+	if err != nil {
+		return nil, err
+	}
+	defer storage.Close()
+
+	ctx := context.Background()
+	songs, err := storage.Songs.ForTenant(tenantID).GetAllSongs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users, err := storage.Users.ForTenant(tenantID).GetAllUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &eval.Snapshot{Songs: songs.Songs, Users: users.Users}, nil
+}
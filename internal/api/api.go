@@ -0,0 +1,30 @@
+// Package api holds what the layers below the handlers share about the HTTP
+// API: errors that carry their status code and the request values go-base
+// stores in the context.
+package api
+
+import (
+	"context"
+
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+// Error is answered with its status code by the handlers' error encoders
+type Error struct {
+	msg  string
+	code int
+}
+
+func NewError(code int, msg string) error {
+	return Error{msg: msg, code: code}
+}
+
+func (e Error) Error() string   { return e.msg }
+func (e Error) StatusCode() int { return e.code }
+
+// ContextString returns a request value such as the method or the request
+// ID, empty outside a request
+func ContextString(ctx context.Context, key http.ContextKey) string {
+	value, _ := ctx.Value(key).(string)
+	return value
+}
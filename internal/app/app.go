@@ -0,0 +1,251 @@
+// Package app wires the stores, services and controllers of the music store
+// onto a transport. main serves it, the end-to-end tests run it against an
+// in-process Redis.
+package app
+
+import (
+	"log/slog"
+	"music-store/internal/audit"
+	"music-store/internal/auth"
+	"music-store/internal/config"
+	"music-store/internal/controller"
+	"music-store/internal/experiment"
+	"music-store/internal/idempotency"
+	"music-store/internal/logging"
+	"music-store/internal/metrics"
+	"music-store/internal/privacy"
+	"music-store/internal/ratelimit"
+	"music-store/internal/repository"
+	"music-store/internal/revision"
+	"music-store/internal/service"
+	"music-store/internal/service/onboarding"
+	"music-store/internal/service/recommender"
+	"music-store/internal/tenant"
+	"music-store/internal/timeout"
+	"music-store/internal/tracing"
+	net_http "net/http"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+type (
+	// Config gathers the settings of every component
+	Config struct {
+		Auth        *auth.Config
+		RateLimit   *ratelimit.Config
+		Audit       *audit.Config
+		Service     *service.Config
+		Revision    *revision.Config
+		Idempotency *idempotency.Config
+		Storage     *repository.Config
+		Recommender *recommender.Config
+		Tenants     *tenant.Registry
+		Experiments *experiment.Config
+		Timeout     *timeout.Config
+		Metrics     *metrics.Config
+	}
+
+	// App is the running application bound to a transport
+	App struct {
+		storage     *repository.Storage
+		experiments experiment.Service
+	}
+)
+
+// LoadConfig turns the settings into the configuration of every component.
+// Tenants and experiments are read from their files, experiments from
+// Redis when no file is set.
+func LoadConfig(settings *config.Config, redisClient redis.UniversalClient) (*Config, error) {
+	tenants, err := tenant.Load(settings.Features.TenantsFile)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading tenants")
+	}
+	experiments, err := experiment.Load(settings.Features.ExperimentsFile, redisClient)
+	if err != nil {
+		return nil, errors.Wrap(err, "loading experiments")
+	}
+	return &Config{
+		Auth:        settings.AuthConfig(),
+		RateLimit:   settings.RateLimitConfig(),
+		Audit:       settings.AuditConfig(),
+		Service:     settings.ServiceConfig(),
+		Revision:    settings.RevisionConfig(),
+		Idempotency: settings.IdempotencyConfig(),
+		Storage:     settings.StorageConfig(),
+		Recommender: settings.RecommenderConfig(),
+		Tenants:     tenants,
+		Experiments: experiments,
+		Timeout:     settings.TimeoutConfig(),
+		Metrics:     settings.MetricsConfig(),
+	}, nil
+}
+
+// New builds the application and binds its routes to transport
+func New(config *Config, redisClient redis.UniversalClient, transport *http.Transport) (*App, error) {
+	if err := ratelimit.CheckTenants(config.Tenants); err != nil {
+		return nil, errors.Wrap(err, "invalid tenant rate limits")
+	}
+
+	// Commands abandoned with their request are counted, every command is
+	// traced
+	redisClient.AddHook(metrics.NewRedisHook())
+	redisClient.AddHook(tracing.NewRedisHook())
+
+	// Every mutation through the song and user services is audited
+	auditLog := audit.NewLog(config.Audit, redisClient)
+
+	// Route policies are enforced per endpoint, denials are audited and
+	// logged
+	authorizer := auth.NewAuthorizer(audit.NewDenialRecorder(auditLog, auth.NewLogDenialRecorder()))
+	auditController := controller.NewAuditController(auditLog, authorizer)
+
+	experimentSvc := experiment.NewService(config.Experiments, redisClient)
+	experimentController := controller.NewExperimentController(experimentSvc, authorizer)
+
+	// Song updates keep the prior version, up to the revision limit per song
+	revisionStore := revision.NewStore(config.Revision, redisClient)
+
+	// Songs and users live in the storage backend, everything else in Redis
+	storage, err := repository.Open(config.Storage, redisClient)
+	if err != nil {
+		experimentSvc.Close()
+		return nil, errors.Wrap(err, "opening storage")
+	}
+
+	// Repository calls are timed, likes counted, service calls traced
+	userRepo := metrics.NewUserRepository(storage.Users)
+	userSvc := tracing.NewUserService(audit.NewUserService(
+		metrics.NewUserService(experiment.NewLikeTrackingUserService(service.NewUserService(userRepo, config.Service), experimentSvc)),
+		auditLog,
+	))
+
+	credentialRepo := metrics.NewCredentialRepository(repository.NewCredentialRepository(redisClient))
+	refreshTokenRepo := metrics.NewRefreshTokenRepository(repository.NewRefreshTokenRepository(redisClient))
+	apiKeyStore := auth.NewAPIKeyStore(redisClient)
+
+	songRepo := metrics.NewSongRepository(storage.Songs)
+
+	// Exports and erasure cover every store holding user data
+	privacySvc := tracing.NewPrivacyService(privacy.NewService(userRepo, redisClient, auditLog,
+		privacy.NewAccountSource(credentialRepo, refreshTokenRepo),
+		privacy.NewExperimentSource(experimentSvc),
+		privacy.NewAuditSource(auditLog),
+		privacy.NewRevisionSource(revisionStore),
+		privacy.NewAPIKeySource(apiKeyStore),
+		privacy.NewSongTrashSource(songRepo),
+	))
+	userController := controller.NewUserController(userSvc, privacySvc, authorizer)
+
+	songSvc := tracing.NewSongService(audit.NewSongService(
+		revision.NewSongService(service.NewSongService(songRepo, userRepo, config.Service), revisionStore),
+		auditLog,
+	))
+	songController := controller.NewSongController(songSvc, authorizer)
+	revisionController := controller.NewRevisionController(tracing.NewRevisionService(revision.NewService(revisionStore, songSvc)), authorizer)
+	trashController := controller.NewTrashController(songSvc, userSvc, authorizer)
+
+	recommendationSvc := tracing.NewRecommendationService(
+		recommender.NewService(songRepo, userRepo, recommender.NewDefaultPipeline(config.Recommender.Weights), experimentSvc, config.Recommender),
+	)
+	recommendationController := controller.NewRecommendationController(recommendationSvc, authorizer)
+
+	onboardingSvc := tracing.NewOnboardingService(audit.NewOnboardingService(onboarding.NewService(songRepo, userRepo, userSvc), userSvc, auditLog))
+	onboardingController := controller.NewOnboardingController(onboardingSvc, authorizer)
+
+	// Every route requires a bearer token or an API key
+	jwtVerifier, err := auth.NewJWTVerifierFromConfig(config.Auth)
+	if err != nil {
+		experimentSvc.Close()
+		storage.Close()
+		return nil, errors.Wrap(err, "initializing JWT verification")
+	}
+	authenticator := auth.NewAuthenticator(jwtVerifier, apiKeyStore)
+
+	// Clients are throttled by IP before they are identified, which covers
+	// failed authentication, then per route group
+	limiter := ratelimit.NewLimiter(config.RateLimit, redisClient)
+	// Retried writes carrying an Idempotency-Key replay the first response
+	idempotencyStore := idempotency.NewStore(config.Idempotency, redisClient)
+
+	// Storefronts are isolated by key prefix, a single-tenant deployment
+	// keeps unprefixed keys
+	opts := []http.HandlerOption{
+		tracing.NewHandlerOption(),
+		logging.NewHandlerOption(),
+		metrics.NewHandlerOption(),
+		timeout.NewHandlerOption(config.Timeout),
+		tenant.NewHandlerOption(config.Tenants),
+		limiter.NewHandlerOption(ratelimit.FixedGroup(ratelimit.GroupIP)),
+		auth.NewHandlerOption(authenticator, config.Tenants),
+		limiter.NewHandlerOption(ratelimit.DefaultGroup),
+		idempotencyStore.NewHandlerOption(),
+	}
+
+	userController.Bind(transport, opts)
+	songController.Bind(transport, opts)
+	revisionController.Bind(transport, opts)
+	recommendationController.Bind(transport, withFeature(opts, tenant.FeatureRecommendations))
+	experimentController.Bind(transport, withFeature(opts, tenant.FeatureExperiments))
+	onboardingController.Bind(transport, withFeature(opts, tenant.FeatureOnboarding))
+	auditController.Bind(transport, opts)
+	trashController.Bind(transport, opts)
+
+	// Signup and login mint their own tokens, so they need the HS256 secret
+	// and are served without the authentication filter. Their responses are
+	// never stored for replay, a replayed refresh would hand out a rotated
+	// token.
+	if config.Auth.JWTSecret != "" {
+		issuer, err := auth.NewTokenIssuer(config.Auth)
+		if err != nil {
+			experimentSvc.Close()
+			storage.Close()
+			return nil, errors.Wrap(err, "initializing token issuer")
+		}
+		authSvc := tracing.NewAuthService(service.NewAuthService(
+			userSvc,
+			credentialRepo,
+			refreshTokenRepo,
+			issuer,
+		))
+		controller.NewAuthController(authSvc).Bind(transport, []http.HandlerOption{
+			tracing.NewHandlerOption(),
+			logging.NewHandlerOption(),
+			metrics.NewHandlerOption(),
+			timeout.NewHandlerOption(config.Timeout),
+			tenant.NewHandlerOption(config.Tenants),
+			tenant.Required(),
+			tenant.RequireFeature(tenant.FeatureAccounts),
+			limiter.NewHandlerOption(ratelimit.FixedGroup(ratelimit.GroupAuth)),
+		})
+	} else {
+		slog.Warn("AUTH_JWT_SECRET not set, account routes are disabled")
+	}
+
+	// Scrapers are not users, the endpoint goes around every filter
+	if config.Metrics.Enabled {
+		collectors := prometheus.NewRegistry()
+		collectors.MustRegister(
+			metrics.NewPoolCollector(redisClient),
+			metrics.NewDomainCollector(config.Tenants.IDs(), metrics.CountCatalog(storage.Songs, storage.Users), config.Metrics.CatalogRefresh),
+		)
+		transport.Mux().Handler(net_http.MethodGet, config.Metrics.Path, metrics.Handler(collectors))
+	}
+
+	return &App{storage: storage, experiments: experimentSvc}, nil
+}
+
+// Close flushes pending experiment events and releases the storage. The
+// Redis client is closed by its owner.
+func (a *App) Close() error {
+	a.experiments.Close()
+	return a.storage.Close()
+}
+
+// withFeature copies opts and hides the routes from tenants that switched
+// the feature off
+func withFeature(opts []http.HandlerOption, feature string) []http.HandlerOption {
+	return append(append([]http.HandlerOption{}, opts...), tenant.RequireFeature(feature))
+}
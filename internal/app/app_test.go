@@ -0,0 +1,1008 @@
+package app_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"io"
+	"log/slog"
+	"net"
+	net_http "net/http"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"music-store/internal/app"
+	"music-store/internal/audit"
+	"music-store/internal/auth"
+	"music-store/internal/experiment"
+	"music-store/internal/idempotency"
+	"music-store/internal/logging"
+	"music-store/internal/metrics"
+	"music-store/internal/model"
+	"music-store/internal/ratelimit"
+	"music-store/internal/repository"
+	"music-store/internal/revision"
+	"music-store/internal/service"
+	"music-store/internal/service/recommender"
+	"music-store/internal/tenant"
+	"music-store/internal/timeout"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+	"github.com/unbxd/go-base/kit/transport/http"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+const jwtSecret = "e2e-secret"
+
+// server is the full application listening on an ephemeral port
+type server struct {
+	t      *testing.T
+	url    string
+	issuer *auth.TokenIssuer
+	redis  *miniredis.Miniredis
+	client *redis.Client
+	// exchanges are recorded in order and compared with the golden file
+	exchanges []*exchange
+}
+
+// exchange is one request and its response as stored in a golden file
+type exchange struct {
+	Name    string          `json:"name"`
+	Request string          `json:"request"`
+	Status  int             `json:"status"`
+	Body    json.RawMessage `json:"body,omitempty"`
+}
+
+// startServer runs the application, configure may adjust its configuration
+func startServer(t *testing.T, configure ...func(*app.Config)) *server {
+	t.Helper()
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	tenants, err := tenant.Load("")
+	if err != nil {
+		t.Fatalf("loading tenants: %v", err)
+	}
+	authConfig := &auth.Config{JWTSecret: jwtSecret, AccessTokenTTL: time.Hour, RefreshTokenTTL: time.Hour}
+	config := &app.Config{
+		Auth: authConfig,
+		// Limits stay out of the way, the limiter has its own behaviour
+		RateLimit: &ratelimit.Config{Groups: map[string]ratelimit.Limit{
+			ratelimit.GroupWrite: {Requests: 10000, Window: time.Minute},
+		}},
+		Audit:       &audit.Config{Retention: time.Hour},
+		Service:     &service.Config{TrashRetention: time.Hour},
+		Revision:    &revision.Config{MaxRevisions: 5},
+		Idempotency: &idempotency.Config{TTL: time.Hour},
+		Storage:     &repository.Config{Backend: repository.BackendRedis},
+		Recommender: recommender.DefaultConfig(),
+		Tenants:     tenants,
+		Experiments: &experiment.Config{},
+		Timeout:     &timeout.Config{Default: time.Minute},
+		Metrics:     &metrics.Config{Enabled: true, Path: "/metrics", CatalogRefresh: time.Minute},
+	}
+	for _, fn := range configure {
+		fn(config)
+	}
+
+	transport, err := http.NewTransport("127.0.0.1", "0")
+	if err != nil {
+		t.Fatalf("creating transport: %v", err)
+	}
+	application, err := app.New(config, redisClient, transport)
+	if err != nil {
+		t.Fatalf("creating application: %v", err)
+	}
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listening: %v", err)
+	}
+	go transport.Serve(listener)
+	t.Cleanup(func() {
+		transport.Close()
+		application.Close()
+	})
+
+	issuer, err := auth.NewTokenIssuer(authConfig)
+	if err != nil {
+		t.Fatalf("creating token issuer: %v", err)
+	}
+	return &server{t: t, url: "http://" + listener.Addr().String(), issuer: issuer, redis: redisServer, client: redisClient}
+}
+
+// token returns a bearer token for subject with roles
+func (s *server) token(subject string, roles ...string) string {
+	s.t.Helper()
+	token, err := s.issuer.IssueAccessToken(subject, "", roles)
+	if err != nil {
+		s.t.Fatalf("issuing token: %v", err)
+	}
+	return token
+}
+
+// do sends a request with token, empty for none, and records the exchange
+// under name. body is sent as is when it is a string and as JSON otherwise.
+func (s *server) do(name, token, method, path string, body interface{}) *net_http.Response {
+	s.t.Helper()
+
+	var reader io.Reader
+	switch b := body.(type) {
+	case nil:
+	case string:
+		reader = strings.NewReader(b)
+	default:
+		data, err := json.Marshal(b)
+		if err != nil {
+			s.t.Fatalf("%s: marshaling body: %v", name, err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := net_http.NewRequest(method, s.url+path, reader)
+	if err != nil {
+		s.t.Fatalf("%s: %v", name, err)
+	}
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := net_http.DefaultClient.Do(req)
+	if err != nil {
+		s.t.Fatalf("%s: %v", name, err)
+	}
+	defer resp.Body.Close()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		s.t.Fatalf("%s: reading body: %v", name, err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(data))
+
+	e := &exchange{Name: name, Request: method + " " + path, Status: resp.StatusCode}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		if !json.Valid(data) {
+			s.t.Fatalf("%s: invalid JSON body %q", name, data)
+		}
+		e.Body = normalize(data)
+	}
+	s.exchanges = append(s.exchanges, e)
+	return resp
+}
+
+// expect checks the status of the last exchange
+func (s *server) expect(status int) {
+	s.t.Helper()
+	last := s.exchanges[len(s.exchanges)-1]
+	if last.Status != status {
+		s.t.Errorf("%s: %s returned %d, want %d: %s", last.Name, last.Request, last.Status, status, last.Body)
+	}
+}
+
+var (
+	timestamp = regexp.MustCompile(`"\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(\.\d+)?(Z|[+-]\d{2}:\d{2})"`)
+	requestID = regexp.MustCompile(`"request_id":"[0-9a-f-]{36}"`)
+)
+
+// normalize replaces timestamps and generated request IDs and reindents so
+// golden files diff cleanly
+func normalize(data []byte) json.RawMessage {
+	data = timestamp.ReplaceAll(data, []byte(`"<timestamp>"`))
+	data = requestID.ReplaceAll(data, []byte(`"request_id":"<request_id>"`))
+	var out bytes.Buffer
+	json.Indent(&out, bytes.TrimSpace(data), "  ", "  ")
+	return out.Bytes()
+}
+
+// checkGolden compares the recorded exchanges with testdata/<name>.golden,
+// or rewrites the file when running with -update
+func (s *server) checkGolden(name string) {
+	s.t.Helper()
+	got, err := json.MarshalIndent(s.exchanges, "", "  ")
+	if err != nil {
+		s.t.Fatalf("marshaling exchanges: %v", err)
+	}
+	got = append(got, '\n')
+
+	path := filepath.Join("testdata", name+".golden")
+	if *update {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			s.t.Fatalf("writing %s: %v", path, err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		s.t.Fatalf("reading %s, run go test -update to create it: %v", path, err)
+	}
+	if !bytes.Equal(got, want) {
+		s.t.Errorf("responses differ from %s, run go test -update and review the diff\ngot:\n%s", path, got)
+	}
+}
+
+func TestUserRoutes(t *testing.T) {
+	s := startServer(t)
+	admin := s.token("admin-1", auth.RoleAdmin)
+	owner := s.token("u1")
+	other := s.token("u2")
+	readonly := s.token("svc", auth.RoleReadOnly)
+
+	s.do("create without token", "", "POST", "/users", map[string]interface{}{"user": map[string]string{"id": "u1"}})
+	s.expect(401)
+	s.do("create as plain user", other, "POST", "/users", map[string]interface{}{"user": map[string]string{"id": "u1"}})
+	s.expect(403)
+	s.do("create with malformed body", admin, "POST", "/users", `{"user":`)
+	s.expect(400)
+	s.do("create", admin, "POST", "/users", map[string]interface{}{"user": map[string]string{"id": "u1", "name": "One"}})
+	s.expect(200)
+
+	// Profiles hold the negative feedback and the taste embedding
+	s.do("get as other user", other, "GET", "/users/u1", nil)
+	s.expect(403)
+	s.do("get", owner, "GET", "/users/u1", nil)
+	s.expect(200)
+	s.do("get missing", admin, "GET", "/users/missing", nil)
+	s.expect(200)
+	s.do("list as readonly", readonly, "GET", "/users", nil)
+	s.expect(200)
+	s.do("get as readonly", readonly, "GET", "/users/u1", nil)
+	s.expect(403)
+
+	s.do("update as other user", other, "PUT", "/users/u1", map[string]interface{}{"user": map[string]string{"name": "Mallory"}})
+	s.expect(403)
+	s.do("update as owner", owner, "PUT", "/users/u1", map[string]interface{}{"user": map[string]string{"name": "Uno"}})
+	s.expect(200)
+
+	s.do("like", owner, "POST", "/users/u1/like/s1", nil)
+	s.expect(200)
+	s.do("like again", owner, "POST", "/users/u1/like/s1", nil)
+	s.expect(200)
+	s.do("like as other user", other, "POST", "/users/u1/like/s2", nil)
+	s.expect(403)
+	s.do("like for missing user", admin, "POST", "/users/ghost/like/s1", nil)
+	s.expect(200)
+	s.do("liked songs as other user", other, "GET", "/users/u1/liked_songs", nil)
+	s.expect(403)
+	s.do("liked songs", owner, "GET", "/users/u1/liked_songs", nil)
+	s.expect(200)
+	s.do("unlike", owner, "DELETE", "/users/u1/unlike/s1", nil)
+	s.expect(200)
+	s.do("unlike again", owner, "DELETE", "/users/u1/unlike/s1", nil)
+	s.expect(200)
+
+	s.do("dislike", owner, "POST", "/users/u1/dislike/s2", nil)
+	s.expect(200)
+	s.do("dislike again", owner, "POST", "/users/u1/dislike/s2", nil)
+	s.expect(200)
+	s.do("hide artist", owner, "POST", "/users/u1/hide_artist/Ann", nil)
+	s.expect(200)
+	s.do("negative feedback", owner, "GET", "/users/u1/negative_feedback", nil)
+	s.expect(200)
+	s.do("undislike", owner, "DELETE", "/users/u1/undislike/s2", nil)
+	s.expect(200)
+	s.do("unhide artist", owner, "DELETE", "/users/u1/unhide_artist/Ann", nil)
+	s.expect(200)
+	s.do("unhide artist again", owner, "DELETE", "/users/u1/unhide_artist/Ann", nil)
+	s.expect(200)
+
+	resp := s.do("export as other user", other, "GET", "/users/u1/export", nil)
+	s.expect(403)
+	resp = s.do("export", owner, "GET", "/users/u1/export", nil)
+	s.expect(200)
+	checkExport(t, resp)
+
+	s.do("delete as other user", other, "DELETE", "/users/u1", nil)
+	s.expect(403)
+	s.do("delete", owner, "DELETE", "/users/u1", nil)
+	s.expect(200)
+	s.do("get deleted", owner, "GET", "/users/u1", nil)
+	s.expect(200)
+	s.do("restore", owner, "POST", "/users/u1/restore", nil)
+	s.expect(200)
+	s.do("restore again", owner, "POST", "/users/u1/restore", nil)
+	s.expect(404)
+	s.do("get restored", owner, "GET", "/users/u1", nil)
+	s.expect(200)
+
+	// A deletion by an admin is not the owner's to undo
+	s.do("delete as admin", admin, "DELETE", "/users/u1", nil)
+	s.expect(200)
+	s.do("restore after admin delete", owner, "POST", "/users/u1/restore", nil)
+	s.expect(403)
+	s.do("restore as admin", admin, "POST", "/users/u1/restore", nil)
+	s.expect(200)
+
+	s.do("erase with malformed flag", owner, "DELETE", "/users/u1?erase=maybe", nil)
+	s.expect(400)
+	s.do("erase", owner, "DELETE", "/users/u1?erase=true", nil)
+	s.expect(200)
+	s.do("erase again", owner, "DELETE", "/users/u1?erase=true", nil)
+	s.expect(200)
+	s.do("restore erased", owner, "POST", "/users/u1/restore", nil)
+	s.expect(404)
+
+	s.checkGolden("users")
+}
+
+// checkExport looks into the archive, its contents are covered by the
+// privacy package
+func checkExport(t *testing.T, resp *net_http.Response) {
+	t.Helper()
+	if ct := resp.Header.Get("Content-Type"); ct != "application/zip" {
+		t.Fatalf("export Content-Type = %q, want application/zip", ct)
+	}
+	data, _ := io.ReadAll(resp.Body)
+	archive, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		t.Fatalf("export is not a zip archive: %v", err)
+	}
+	var names []string
+	for _, f := range archive.File {
+		names = append(names, f.Name)
+	}
+	sort.Strings(names)
+	if !contains(names, "manifest.json") || !contains(names, "profile.json") {
+		t.Fatalf("export holds %v, want a manifest and a profile", names)
+	}
+}
+
+func TestSongRoutes(t *testing.T) {
+	s := startServer(t)
+	editor := s.token("editor-1", auth.RoleCatalogAdmin)
+	listener := s.token("u1")
+	readonly := s.token("svc", auth.RoleReadOnly)
+
+	song := map[string]interface{}{"song": map[string]interface{}{
+		"name": "s1", "artist": "Ann", "genre": "rock", "embedding": []float64{1, 0}, "release_date": "2024-05-01",
+	}}
+
+	s.do("create without token", "", "POST", "/songs", song)
+	s.expect(401)
+	s.do("create as listener", listener, "POST", "/songs", song)
+	s.expect(403)
+	s.do("create with malformed body", editor, "POST", "/songs", `{"song":`)
+	s.expect(400)
+	s.do("create", editor, "POST", "/songs", song)
+	s.expect(200)
+
+	s.do("get", listener, "GET", "/songs/s1", nil)
+	s.expect(200)
+	s.do("get missing", listener, "GET", "/songs/missing", nil)
+	s.expect(200)
+	s.do("list", listener, "GET", "/songs", nil)
+	s.expect(200)
+	s.do("list as readonly", readonly, "GET", "/songs", nil)
+	s.expect(200)
+
+	s.do("update as listener", listener, "PUT", "/songs/s1", map[string]interface{}{"song": map[string]string{"name": "s1"}})
+	s.expect(403)
+	s.do("update", editor, "PUT", "/songs/s1", map[string]interface{}{"song": map[string]interface{}{
+		"name": "s1", "artist": "Ann", "genre": "jazz", "embedding": []float64{0, 1},
+	}})
+	s.expect(200)
+	s.do("get updated", listener, "GET", "/songs/s1", nil)
+	s.expect(200)
+
+	// Deleting takes the song out of its fans' likes until it is restored
+	admin := s.token("admin-1", auth.RoleAdmin)
+	s.do("create fan", admin, "POST", "/users", map[string]interface{}{"user": map[string]string{"id": "u1"}})
+	s.expect(200)
+	s.do("like", listener, "POST", "/users/u1/like/s1", nil)
+	s.expect(200)
+
+	s.do("delete as listener", listener, "DELETE", "/songs/s1", nil)
+	s.expect(403)
+	s.do("delete", editor, "DELETE", "/songs/s1", nil)
+	s.expect(200)
+	s.do("delete again", editor, "DELETE", "/songs/s1", nil)
+	s.expect(200)
+	s.do("list after delete", listener, "GET", "/songs", nil)
+	s.expect(200)
+	s.do("fan likes after delete", listener, "GET", "/users/u1/liked_songs", nil)
+	s.expect(200)
+
+	s.do("restore as listener", listener, "POST", "/songs/s1/restore", nil)
+	s.expect(403)
+	s.do("restore", editor, "POST", "/songs/s1/restore", nil)
+	s.expect(200)
+	s.do("restore again", editor, "POST", "/songs/s1/restore", nil)
+	s.expect(404)
+	s.do("fan likes after restore", listener, "GET", "/users/u1/liked_songs", nil)
+	s.expect(200)
+
+	s.do("delete before conflict", editor, "DELETE", "/songs/s1", nil)
+	s.expect(200)
+	s.do("recreate", editor, "POST", "/songs", song)
+	s.expect(200)
+	s.do("restore over recreated", editor, "POST", "/songs/s1/restore", nil)
+	s.expect(409)
+
+	// Erasing the fan also drops them from the likes kept in the trash
+	s.do("trash before erasure", admin, "GET", "/admin/trash/songs", nil)
+	s.expect(200)
+	s.do("erase fan", admin, "DELETE", "/users/u1?erase=true", nil)
+	s.expect(200)
+	resp := s.do("trash after erasure", admin, "GET", "/admin/trash/songs", nil)
+	s.expect(200)
+	if data, _ := io.ReadAll(resp.Body); bytes.Contains(data, []byte(`"u1"`)) {
+		t.Errorf("trash still holds the erased user: %s", data)
+	}
+
+	s.checkGolden("songs")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// Onboarding picks are liked like any other like: a pick replaces an earlier
+// dislike and is audited
+func TestOnboardingLikes(t *testing.T) {
+	s := startServer(t)
+	admin := s.token("admin-1", auth.RoleAdmin)
+	owner := s.token("u1")
+
+	s.do("create user", admin, "POST", "/users", map[string]interface{}{"user": map[string]string{"id": "u1"}})
+	s.expect(200)
+	s.do("create song", s.token("editor-1", auth.RoleCatalogAdmin), "POST", "/songs", map[string]interface{}{"song": map[string]interface{}{
+		"name": "s1", "artist": "Ann", "genre": "jazz", "embedding": []float64{1, 0},
+	}})
+	s.expect(200)
+	s.do("dislike", owner, "POST", "/users/u1/dislike/s1", nil)
+	s.expect(200)
+	s.do("onboard", owner, "POST", "/users/u1/onboarding", map[string]interface{}{"song_names": []string{"s1"}, "genres": []string{"jazz"}})
+	s.expect(200)
+
+	resp := s.do("get", owner, "GET", "/users/u1", nil)
+	s.expect(200)
+	var user model.GetUserResponse
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		t.Fatalf("decoding user: %v", err)
+	}
+	if !reflect.DeepEqual(user.User.LikedSongs, []string{"s1"}) || len(user.User.DislikedSongs) != 0 ||
+		!reflect.DeepEqual(user.User.Genres, []string{"jazz"}) || len(user.User.Embedding) != 2 {
+		t.Fatalf("onboarded user = %+v", user.User)
+	}
+
+	resp = s.do("audit", admin, "GET", "/admin/audit?actor=u1", nil)
+	s.expect(200)
+	var log model.GetAuditLogResponse
+	if err := json.NewDecoder(resp.Body).Decode(&log); err != nil {
+		t.Fatalf("decoding audit log: %v", err)
+	}
+	if !hasAction(log.Records, "user.like") || !hasAction(log.Records, "user.onboard") {
+		t.Fatalf("onboarding is not audited: %+v", log.Records)
+	}
+}
+
+// Users created by signing up and users erased are audited like the users
+// admins create and delete
+func TestAccountMutationsAreAudited(t *testing.T) {
+	s := startServer(t)
+	admin := s.token("admin-1", auth.RoleAdmin)
+
+	resp := s.do("signup", "", "POST", "/auth/signup", map[string]string{"username": "ann", "password": "correct horse battery"})
+	s.expect(200)
+	var signup model.SignupResponse
+	if err := json.NewDecoder(resp.Body).Decode(&signup); err != nil {
+		t.Fatalf("decoding signup: %v", err)
+	}
+	target := "user:" + signup.UserID
+
+	auditOf := func() []*model.AuditRecord {
+		resp := s.do("audit", admin, "GET", "/admin/audit?target="+target, nil)
+		s.expect(200)
+		var log model.GetAuditLogResponse
+		if err := json.NewDecoder(resp.Body).Decode(&log); err != nil {
+			t.Fatalf("decoding audit log: %v", err)
+		}
+		return log.Records
+	}
+	if records := auditOf(); len(records) != 1 || records[0].Action != "user.create" || records[0].Actor != signup.UserID {
+		t.Fatalf("signup records = %+v, want the user creating itself", records)
+	}
+
+	s.do("erase", signup.Tokens.AccessToken, "DELETE", "/users/"+signup.UserID+"?erase=true", nil)
+	s.expect(200)
+	// The user's own records are forgotten, the erasure is kept
+	if records := auditOf(); len(records) != 1 || records[0].Action != "user.erase" || len(records[0].Changes) != 0 {
+		t.Fatalf("erasure records = %+v, want only the erasure", records)
+	}
+}
+
+func hasAction(records []*model.AuditRecord, action string) bool {
+	for _, record := range records {
+		if record.Action == action {
+			return true
+		}
+	}
+	return false
+}
+
+// Account responses carry tokens, so they are never stored for replay
+func TestAccountRoutesAreNotReplayed(t *testing.T) {
+	s := startServer(t)
+
+	post := func(path string, body interface{}) *net_http.Response {
+		data, _ := json.Marshal(body)
+		req, _ := net_http.NewRequest("POST", s.url+path, bytes.NewReader(data))
+		req.Header.Set(idempotency.Header, "k1")
+		resp, err := net_http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	credentials := map[string]string{"username": "ann", "password": "correct horse battery"}
+	if resp := post("/auth/signup", credentials); resp.StatusCode != 200 {
+		t.Fatalf("signup returned %d", resp.StatusCode)
+	}
+	for i := 0; i < 2; i++ {
+		resp := post("/auth/login", credentials)
+		if resp.StatusCode != 200 || resp.Header.Get("Idempotent-Replayed") != "" {
+			t.Fatalf("login %d returned %d, replayed %q", i, resp.StatusCode, resp.Header.Get("Idempotent-Replayed"))
+		}
+	}
+	for _, key := range s.redis.Keys() {
+		if strings.HasPrefix(key, "idempotency:") {
+			t.Fatalf("account response stored under %s", key)
+		}
+	}
+}
+
+// Bodies are buffered to fingerprint a keyed request, up to a limit
+func TestIdempotentBodyLimit(t *testing.T) {
+	s := startServer(t)
+
+	body := `{"user":{"id":"u1","name":"` + strings.Repeat("x", 2<<20) + `"}}`
+	req, _ := net_http.NewRequest("POST", s.url+"/users", strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+s.token("admin-1", auth.RoleAdmin))
+	req.Header.Set(idempotency.Header, "k1")
+	resp, err := net_http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 413 {
+		t.Fatalf("oversized body returned %d, want 413", resp.StatusCode)
+	}
+}
+
+// Denied requests land in the audit log beside the mutations
+func TestDenialsAreAudited(t *testing.T) {
+	s := startServer(t)
+	admin := s.token("admin-1", auth.RoleAdmin)
+
+	s.do("update as other user", s.token("u2"), "PUT", "/users/u1", map[string]interface{}{"user": map[string]string{"name": "Mallory"}})
+	s.expect(403)
+	resp := s.do("audit", admin, "GET", "/admin/audit?actor=u2", nil)
+	s.expect(200)
+
+	var log model.GetAuditLogResponse
+	if err := json.NewDecoder(resp.Body).Decode(&log); err != nil {
+		t.Fatalf("decoding audit log: %v", err)
+	}
+	if len(log.Records) != 1 {
+		t.Fatalf("audit log holds %d records of u2, want the denial", len(log.Records))
+	}
+	record := log.Records[0]
+	if record.Action != "users.update.denied" || record.Target != "/users/u1" || record.RequestID == "" || record.ClientIP == "" {
+		t.Fatalf("denial record = %+v", record)
+	}
+}
+
+// X-Forwarded-For names the client only behind a trusted proxy, otherwise
+// the audit log keeps the address the request came from
+func TestAuditClientIP(t *testing.T) {
+	for _, trustProxy := range []bool{false, true} {
+		s := startServer(t, func(config *app.Config) {
+			config.Audit.TrustProxy = trustProxy
+		})
+
+		req, _ := net_http.NewRequest("DELETE", s.url+"/users/u1", nil)
+		req.Header.Set("Authorization", "Bearer "+s.token("u2"))
+		req.Header.Set("X-Forwarded-For", "203.0.113.7, 10.0.0.1")
+		resp, err := net_http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != 403 {
+			t.Fatalf("delete as other user returned %d, want 403", resp.StatusCode)
+		}
+
+		resp = s.do("audit", s.token("admin-1", auth.RoleAdmin), "GET", "/admin/audit?actor=u2", nil)
+		s.expect(200)
+		var log model.GetAuditLogResponse
+		if err := json.NewDecoder(resp.Body).Decode(&log); err != nil {
+			t.Fatalf("decoding audit log: %v", err)
+		}
+		want := "127.0.0.1"
+		if trustProxy {
+			want = "203.0.113.7"
+		}
+		if len(log.Records) != 1 || log.Records[0].ClientIP != want {
+			t.Fatalf("with trust proxy %t, audit records = %+v, want client IP %s", trustProxy, log.Records, want)
+		}
+	}
+}
+
+// Credentials that cannot be checked are a server error, not a reason to
+// tell the client its key is wrong
+func TestAuthenticationBackendDown(t *testing.T) {
+	s := startServer(t)
+
+	req, _ := net_http.NewRequest("GET", s.url+"/songs", nil)
+	req.Header.Set("X-API-Key", "ms_unknown")
+	resp, err := net_http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 401 || resp.Header.Get("WWW-Authenticate") == "" {
+		t.Fatalf("unknown key returned %d with WWW-Authenticate %q, want a 401 challenge", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+
+	s.redis.Close()
+	resp, err = net_http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request: %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if resp.StatusCode != 500 || resp.Header.Get("WWW-Authenticate") != "" {
+		t.Fatalf("key lookup without Redis returned %d with WWW-Authenticate %q, want 500 and no challenge", resp.StatusCode, resp.Header.Get("WWW-Authenticate"))
+	}
+	// The Redis error stays in the logs
+	if got := strings.TrimSpace(string(body)); got != `{"error":"internal error"}` {
+		t.Errorf("body = %s, want a generic error", got)
+	}
+}
+
+// The default tenant only applies to requests whose credentials name none
+func TestDefaultTenantDefersToToken(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tenants.json")
+	if err := os.WriteFile(path, []byte(`{"tenants":[{"id":"a"},{"id":"b"}],"default":"a"}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	tenants, err := tenant.LoadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := startServer(t, func(config *app.Config) { config.Tenants = tenants })
+
+	tokenFor := func(tenantID string) string {
+		token, err := s.issuer.IssueAccessToken("u1", tenantID, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+	tests := []struct {
+		name, token, header string
+		want                int
+	}{
+		{name: "token of the default tenant", token: tokenFor("a"), want: 200},
+		{name: "token of another tenant", token: tokenFor("b"), want: 200},
+		{name: "token of another tenant, naming the default", token: tokenFor("b"), header: "a", want: 403},
+		{name: "token without a tenant", token: tokenFor(""), want: 403},
+	}
+	for _, test := range tests {
+		req, _ := net_http.NewRequest("GET", s.url+"/songs", nil)
+		req.Header.Set("Authorization", "Bearer "+test.token)
+		if test.header != "" {
+			req.Header.Set(tenant.HeaderName, test.header)
+		}
+		resp, err := net_http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("%s: %v", test.name, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != test.want {
+			t.Errorf("%s: status %d, want %d", test.name, resp.StatusCode, test.want)
+		}
+	}
+}
+
+// Clients guessing credentials are throttled by IP, since they have no
+// identity to be limited by
+func TestFailedAuthenticationIsLimited(t *testing.T) {
+	s := startServer(t, func(config *app.Config) {
+		config.RateLimit.Groups[ratelimit.GroupIP] = ratelimit.Limit{Requests: 2, Window: time.Minute}
+	})
+
+	for i := 0; i < 2; i++ {
+		s.do("bad token", "not-a-token", "GET", "/songs", nil)
+		s.expect(401)
+	}
+	s.do("bad token over the limit", "not-a-token", "GET", "/songs", nil)
+	s.expect(429)
+}
+
+// Weights from the query are rejected before they reach the ranking
+func TestRecommendationWeights(t *testing.T) {
+	s := startServer(t)
+	token := s.token("u1")
+
+	for _, weights := range []string{"popularity:NaN", "popularity:Inf", "popularity:-1", "popularity"} {
+		s.do(weights, token, "GET", "/users/u1/recommendations?weights="+weights, nil)
+		s.expect(400)
+	}
+	s.do("valid", token, "GET", "/users/u1/recommendations?weights=popularity:0.5", nil)
+	s.expect(200)
+}
+
+// A route past its deadline answers 504 and abandons its Redis work, the
+// other routes keep their own timeout
+func TestRouteTimeout(t *testing.T) {
+	s := startServer(t, func(config *app.Config) {
+		config.Timeout.Routes = map[string]time.Duration{timeout.Route("GET", "/songs"): time.Nanosecond}
+	})
+	token := s.token("u1")
+
+	s.do("list past the deadline", token, "GET", "/songs", nil)
+	s.expect(504)
+	s.do("get within the default", token, "GET", "/users/u1/liked_songs", nil)
+	s.expect(200)
+}
+
+// A keyed write that timed out is not stored, the retry runs it again
+func TestIdempotentTimeout(t *testing.T) {
+	s := startServer(t, func(config *app.Config) {
+		config.Timeout.Routes = map[string]time.Duration{timeout.Route("POST", "/users"): 50 * time.Millisecond}
+	})
+	// The first write of the user outlives the deadline, after the key was
+	// taken
+	s.client.AddHook(&slowHook{key: "user:u1", delay: 100 * time.Millisecond})
+
+	create := func() *net_http.Response {
+		req, _ := net_http.NewRequest("POST", s.url+"/users", strings.NewReader(`{"user":{"id":"u1"}}`))
+		req.Header.Set("Authorization", "Bearer "+s.token("admin-1", auth.RoleAdmin))
+		req.Header.Set(idempotency.Header, "k1")
+		resp, err := net_http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("request: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	if resp := create(); resp.StatusCode != 504 {
+		t.Fatalf("create past the deadline returned %d, want 504", resp.StatusCode)
+	}
+	resp := create()
+	if resp.StatusCode != 200 || resp.Header.Get("Idempotent-Replayed") != "" {
+		t.Fatalf("retry returned %d, replayed %q, want it served again", resp.StatusCode, resp.Header.Get("Idempotent-Replayed"))
+	}
+	if !s.redis.Exists("user:u1") {
+		t.Fatal("retry did not create the user")
+	}
+}
+
+// slowHook delays the first command on key
+type slowHook struct {
+	key   string
+	delay time.Duration
+	once  sync.Once
+}
+
+func (h *slowHook) DialHook(next redis.DialHook) redis.DialHook { return next }
+
+func (h *slowHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		if args := cmd.Args(); len(args) > 1 && args[1] == h.key {
+			h.once.Do(func() { time.Sleep(h.delay) })
+		}
+		return next(ctx, cmd)
+	}
+}
+
+func (h *slowHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return next
+}
+
+func TestMetrics(t *testing.T) {
+	s := startServer(t)
+	editor := s.token("editor-1", auth.RoleCatalogAdmin)
+	listener := s.token("u1")
+
+	s.do("create", editor, "POST", "/songs", map[string]interface{}{"song": map[string]interface{}{"name": "s1", "embedding": []float64{1, 0}}})
+	s.expect(200)
+	s.do("create", editor, "POST", "/songs", map[string]interface{}{"song": map[string]interface{}{"name": "s2"}})
+	s.expect(200)
+	s.do("list without token", "", "GET", "/songs", nil)
+	s.expect(401)
+	s.do("list", listener, "GET", "/songs", nil)
+	s.expect(200)
+
+	resp := s.do("scrape without token", "", "GET", "/metrics", nil)
+	s.expect(200)
+	body, _ := io.ReadAll(resp.Body)
+	for _, series := range []string{
+		`music_store_http_requests_total{method="GET",route="/songs",status="401"}`,
+		`music_store_http_request_duration_seconds_count{method="POST",route="/songs"}`,
+		`music_store_repository_duration_seconds_count{method="GetAllSongs",repository="songs"}`,
+		`music_store_redis_pool_connections `,
+		`music_store_songs{tenant=""} 2`,
+		`music_store_vector_index_size{tenant=""} 1`,
+		`music_store_song_likes_per_minute `,
+	} {
+		if !strings.Contains(string(body), series) {
+			t.Errorf("scrape lacks %s", series)
+		}
+	}
+}
+
+// A request carrying a traceparent continues the caller's trace through the
+// services, the recommender stages and the Redis commands
+func TestTracing(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	s := startServer(t)
+	admin := s.token("admin-1", auth.RoleAdmin)
+	editor := s.token("editor-1", auth.RoleCatalogAdmin)
+	s.do("create user", admin, "POST", "/users", map[string]interface{}{"user": map[string]string{"id": "u1"}})
+	s.expect(200)
+	s.do("create song", editor, "POST", "/songs", map[string]interface{}{"song": map[string]interface{}{"name": "s1", "embedding": []float64{1, 0}}})
+	s.expect(200)
+
+	const traceID, parentID = "4bf92f3577b34da6a3ce929d0e0e4736", "00f067aa0ba902b7"
+	req, _ := net_http.NewRequest("GET", s.url+"/users/u1/recommendations", nil)
+	req.Header.Set("Authorization", "Bearer "+s.token("u1"))
+	req.Header.Set("traceparent", "00-"+traceID+"-"+parentID+"-01")
+	resp, err := net_http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("recommendations: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Fatalf("recommendations returned %d", resp.StatusCode)
+	}
+
+	// The server span ends after the response went out
+	const root = "GET /users/:id/recommendations"
+	spans := map[string]sdktrace.ReadOnlySpan{}
+	for deadline := time.Now().Add(time.Second); spans[root] == nil && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		for _, span := range recorder.Ended() {
+			if span.SpanContext().TraceID().String() == traceID {
+				spans[span.Name()] = span
+			}
+		}
+	}
+
+	if span := spans[root]; span == nil {
+		t.Fatalf("no span %q in trace, got %v", root, spans)
+	} else if got := span.Parent().SpanID().String(); got != parentID {
+		t.Errorf("request span has parent %s, want %s", got, parentID)
+	}
+	for _, name := range []string{
+		"RecommendationService.Recommend",
+		"recommender.fetch_user",
+		"recommender.load_corpus",
+		"recommender.candidates",
+		"recommender.source " + recommender.SourceEmbeddingKNN,
+		"recommender.rank",
+		"redis get",
+	} {
+		if spans[name] == nil {
+			t.Errorf("no span %q in trace", name)
+		}
+	}
+}
+
+// syncBuffer is written by the server while the test reads it
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// records returns the JSON log lines carrying requestID
+func (b *syncBuffer) records(t *testing.T, requestID string) []map[string]interface{} {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	var records []map[string]interface{}
+	for _, line := range bytes.Split(b.buf.Bytes(), []byte("\n")) {
+		if len(line) == 0 {
+			continue
+		}
+		var record map[string]interface{}
+		if err := json.Unmarshal(line, &record); err != nil {
+			t.Fatalf("log line %q is not JSON: %v", line, err)
+		}
+		if record["request_id"] == requestID {
+			records = append(records, record)
+		}
+	}
+	return records
+}
+
+// Requests keep the ID they are sent with, or get one, and every line
+// logged for them carries it
+func TestRequestLogging(t *testing.T) {
+	logs := &syncBuffer{}
+	previous := slog.Default()
+	slog.SetDefault(logging.NewLogger(logs, &logging.Config{Level: slog.LevelDebug, Format: logging.FormatJSON}))
+	t.Cleanup(func() { slog.SetDefault(previous) })
+
+	s := startServer(t)
+	send := func(requestID string) *net_http.Response {
+		req, _ := net_http.NewRequest("GET", s.url+"/songs", nil)
+		req.Header.Set("Authorization", "Bearer "+s.token("u1"))
+		if requestID != "" {
+			req.Header.Set(logging.HeaderRequestID, requestID)
+		}
+		resp, err := net_http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatalf("list: %v", err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+
+	if got := send("req-123").Header.Get(logging.HeaderRequestID); got != "req-123" {
+		t.Errorf("response carries request ID %q, want req-123", got)
+	}
+	if got := send("forged id").Header.Get(logging.HeaderRequestID); got == "" || strings.Contains(got, " ") {
+		t.Errorf("unusable request ID was answered with %q", got)
+	}
+
+	// The access line is written after the response went out
+	var access, repository map[string]interface{}
+	for deadline := time.Now().Add(time.Second); access == nil && time.Now().Before(deadline); {
+		time.Sleep(10 * time.Millisecond)
+		for _, record := range logs.records(t, "req-123") {
+			switch record["msg"] {
+			case "request":
+				access = record
+			case "repository call":
+				repository = record
+			}
+		}
+	}
+	if access == nil {
+		t.Fatal("no access log line for req-123")
+	}
+	for key, want := range map[string]interface{}{
+		"level": "INFO", "method": "GET", "route": "/songs", "status": float64(200), "principal": "u1",
+	} {
+		if access[key] != want {
+			t.Errorf("access log %s = %v, want %v", key, access[key], want)
+		}
+	}
+	if _, ok := access["latency_ms"].(float64); !ok {
+		t.Errorf("access log lacks the latency: %v", access)
+	}
+	if repository == nil || repository["method"] != "GetAllSongs" {
+		t.Errorf("repository log line of the request = %v", repository)
+	}
+}
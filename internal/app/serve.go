@@ -0,0 +1,48 @@
+package app
+
+import (
+	"context"
+	"log/slog"
+	"net"
+	net_http "net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+// ErrDrainTimeout means requests were still running at the shutdown
+// deadline and their connections were closed
+var ErrDrainTimeout = errors.New("in-flight requests did not finish before the shutdown deadline")
+
+// Serve accepts connections on listener until ctx is done. It then stops
+// accepting, closes idle connections and waits up to timeout for the
+// requests in flight.
+func Serve(ctx context.Context, transport *http.Transport, listener net.Listener, timeout time.Duration) error {
+	served := make(chan error, 1)
+	go func() { served <- transport.Serve(listener) }()
+
+	select {
+	case err := <-served:
+		return errors.Wrap(err, "serving HTTP")
+	case <-ctx.Done():
+	}
+
+	slog.Info("shutting down, draining in-flight requests", "timeout", timeout.String())
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if err := transport.Shutdown(shutdownCtx); err != nil {
+		// Transport.Close shuts down gracefully, the server's Close does not
+		transport.Server.Close()
+		if err == context.DeadlineExceeded {
+			return ErrDrainTimeout
+		}
+		return errors.Wrap(err, "shutting down HTTP")
+	}
+	if err := <-served; err != nil && err != net_http.ErrServerClosed {
+		return errors.Wrap(err, "serving HTTP")
+	}
+	slog.Info("HTTP server drained")
+	return nil
+}
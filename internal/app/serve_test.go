@@ -0,0 +1,75 @@
+package app_test
+
+import (
+	"context"
+	"io"
+	"net"
+	net_http "net/http"
+	"testing"
+	"time"
+
+	"music-store/internal/app"
+
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+// startSlow serves a handler that answers after delay and returns once a
+// request is in flight
+func startSlow(t *testing.T, delay, timeout time.Duration) (cancel func(), response <-chan error, served <-chan error) {
+	t.Helper()
+	transport, err := http.NewTransport("127.0.0.1", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started := make(chan struct{})
+	transport.Handler = net_http.HandlerFunc(func(w net_http.ResponseWriter, r *net_http.Request) {
+		close(started)
+		time.Sleep(delay)
+		io.WriteString(w, "done")
+	})
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	serveResult := make(chan error, 1)
+	go func() { serveResult <- app.Serve(ctx, transport, listener, timeout) }()
+
+	url := "http://" + listener.Addr().String()
+	responseResult := make(chan error, 1)
+	go func() {
+		resp, err := net_http.Get(url)
+		if err == nil {
+			_, err = io.ReadAll(resp.Body)
+			resp.Body.Close()
+		}
+		responseResult <- err
+	}()
+	<-started
+	return cancel, responseResult, serveResult
+}
+
+func TestServeDrainsInFlightRequests(t *testing.T) {
+	cancel, response, served := startSlow(t, 200*time.Millisecond, 5*time.Second)
+	cancel()
+
+	if err := <-response; err != nil {
+		t.Errorf("in-flight request failed: %v", err)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("Serve = %v, want a clean drain", err)
+	}
+}
+
+func TestServeCutsOffAtDeadline(t *testing.T) {
+	cancel, response, served := startSlow(t, 2*time.Second, 100*time.Millisecond)
+	cancel()
+
+	if err := <-served; err != app.ErrDrainTimeout {
+		t.Errorf("Serve = %v, want ErrDrainTimeout", err)
+	}
+	if err := <-response; err == nil {
+		t.Error("request outliving the deadline completed")
+	}
+}
@@ -0,0 +1,29 @@
+package audit
+
+import (
+	"context"
+	"log/slog"
+	"music-store/internal/auth"
+)
+
+// denialRecorder appends rejected requests to the audit log, so they can be
+// queried beside the mutations
+type denialRecorder struct {
+	log  Log
+	next auth.DenialRecorder
+}
+
+// NewDenialRecorder records denials as "<action>.denied" with the request
+// path as target, then hands them to next
+func NewDenialRecorder(auditLog Log, next auth.DenialRecorder) auth.DenialRecorder {
+	return &denialRecorder{log: auditLog, next: next}
+}
+
+func (r *denialRecorder) RecordDenial(ctx context.Context, denial *auth.Denial) {
+	record := newRecord(ctx, denial.Action+".denied", denial.Path)
+	record.Timestamp = denial.Timestamp
+	if err := r.log.Append(ctx, record); err != nil {
+		slog.ErrorContext(ctx, "audit append failed", "action", record.Action, "target", record.Target, "error", err)
+	}
+	r.next.RecordDenial(ctx, denial)
+}
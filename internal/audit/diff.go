@@ -0,0 +1,57 @@
+package audit
+
+import (
+	"bytes"
+	"encoding/json"
+	"music-store/internal/model"
+	"sort"
+)
+
+// Diff compares the top level JSON fields of two values. A nil before or
+// after, as on create and delete, reports every field of the other side.
+func Diff(before, after interface{}) ([]*model.FieldChange, error) {
+	beforeFields, err := fields(before)
+	if err != nil {
+		return nil, err
+	}
+	afterFields, err := fields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(beforeFields)+len(afterFields))
+	for name := range beforeFields {
+		names = append(names, name)
+	}
+	for name := range afterFields {
+		if _, ok := beforeFields[name]; !ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var changes []*model.FieldChange
+	for _, name := range names {
+		b, a := beforeFields[name], afterFields[name]
+		if bytes.Equal(b, a) {
+			continue
+		}
+		changes = append(changes, &model.FieldChange{Field: name, Before: b, After: a})
+	}
+	return changes, nil
+}
+
+func fields(v interface{}) (map[string]json.RawMessage, error) {
+	if v == nil {
+		return nil, nil
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var m map[string]json.RawMessage
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
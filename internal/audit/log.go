@@ -0,0 +1,208 @@
+// Package audit records every mutation made through the services in a Redis
+// stream, together with the caller and the fields that changed.
+package audit
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"music-store/internal/api"
+	"music-store/internal/auth"
+	"music-store/internal/model"
+	"music-store/internal/tenant"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+const (
+	streamKey    = "audit:log" // One stream per tenant
+	recordField  = "record"
+	pageSize     = 500
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+type (
+	// Config holds the audit log settings
+	Config struct {
+		Retention  time.Duration // Entries older than this are trimmed on write
+		TrustProxy bool          // Record the client of X-Forwarded-For
+	}
+
+	Log interface {
+		Append(ctx context.Context, record *model.AuditRecord) error
+		// Query returns matching records newest first. A negative limit
+		// returns every match.
+		Query(ctx context.Context, req *model.GetAuditLogRequest) (*model.GetAuditLogResponse, error)
+		// Forget deletes every record made by actor or about target
+		Forget(ctx context.Context, actor, target string) (int, error)
+	}
+
+	redisLog struct {
+		redisClient redis.UniversalClient
+		retention   time.Duration
+		trustProxy  bool
+	}
+)
+
+func NewLog(config *Config, redisClient redis.UniversalClient) Log {
+	return &redisLog{redisClient: redisClient, retention: config.Retention, trustProxy: config.TrustProxy}
+}
+
+func (l *redisLog) Append(ctx context.Context, record *model.AuditRecord) error {
+	if record.ClientIP == "" {
+		record.ClientIP = clientIP(ctx, l.trustProxy)
+	}
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	args := &redis.XAddArgs{Stream: tenant.Key(ctx, streamKey), Values: []interface{}{recordField, data}}
+	if l.retention > 0 {
+		// Stream IDs start with the millisecond timestamp, so trimming by
+		// ID trims by age
+		args.MinID = strconv.FormatInt(time.Now().Add(-l.retention).UnixMilli(), 10)
+		args.Approx = true
+	}
+
+	id, err := l.redisClient.XAdd(ctx, args).Result()
+	if err != nil {
+		return err
+	}
+	record.ID = id
+	return nil
+}
+
+func (l *redisLog) Query(ctx context.Context, req *model.GetAuditLogRequest) (*model.GetAuditLogResponse, error) {
+	limit := req.Limit
+	switch {
+	case limit < 0:
+		limit = math.MaxInt // Every match, for exports
+	case limit == 0:
+		limit = defaultLimit
+	case limit > maxLimit:
+		limit = maxLimit
+	}
+
+	start := "-"
+	if !req.Since.IsZero() {
+		start = strconv.FormatInt(req.Since.UnixMilli(), 10)
+	}
+
+	records := []*model.AuditRecord{}
+	end := "+"
+	for len(records) < limit {
+		messages, err := l.redisClient.XRevRangeN(ctx, tenant.Key(ctx, streamKey), end, start, pageSize).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, message := range messages {
+			record, err := decodeRecord(message)
+			if err != nil {
+				continue // Skip malformed entries
+			}
+			if req.Target != "" && record.Target != req.Target {
+				continue
+			}
+			if req.Actor != "" && record.Actor != req.Actor {
+				continue
+			}
+			records = append(records, record)
+			if len(records) == limit {
+				break
+			}
+		}
+
+		if len(messages) < pageSize {
+			break
+		}
+		end = "(" + messages[len(messages)-1].ID
+	}
+
+	return &model.GetAuditLogResponse{Records: records}, nil
+}
+
+func (l *redisLog) Forget(ctx context.Context, actor, target string) (int, error) {
+	removed := 0
+	start := "-"
+	for {
+		messages, err := l.redisClient.XRangeN(ctx, tenant.Key(ctx, streamKey), start, "+", pageSize).Result()
+		if err != nil {
+			return removed, err
+		}
+
+		var ids []string
+		for _, message := range messages {
+			record, err := decodeRecord(message)
+			if err != nil {
+				continue
+			}
+			if (actor != "" && record.Actor == actor) || (target != "" && record.Target == target) {
+				ids = append(ids, message.ID)
+			}
+		}
+		if len(ids) > 0 {
+			n, err := l.redisClient.XDel(ctx, tenant.Key(ctx, streamKey), ids...).Result()
+			if err != nil {
+				return removed, err
+			}
+			removed += int(n)
+		}
+
+		if len(messages) < pageSize {
+			return removed, nil
+		}
+		start = "(" + messages[len(messages)-1].ID
+	}
+}
+
+func decodeRecord(message redis.XMessage) (*model.AuditRecord, error) {
+	data, _ := message.Values[recordField].(string)
+	var record model.AuditRecord
+	if err := json.Unmarshal([]byte(data), &record); err != nil {
+		return nil, err
+	}
+	record.ID = message.ID
+	return &record, nil
+}
+
+// newRecord fills in the caller of the request in ctx, the log adds its
+// address
+func newRecord(ctx context.Context, action, target string) *model.AuditRecord {
+	record := &model.AuditRecord{
+		Action:    action,
+		Target:    target,
+		Timestamp: time.Now().UTC(),
+		RequestID: api.ContextString(ctx, http.ContextKeyRequestXRequestID),
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		record.Actor = principal.Subject
+		record.ActorKind = principal.Kind
+		record.KeyID = principal.KeyID
+	}
+	return record
+}
+
+// clientIP only believes X-Forwarded-For behind a proxy, otherwise any
+// client could write its own address into the log
+func clientIP(ctx context.Context, trustProxy bool) string {
+	if trustProxy {
+		if forwarded := api.ContextString(ctx, http.ContextKeyRequestXForwardedFor); forwarded != "" {
+			first, _, _ := strings.Cut(forwarded, ",")
+			return strings.TrimSpace(first)
+		}
+	}
+	remoteAddr := api.ContextString(ctx, http.ContextKeyRequestRemoteAddr)
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
@@ -0,0 +1,245 @@
+package audit
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"music-store/internal/model"
+	"music-store/internal/service"
+	"music-store/internal/service/onboarding"
+)
+
+// recorder appends records for the service decorators
+type recorder struct {
+	log Log
+}
+
+func (r recorder) record(ctx context.Context, action, target string, before, after interface{}) {
+	Record(ctx, r.log, action, target, before, after)
+}
+
+// Record appends a mutation made outside the decorated services, with the
+// fields that changed between before and after. The mutation has already
+// happened when it runs, so failures are only logged.
+func Record(ctx context.Context, auditLog Log, action, target string, before, after interface{}) {
+	record := newRecord(ctx, action, target)
+
+	changes, err := Diff(before, after)
+	if err != nil {
+		slog.ErrorContext(ctx, "audit diff failed", "action", action, "target", target, "error", err)
+	}
+	record.Changes = changes
+
+	if err := auditLog.Append(ctx, record); err != nil {
+		slog.ErrorContext(ctx, "audit append failed", "action", action, "target", target, "error", err)
+	}
+}
+
+// auditedSongService records song mutations
+type auditedSongService struct {
+	service.SongService
+	recorder
+}
+
+func NewSongService(next service.SongService, auditLog Log) service.SongService {
+	return &auditedSongService{SongService: next, recorder: recorder{log: auditLog}}
+}
+
+func (s *auditedSongService) CreateSong(ctx context.Context, req *model.CreateSongRequest) (string, error) {
+	msg, err := s.SongService.CreateSong(ctx, req)
+	if err == nil && msg == "success" {
+		s.record(ctx, "song.create", songKey(req.Song.Name), nil, s.song(ctx, req.Song.Name))
+	}
+	return msg, err
+}
+
+func (s *auditedSongService) UpdateSong(ctx context.Context, req *model.UpdateSongRequest) (string, error) {
+	name := req.Name
+	if name == "" {
+		name = req.Song.Name
+	}
+
+	before := s.song(ctx, name)
+	msg, err := s.SongService.UpdateSong(ctx, req)
+	if err == nil && msg == "success" {
+		s.record(ctx, "song.update", songKey(name), before, s.song(ctx, name))
+	}
+	return msg, err
+}
+
+func (s *auditedSongService) DeleteSong(ctx context.Context, name string) (string, error) {
+	before := s.song(ctx, name)
+	msg, err := s.SongService.DeleteSong(ctx, name)
+	if err == nil && msg == "success" {
+		s.record(ctx, "song.delete", songKey(name), before, nil)
+	}
+	return msg, err
+}
+
+func (s *auditedSongService) RestoreSong(ctx context.Context, name string) (string, error) {
+	msg, err := s.SongService.RestoreSong(ctx, name)
+	if err == nil && msg == "success" {
+		s.record(ctx, "song.restore", songKey(name), nil, s.song(ctx, name))
+	}
+	return msg, err
+}
+
+func (s *auditedSongService) PurgeSong(ctx context.Context, name string) (string, error) {
+	msg, err := s.SongService.PurgeSong(ctx, name)
+	if err == nil && msg == "success" {
+		s.record(ctx, "song.purge", songKey(name), nil, nil)
+	}
+	return msg, err
+}
+
+// song returns nil when the song cannot be read, the diff then shows every
+// field of the other side
+func (s *auditedSongService) song(ctx context.Context, name string) *model.Song {
+	resp, err := s.SongService.GetSong(ctx, name)
+	if err != nil || resp == nil {
+		return nil
+	}
+	return resp.Song
+}
+
+// auditedUserService records user mutations, including likes and negative
+// feedback
+type auditedUserService struct {
+	service.UserService
+	recorder
+}
+
+func NewUserService(next service.UserService, auditLog Log) service.UserService {
+	return &auditedUserService{UserService: next, recorder: recorder{log: auditLog}}
+}
+
+func (s *auditedUserService) CreateUser(ctx context.Context, req *model.CreateUserRequest) (string, error) {
+	msg, err := s.UserService.CreateUser(ctx, req)
+	if err == nil && msg == "success" {
+		s.record(ctx, "user.create", userKey(req.User.ID), nil, s.user(ctx, req.User.ID))
+	}
+	return msg, err
+}
+
+func (s *auditedUserService) UpdateUser(ctx context.Context, req *model.UpdateUserRequest) (string, error) {
+	id := req.ID
+	if id == "" {
+		id = req.User.ID
+	}
+	return s.mutate(ctx, "user.update", id, func() (string, error) {
+		return s.UserService.UpdateUser(ctx, req)
+	})
+}
+
+func (s *auditedUserService) DeleteUser(ctx context.Context, id string) (string, error) {
+	before := s.user(ctx, id)
+	msg, err := s.UserService.DeleteUser(ctx, id)
+	if err == nil && msg == "success" {
+		s.record(ctx, "user.delete", userKey(id), before, nil)
+	}
+	return msg, err
+}
+
+func (s *auditedUserService) RestoreUser(ctx context.Context, id string) (string, error) {
+	msg, err := s.UserService.RestoreUser(ctx, id)
+	if err == nil && msg == "success" {
+		s.record(ctx, "user.restore", userKey(id), nil, s.user(ctx, id))
+	}
+	return msg, err
+}
+
+func (s *auditedUserService) PurgeUser(ctx context.Context, id string) (string, error) {
+	msg, err := s.UserService.PurgeUser(ctx, id)
+	if err == nil && msg == "success" {
+		s.record(ctx, "user.purge", userKey(id), nil, nil)
+	}
+	return msg, err
+}
+
+func (s *auditedUserService) LikeSong(ctx context.Context, userID, songName string) (string, error) {
+	return s.mutate(ctx, "user.like", userID, func() (string, error) {
+		return s.UserService.LikeSong(ctx, userID, songName)
+	})
+}
+
+func (s *auditedUserService) UnlikeSong(ctx context.Context, userID, songName string) (string, error) {
+	return s.mutate(ctx, "user.unlike", userID, func() (string, error) {
+		return s.UserService.UnlikeSong(ctx, userID, songName)
+	})
+}
+
+func (s *auditedUserService) DislikeSong(ctx context.Context, userID, songName string) (string, error) {
+	return s.mutate(ctx, "user.dislike", userID, func() (string, error) {
+		return s.UserService.DislikeSong(ctx, userID, songName)
+	})
+}
+
+func (s *auditedUserService) UndislikeSong(ctx context.Context, userID, songName string) (string, error) {
+	return s.mutate(ctx, "user.undislike", userID, func() (string, error) {
+		return s.UserService.UndislikeSong(ctx, userID, songName)
+	})
+}
+
+func (s *auditedUserService) HideArtist(ctx context.Context, userID, artist string) (string, error) {
+	return s.mutate(ctx, "user.hide_artist", userID, func() (string, error) {
+		return s.UserService.HideArtist(ctx, userID, artist)
+	})
+}
+
+func (s *auditedUserService) UnhideArtist(ctx context.Context, userID, artist string) (string, error) {
+	return s.mutate(ctx, "user.unhide_artist", userID, func() (string, error) {
+		return s.UserService.UnhideArtist(ctx, userID, artist)
+	})
+}
+
+// mutate records fn as action when it succeeds, with the user before and
+// after it ran
+func (s *auditedUserService) mutate(ctx context.Context, action, id string, fn func() (string, error)) (string, error) {
+	before := s.user(ctx, id)
+	msg, err := fn()
+	if err == nil && msg == "success" {
+		s.record(ctx, action, userKey(id), before, s.user(ctx, id))
+	}
+	return msg, err
+}
+
+func (s *auditedUserService) user(ctx context.Context, id string) *model.User {
+	resp, err := s.UserService.GetUser(ctx, id)
+	if err != nil || resp == nil {
+		return nil
+	}
+	return resp.User
+}
+
+func songKey(name string) string { return fmt.Sprintf("song:%s", name) }
+func userKey(id string) string   { return fmt.Sprintf("user:%s", id) }
+
+// auditedOnboardingService records the genres and taste vector onboarding
+// seeds. The picked songs also show up as likes, onboarding likes them
+// through the user service.
+type auditedOnboardingService struct {
+	onboarding.Service
+	users service.UserService
+	recorder
+}
+
+func NewOnboardingService(next onboarding.Service, users service.UserService, auditLog Log) onboarding.Service {
+	return &auditedOnboardingService{Service: next, users: users, recorder: recorder{log: auditLog}}
+}
+
+func (s *auditedOnboardingService) Onboard(ctx context.Context, req *model.OnboardUserRequest) (string, error) {
+	before := s.user(ctx, req.UserID)
+	msg, err := s.Service.Onboard(ctx, req)
+	if err == nil && msg == "success" {
+		s.record(ctx, "user.onboard", userKey(req.UserID), before, s.user(ctx, req.UserID))
+	}
+	return msg, err
+}
+
+func (s *auditedOnboardingService) user(ctx context.Context, id string) *model.User {
+	resp, err := s.users.GetUser(ctx, id)
+	if err != nil || resp == nil {
+		return nil
+	}
+	return resp.User
+}
@@ -0,0 +1,120 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"music-store/internal/tenant"
+	"music-store/utils"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+const apiKeyPrefix = "ms_"
+
+// APIKey is what is stored for an issued key. The key itself is never
+// stored, only its SHA-256 digest, which is also its ID.
+type APIKey struct {
+	ID        string    `json:"id"`
+	Subject   string    `json:"subject"`
+	Roles     []string  `json:"roles,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type APIKeyStore struct {
+	redisClient redis.UniversalClient
+}
+
+func NewAPIKeyStore(redisClient redis.UniversalClient) *APIKeyStore {
+	return &APIKeyStore{redisClient: redisClient}
+}
+
+// Create issues a new key and returns it in plain text. This is the only
+// time the plain key is available.
+func (s *APIKeyStore) Create(ctx context.Context, subject string, roles []string) (string, *APIKey, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, err
+	}
+	plain := apiKeyPrefix + base64.RawURLEncoding.EncodeToString(raw)
+
+	key := &APIKey{ID: hashAPIKey(plain), Subject: subject, Roles: roles, CreatedAt: time.Now().UTC()}
+	data, err := json.Marshal(key)
+	if err != nil {
+		return "", nil, err
+	}
+	if err := s.redisClient.Set(ctx, apiKeyRedisKey(ctx, key.ID), data, 0).Err(); err != nil {
+		return "", nil, err
+	}
+	return plain, key, nil
+}
+
+func (s *APIKeyStore) Revoke(ctx context.Context, id string) error {
+	return s.redisClient.Del(ctx, apiKeyRedisKey(ctx, id)).Err()
+}
+
+func (s *APIKeyStore) Authenticate(ctx context.Context, plain string) (*Principal, error) {
+	data, err := s.redisClient.Get(ctx, apiKeyRedisKey(ctx, hashAPIKey(plain))).Bytes()
+	if err == redis.Nil {
+		return nil, errors.Wrap(ErrInvalidCredentials, "unknown api key")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var key APIKey
+	if err := json.Unmarshal(data, &key); err != nil {
+		return nil, err
+	}
+	return &Principal{Subject: key.Subject, Kind: KindAPIKey, Roles: key.Roles, KeyID: key.ID, Tenant: tenant.ID(ctx)}, nil
+}
+
+// ListBySubject scans every stored key and returns those owned by subject
+func (s *APIKeyStore) ListBySubject(ctx context.Context, subject string) ([]*APIKey, error) {
+	redisKeys, err := utils.ScanKeys(ctx, s.redisClient, apiKeyRedisKey(ctx, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var keys []*APIKey
+	for _, redisKey := range redisKeys {
+		data, err := s.redisClient.Get(ctx, redisKey).Bytes()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue // Revoked while scanning
+		}
+		var key APIKey
+		if err := json.Unmarshal(data, &key); err != nil {
+			continue
+		}
+		if key.Subject == subject {
+			keys = append(keys, &key)
+		}
+	}
+	return keys, nil
+}
+
+func hashAPIKey(plain string) string {
+	return HashToken(plain)
+}
+
+// HashToken returns the hex encoded SHA-256 digest under which opaque
+// secrets such as API keys and refresh tokens are stored
+func HashToken(plain string) string {
+	sum := sha256.Sum256([]byte(plain))
+	return hex.EncodeToString(sum[:])
+}
+
+// apiKeyRedisKey scopes keys to the tenant, a key only works for the
+// storefront it was created for
+func apiKeyRedisKey(ctx context.Context, id string) string {
+	return tenant.Key(ctx, fmt.Sprintf("apikey:%s", id))
+}
@@ -0,0 +1,183 @@
+// Package auth identifies the caller of a request from a JWT bearer token or
+// an API key and stores the resulting principal in the request context.
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"music-store/internal/api"
+	"music-store/internal/logging"
+	"music-store/internal/tenant"
+	net_http "net/http"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+// Principal kinds
+const (
+	KindUser   = "user"
+	KindAPIKey = "api_key"
+)
+
+var (
+	ErrMissingCredentials error = api.NewError(401, "missing credentials")
+	ErrInvalidCredentials error = api.NewError(401, "invalid credentials")
+	ErrTenantMismatch     error = api.NewError(403, "credentials belong to another tenant")
+	ErrTenantRequired     error = api.NewError(400, "tenant required")
+)
+
+type (
+	// Principal is the authenticated caller of a request
+	Principal struct {
+		Subject string   `json:"subject"` // User ID for tokens, owner for API keys
+		Kind    string   `json:"kind"`
+		Roles   []string `json:"roles,omitempty"`
+		KeyID   string   `json:"key_id,omitempty"` // Set for API keys only
+		Tenant  string   `json:"tenant,omitempty"`
+	}
+
+	// Authenticator resolves the principal behind a request
+	Authenticator interface {
+		Authenticate(ctx context.Context, r *net_http.Request) (*Principal, error)
+	}
+
+	principalKey struct{}
+)
+
+func WithPrincipal(ctx context.Context, p *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+// PrincipalFromContext returns the principal stored by the auth filter
+func PrincipalFromContext(ctx context.Context) (*Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(*Principal)
+	return p, ok && p != nil
+}
+
+func (p *Principal) HasRole(role string) bool {
+	for _, r := range p.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// authenticator accepts either an API key or a bearer token. API keys are
+// sent as "X-API-Key: <key>" or "Authorization: ApiKey <key>".
+type authenticator struct {
+	tokens *JWTVerifier
+	keys   *APIKeyStore
+}
+
+// NewAuthenticator returns an Authenticator that tries API keys first and
+// JWTs second. Either argument may be nil to disable that scheme.
+func NewAuthenticator(tokens *JWTVerifier, keys *APIKeyStore) Authenticator {
+	return &authenticator{tokens: tokens, keys: keys}
+}
+
+func (a *authenticator) Authenticate(ctx context.Context, r *net_http.Request) (*Principal, error) {
+	apiKey := r.Header.Get("X-API-Key")
+	scheme, credentials, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+
+	switch {
+	case apiKey != "" && a.keys != nil:
+		return a.keys.Authenticate(ctx, apiKey)
+	case strings.EqualFold(scheme, "ApiKey") && a.keys != nil:
+		return a.keys.Authenticate(ctx, strings.TrimSpace(credentials))
+	case strings.EqualFold(scheme, "Bearer") && a.tokens != nil:
+		return a.tokens.Verify(strings.TrimSpace(credentials))
+	case apiKey != "" || scheme != "":
+		return nil, errors.Wrap(ErrInvalidCredentials, "unsupported authentication scheme")
+	default:
+		return nil, ErrMissingCredentials
+	}
+}
+
+// NewHandlerOption authenticates every request before it is decoded.
+// Requests without valid credentials are answered with 401 and never reach
+// the endpoint. The credentials must belong to the tenant resolved by the
+// tenant filter, requests that named no tenant get the one of their token.
+func NewHandlerOption(authenticator Authenticator, tenants *tenant.Registry) http.HandlerOption {
+	return http.HandlerWithFilter(func(next net_http.Handler) net_http.Handler {
+		return net_http.HandlerFunc(func(w net_http.ResponseWriter, r *net_http.Request) {
+			principal, err := authenticator.Authenticate(r.Context(), r)
+			if err != nil {
+				// A failed key lookup says nothing about the credentials
+				if isCredentialError(err) {
+					writeUnauthorized(w, err)
+				} else {
+					writeError(r.Context(), w, err)
+				}
+				return
+			}
+
+			logging.Annotate(r.Context(), slog.String("principal", principal.Subject), slog.String("principal_kind", principal.Kind))
+
+			ctx, err := bindTenant(r.Context(), tenants, principal)
+			if err != nil {
+				writeError(r.Context(), w, err)
+				return
+			}
+			next.ServeHTTP(w, r.WithContext(WithPrincipal(ctx, principal)))
+		})
+	})
+}
+
+func bindTenant(ctx context.Context, tenants *tenant.Registry, principal *Principal) (context.Context, error) {
+	if !tenants.MultiTenant() {
+		return ctx, nil
+	}
+
+	// Tokens and keys without a tenant were issued for the default
+	// keyspace and are not valid for any storefront. The registry default
+	// only stands in for a tenant the request did not name, the one of the
+	// token wins over it.
+	resolved, ok := tenant.Resolved(ctx)
+	if ok && tenant.Defaulted(ctx) && principal.Tenant != "" {
+		ok = false
+	}
+	switch {
+	case principal.Tenant == "" && !ok:
+		return nil, ErrTenantRequired
+	case ok && resolved.ID != principal.Tenant:
+		return nil, ErrTenantMismatch
+	case ok:
+		return ctx, nil
+	}
+
+	t, ok := tenants.Get(principal.Tenant)
+	if !ok {
+		return nil, ErrTenantMismatch
+	}
+	return tenant.WithTenant(ctx, t), nil
+}
+
+func isCredentialError(err error) bool {
+	cause := errors.Cause(err)
+	return cause == ErrMissingCredentials || cause == ErrInvalidCredentials
+}
+
+// writeError answers with the status of an api.Error. Anything else is a
+// backend failure, logged here and not described to the client.
+func writeError(ctx context.Context, w net_http.ResponseWriter, err error) {
+	code, msg := net_http.StatusInternalServerError, "internal error"
+	if se, ok := errors.Cause(err).(api.Error); ok {
+		code, msg = se.StatusCode(), err.Error()
+	} else {
+		slog.ErrorContext(ctx, "authentication failed", "error", err)
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
+
+func writeUnauthorized(w net_http.ResponseWriter, err error) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("WWW-Authenticate", `Bearer realm="music-store"`)
+	w.WriteHeader(net_http.StatusUnauthorized)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
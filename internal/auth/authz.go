@@ -0,0 +1,146 @@
+package auth
+
+import (
+	"context"
+	"log/slog"
+	"music-store/internal/api"
+	"time"
+
+	tmux "github.com/dimfeld/httptreemux/v5"
+	"github.com/unbxd/go-base/kit/endpoint"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+// Roles
+const (
+	RoleAdmin        = "admin"         // Full access
+	RoleCatalogAdmin = "catalog_admin" // May create, update and delete songs
+	RoleReadOnly     = "readonly"      // Service accounts restricted to listing
+)
+
+var ErrForbidden error = api.NewError(403, "forbidden")
+
+type (
+	// OwnerFunc returns the user ID owning the resource a request targets
+	OwnerFunc func(ctx context.Context, request interface{}) string
+
+	// Policy declares who may call a route. Admins may call every route and
+	// read-only principals only those with AllowReadOnly. Everyone else is
+	// allowed when the policy has no Roles and no Owner, when they hold one
+	// of the Roles, or when they own the targeted resource.
+	Policy struct {
+		Action        string // Name of the operation, used in audit entries
+		Roles         []string
+		Owner         OwnerFunc
+		AllowReadOnly bool
+	}
+
+	Denial struct {
+		Action     string     `json:"action"`
+		Principal  *Principal `json:"principal"`
+		Method     string     `json:"method,omitempty"`
+		Path       string     `json:"path,omitempty"`
+		RemoteAddr string     `json:"remote_addr,omitempty"`
+		RequestID  string     `json:"request_id,omitempty"`
+		Timestamp  time.Time  `json:"timestamp"`
+	}
+
+	// DenialRecorder keeps an audit trail of rejected requests
+	DenialRecorder interface {
+		RecordDenial(ctx context.Context, denial *Denial)
+	}
+)
+
+// Authenticated allows every principal except read-only service accounts
+func Authenticated(action string) Policy {
+	return Policy{Action: action}
+}
+
+// Listing additionally allows read-only service accounts
+func Listing(action string) Policy {
+	return Policy{Action: action, AllowReadOnly: true}
+}
+
+// RequireRole allows principals holding any of the roles
+func RequireRole(action string, roles ...string) Policy {
+	return Policy{Action: action, Roles: roles}
+}
+
+// RequireOwner allows the owner of the resource and principals holding any
+// of the roles
+func RequireOwner(action string, owner OwnerFunc, roles ...string) Policy {
+	return Policy{Action: action, Owner: owner, Roles: roles}
+}
+
+// PathOwner reads the owner from a path parameter such as :id
+func PathOwner(param string) OwnerFunc {
+	return func(ctx context.Context, _ interface{}) string {
+		return tmux.ContextParams(ctx)[param]
+	}
+}
+
+// Allows evaluates the policy for a decoded request
+func (p Policy) Allows(ctx context.Context, principal *Principal, request interface{}) bool {
+	switch {
+	case principal == nil:
+		return false
+	case principal.HasRole(RoleAdmin):
+		return true
+	case principal.HasRole(RoleReadOnly):
+		return p.AllowReadOnly
+	case len(p.Roles) == 0 && p.Owner == nil:
+		return true
+	}
+
+	for _, role := range p.Roles {
+		if principal.HasRole(role) {
+			return true
+		}
+	}
+	return p.Owner != nil && principal.Subject != "" && p.Owner(ctx, request) == principal.Subject
+}
+
+// Authorizer enforces policies in the endpoint layer, after the request has
+// been authenticated and decoded
+type Authorizer struct {
+	recorder DenialRecorder
+}
+
+func NewAuthorizer(recorder DenialRecorder) *Authorizer {
+	return &Authorizer{recorder: recorder}
+}
+
+// Enforce returns a HandlerOption rejecting requests the policy does not
+// allow with ErrForbidden
+func (a *Authorizer) Enforce(policy Policy) http.HandlerOption {
+	return http.HandlerWithEndpointMiddleware(func(next endpoint.Endpoint) endpoint.Endpoint {
+		return func(ctx context.Context, request interface{}) (interface{}, error) {
+			principal, _ := PrincipalFromContext(ctx)
+			if policy.Allows(ctx, principal, request) {
+				return next(ctx, request)
+			}
+
+			a.recorder.RecordDenial(ctx, &Denial{
+				Action:     policy.Action,
+				Principal:  principal,
+				Method:     api.ContextString(ctx, http.ContextKeyRequestMethod),
+				Path:       api.ContextString(ctx, http.ContextKeyRequestPath),
+				RemoteAddr: api.ContextString(ctx, http.ContextKeyRequestRemoteAddr),
+				RequestID:  api.ContextString(ctx, http.ContextKeyRequestXRequestID),
+				Timestamp:  time.Now().UTC(),
+			})
+			return nil, ErrForbidden
+		}
+	})
+}
+
+// logDenialRecorder writes denials to the default logger
+type logDenialRecorder struct{}
+
+func NewLogDenialRecorder() DenialRecorder {
+	return logDenialRecorder{}
+}
+
+func (logDenialRecorder) RecordDenial(ctx context.Context, denial *Denial) {
+	slog.WarnContext(ctx, "authorization denied", "action", denial.Action, "denial", denial)
+}
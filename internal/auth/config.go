@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"crypto/rsa"
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Config holds the token validation settings
+type Config struct {
+	JWTSecret        string
+	JWTPublicKeyFile string // PEM encoded RSA public key for RS256
+	Issuer           string
+	Audience         string
+	AccessTokenTTL   time.Duration
+	RefreshTokenTTL  time.Duration
+}
+
+// NewJWTVerifierFromConfig returns nil when neither a secret nor a public key
+// is configured, in which case only API keys are accepted
+func NewJWTVerifierFromConfig(config *Config) (*JWTVerifier, error) {
+	var publicKey *rsa.PublicKey
+	if config.JWTPublicKeyFile != "" {
+		pem, err := os.ReadFile(config.JWTPublicKeyFile)
+		if err != nil {
+			return nil, err
+		}
+		if publicKey, err = jwt.ParseRSAPublicKeyFromPEM(pem); err != nil {
+			return nil, err
+		}
+	}
+
+	if config.JWTSecret == "" && publicKey == nil {
+		return nil, nil
+	}
+	return NewJWTVerifier([]byte(config.JWTSecret), publicKey, config.Issuer, config.Audience)
+}
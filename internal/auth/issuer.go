@@ -0,0 +1,66 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/pkg/errors"
+)
+
+const refreshTokenPrefix = "rt_"
+
+// TokenIssuer signs HS256 access tokens that JWTVerifier accepts and mints
+// opaque refresh tokens
+type TokenIssuer struct {
+	secret     []byte
+	issuer     string
+	audience   string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewTokenIssuer(config *Config) (*TokenIssuer, error) {
+	if config.JWTSecret == "" {
+		return nil, errors.New("issuing tokens requires AUTH_JWT_SECRET")
+	}
+	return &TokenIssuer{
+		secret:     []byte(config.JWTSecret),
+		issuer:     config.Issuer,
+		audience:   config.Audience,
+		accessTTL:  config.AccessTokenTTL,
+		refreshTTL: config.RefreshTokenTTL,
+	}, nil
+}
+
+func (i *TokenIssuer) AccessTokenTTL() time.Duration  { return i.accessTTL }
+func (i *TokenIssuer) RefreshTokenTTL() time.Duration { return i.refreshTTL }
+
+func (i *TokenIssuer) IssueAccessToken(userID, tenantID string, roles []string) (string, error) {
+	now := time.Now()
+	claims := Claims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Subject:   userID,
+			Issuer:    i.issuer,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(i.accessTTL)),
+		},
+		Roles:  roles,
+		Tenant: tenantID,
+	}
+	if i.audience != "" {
+		claims.Audience = jwt.ClaimStrings{i.audience}
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(i.secret)
+}
+
+// NewRefreshToken returns a random opaque token and the ID it is stored under
+func (i *TokenIssuer) NewRefreshToken() (plain, id string, err error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", "", err
+	}
+	plain = refreshTokenPrefix + base64.RawURLEncoding.EncodeToString(raw)
+	return plain, HashToken(plain), nil
+}
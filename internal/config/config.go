@@ -0,0 +1,401 @@
+// Package config holds the settings of the music store. Each value comes
+// from the first of a flag, an environment variable, the YAML or TOML file
+// and the built-in default that sets it.
+package config
+
+import (
+	"fmt"
+	"io"
+	"music-store/internal/audit"
+	"music-store/internal/auth"
+	"music-store/internal/idempotency"
+	"music-store/internal/logging"
+	"music-store/internal/metrics"
+	"music-store/internal/ratelimit"
+	"music-store/internal/repository"
+	"music-store/internal/revision"
+	"music-store/internal/service"
+	"music-store/internal/service/recommender"
+	"music-store/internal/timeout"
+	"music-store/internal/tracing"
+	"music-store/utils"
+	"net"
+	"strconv"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces secrets in printed configurations
+const redacted = "[redacted]"
+
+type (
+	Config struct {
+		Server    Server    `yaml:"server" toml:"server"`
+		Redis     Redis     `yaml:"redis" toml:"redis"`
+		Storage   Storage   `yaml:"storage" toml:"storage"`
+		Auth      Auth      `yaml:"auth" toml:"auth"`
+		Limits    Limits    `yaml:"limits" toml:"limits"`
+		Index     Index     `yaml:"index" toml:"index"`
+		Retention Retention `yaml:"retention" toml:"retention"`
+		Features  Features  `yaml:"features" toml:"features"`
+		Metrics   Metrics   `yaml:"metrics" toml:"metrics"`
+		Tracing   Tracing   `yaml:"tracing" toml:"tracing"`
+		Log       Log       `yaml:"log" toml:"log"`
+	}
+
+	Server struct {
+		Host string `yaml:"host" toml:"host"`
+		Port int    `yaml:"port" toml:"port"`
+		// ShutdownTimeout bounds how long requests in flight may take to
+		// finish after SIGTERM, keep it below the orchestrator's grace period
+		ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout"`
+		// RequestTimeout bounds every request, RouteTimeouts overrides it
+		// per "METHOD /pattern". 0 leaves requests unbounded.
+		RequestTimeout Duration            `yaml:"request_timeout" toml:"request_timeout"`
+		RouteTimeouts  map[string]Duration `yaml:"route_timeouts" toml:"route_timeouts"`
+	}
+
+	Redis struct {
+		Mode             string   `yaml:"mode" toml:"mode"` // standalone, sentinel or cluster
+		Addresses        []string `yaml:"addresses" toml:"addresses"`
+		MasterName       string   `yaml:"master_name" toml:"master_name"`
+		SentinelPassword string   `yaml:"sentinel_password" toml:"sentinel_password"`
+		Username         string   `yaml:"username" toml:"username"`
+		Password         string   `yaml:"password" toml:"password"`
+		DB               int      `yaml:"db" toml:"db"`
+		TLS              RedisTLS `yaml:"tls" toml:"tls"`
+		PoolSize         int      `yaml:"pool_size" toml:"pool_size"`
+		MinIdleConns     int      `yaml:"min_idle_conns" toml:"min_idle_conns"`
+		DialTimeout      Duration `yaml:"dial_timeout" toml:"dial_timeout"`
+		ReadTimeout      Duration `yaml:"read_timeout" toml:"read_timeout"`
+		WriteTimeout     Duration `yaml:"write_timeout" toml:"write_timeout"`
+		PoolTimeout      Duration `yaml:"pool_timeout" toml:"pool_timeout"`
+		MaxRetries       int      `yaml:"max_retries" toml:"max_retries"` // -1 disables retries
+		MinRetryBackoff  Duration `yaml:"min_retry_backoff" toml:"min_retry_backoff"`
+		MaxRetryBackoff  Duration `yaml:"max_retry_backoff" toml:"max_retry_backoff"`
+	}
+
+	RedisTLS struct {
+		Enabled            bool   `yaml:"enabled" toml:"enabled"`
+		CAFile             string `yaml:"ca_file" toml:"ca_file"`
+		CertFile           string `yaml:"cert_file" toml:"cert_file"`
+		KeyFile            string `yaml:"key_file" toml:"key_file"`
+		ServerName         string `yaml:"server_name" toml:"server_name"`
+		InsecureSkipVerify bool   `yaml:"insecure_skip_verify" toml:"insecure_skip_verify"`
+	}
+
+	Storage struct {
+		Backend    string `yaml:"backend" toml:"backend"` // redis, memory or sqlite
+		SQLitePath string `yaml:"sqlite_path" toml:"sqlite_path"`
+	}
+
+	Auth struct {
+		JWTSecret        string   `yaml:"jwt_secret" toml:"jwt_secret"`
+		JWTPublicKeyFile string   `yaml:"jwt_public_key_file" toml:"jwt_public_key_file"`
+		Issuer           string   `yaml:"issuer" toml:"issuer"`
+		Audience         string   `yaml:"audience" toml:"audience"`
+		AccessTokenTTL   Duration `yaml:"access_token_ttl" toml:"access_token_ttl"`
+		RefreshTokenTTL  Duration `yaml:"refresh_token_ttl" toml:"refresh_token_ttl"`
+	}
+
+	// Limits are written as "<requests>/<window>" or "off"
+	Limits struct {
+		Read             string `yaml:"read" toml:"read"`
+		Write            string `yaml:"write" toml:"write"`
+		Search           string `yaml:"search" toml:"search"`
+		Auth             string `yaml:"auth" toml:"auth"`
+		IP               string `yaml:"ip" toml:"ip"` // Checked before authentication
+		APIKeyDailyQuota int    `yaml:"api_key_daily_quota" toml:"api_key_daily_quota"`
+		TrustProxy       bool   `yaml:"trust_proxy" toml:"trust_proxy"` // Also applies to the audit log
+	}
+
+	// Index tunes the candidate search of the recommender
+	Index struct {
+		Weights         map[string]float64 `yaml:"weights" toml:"weights"`
+		CandidateFactor int                `yaml:"candidate_factor" toml:"candidate_factor"`
+		MinCandidates   int                `yaml:"min_candidates" toml:"min_candidates"`
+	}
+
+	Retention struct {
+		Trash         Duration `yaml:"trash" toml:"trash"`
+		Audit         Duration `yaml:"audit" toml:"audit"`
+		Idempotency   Duration `yaml:"idempotency" toml:"idempotency"`
+		SongRevisions int      `yaml:"song_revisions" toml:"song_revisions"`
+	}
+
+	Features struct {
+		TenantsFile     string `yaml:"tenants_file" toml:"tenants_file"`
+		ExperimentsFile string `yaml:"experiments_file" toml:"experiments_file"` // Empty reads them from Redis
+	}
+
+	// Metrics are served without authentication, keep the path off the
+	// public ingress
+	Metrics struct {
+		Enabled        bool     `yaml:"enabled" toml:"enabled"`
+		Path           string   `yaml:"path" toml:"path"`
+		CatalogRefresh Duration `yaml:"catalog_refresh" toml:"catalog_refresh"`
+	}
+
+	Tracing struct {
+		Exporter    string  `yaml:"exporter" toml:"exporter"` // none, otlp, stdout or file
+		Endpoint    string  `yaml:"endpoint" toml:"endpoint"` // OTLP over HTTP
+		File        string  `yaml:"file" toml:"file"`
+		ServiceName string  `yaml:"service_name" toml:"service_name"`
+		SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio"` // Of new traces, callers decide for theirs
+	}
+
+	Log struct {
+		Level  string `yaml:"level" toml:"level"`   // debug, info, warn or error
+		Format string `yaml:"format" toml:"format"` // json or text
+	}
+
+	// Duration reads and prints as "15m", "720h" and so on
+	Duration time.Duration
+)
+
+// Default returns the built-in settings
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Host:            "0.0.0.0",
+			Port:            8080,
+			ShutdownTimeout: Duration(20 * time.Second),
+			RequestTimeout:  Duration(10 * time.Second),
+			// Recommendations score the whole catalogue
+			RouteTimeouts: map[string]Duration{"GET /users/:id/recommendations": Duration(30 * time.Second)},
+		},
+		Redis: Redis{
+			Mode:            string(utils.RedisStandalone),
+			Addresses:       []string{"localhost:6379"},
+			PoolSize:        10,
+			DialTimeout:     Duration(10 * time.Second),
+			ReadTimeout:     Duration(30 * time.Second),
+			WriteTimeout:    Duration(30 * time.Second),
+			PoolTimeout:     Duration(30 * time.Second),
+			MaxRetries:      3,
+			MinRetryBackoff: Duration(8 * time.Millisecond),
+			MaxRetryBackoff: Duration(512 * time.Millisecond),
+		},
+		Storage: Storage{Backend: repository.BackendRedis, SQLitePath: "music-store.db"},
+		Auth: Auth{
+			AccessTokenTTL:  Duration(15 * time.Minute),
+			RefreshTokenTTL: Duration(30 * 24 * time.Hour),
+		},
+		Limits: Limits{
+			Read:             "300/1m",
+			Write:            "60/1m",
+			Search:           "20/1m",
+			Auth:             "10/1m",
+			IP:               "600/1m",
+			APIKeyDailyQuota: 10000,
+		},
+		Index: Index{
+			Weights:         recommender.DefaultConfig().Weights,
+			CandidateFactor: recommender.DefaultConfig().CandidateFactor,
+			MinCandidates:   recommender.DefaultConfig().MinCandidates,
+		},
+		Retention: Retention{
+			Trash:         Duration(30 * 24 * time.Hour),
+			Audit:         Duration(90 * 24 * time.Hour),
+			Idempotency:   Duration(24 * time.Hour),
+			SongRevisions: 20,
+		},
+		Metrics: Metrics{Enabled: true, Path: "/metrics", CatalogRefresh: Duration(time.Minute)},
+		Tracing: Tracing{
+			Exporter:    tracing.ExporterNone,
+			Endpoint:    "http://localhost:4318",
+			File:        "traces.jsonl",
+			ServiceName: tracing.Name,
+			SampleRatio: 1,
+		},
+		Log: Log{Level: "info", Format: logging.FormatJSON},
+	}
+}
+
+// Address is the host:port the server listens on
+func (s Server) Address() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(s.Port))
+}
+
+func (c *Config) RedisConfig() *utils.RedisConfig {
+	r := c.Redis
+	config := &utils.RedisConfig{
+		Mode:             utils.RedisMode(r.Mode),
+		Addresses:        r.Addresses,
+		MasterName:       r.MasterName,
+		Username:         r.Username,
+		Password:         r.Password,
+		Database:         r.DB,
+		SentinelPassword: r.SentinelPassword,
+		PoolSize:         r.PoolSize,
+		MinIdleConns:     r.MinIdleConns,
+		DialTimeout:      time.Duration(r.DialTimeout),
+		ReadTimeout:      time.Duration(r.ReadTimeout),
+		WriteTimeout:     time.Duration(r.WriteTimeout),
+		PoolTimeout:      time.Duration(r.PoolTimeout),
+		MaxRetries:       r.MaxRetries,
+		MinRetryBackoff:  time.Duration(r.MinRetryBackoff),
+		MaxRetryBackoff:  time.Duration(r.MaxRetryBackoff),
+	}
+	if r.TLS.Enabled {
+		config.TLS = &utils.RedisTLSConfig{
+			CAFile:             r.TLS.CAFile,
+			CertFile:           r.TLS.CertFile,
+			KeyFile:            r.TLS.KeyFile,
+			ServerName:         r.TLS.ServerName,
+			InsecureSkipVerify: r.TLS.InsecureSkipVerify,
+		}
+	}
+	return config
+}
+
+func (c *Config) StorageConfig() *repository.Config {
+	return &repository.Config{Backend: c.Storage.Backend, SQLitePath: c.Storage.SQLitePath}
+}
+
+func (c *Config) AuthConfig() *auth.Config {
+	return &auth.Config{
+		JWTSecret:        c.Auth.JWTSecret,
+		JWTPublicKeyFile: c.Auth.JWTPublicKeyFile,
+		Issuer:           c.Auth.Issuer,
+		Audience:         c.Auth.Audience,
+		AccessTokenTTL:   time.Duration(c.Auth.AccessTokenTTL),
+		RefreshTokenTTL:  time.Duration(c.Auth.RefreshTokenTTL),
+	}
+}
+
+// RateLimitConfig expects a validated configuration, limits that do not
+// parse are left off
+func (c *Config) RateLimitConfig() *ratelimit.Config {
+	groups := map[string]ratelimit.Limit{}
+	for group, value := range c.limits() {
+		if limit, err := ratelimit.ParseLimit(value); err == nil {
+			groups[group] = limit
+		}
+	}
+	return &ratelimit.Config{
+		Groups:     groups,
+		DailyQuota: c.Limits.APIKeyDailyQuota,
+		TrustProxy: c.Limits.TrustProxy,
+	}
+}
+
+// TimeoutConfig expects a validated configuration, routes that do not
+// parse are left off
+func (c *Config) TimeoutConfig() *timeout.Config {
+	routes := map[string]time.Duration{}
+	for route, d := range c.Server.RouteTimeouts {
+		if route, err := timeout.CheckRoute(route); err == nil {
+			routes[route] = time.Duration(d)
+		}
+	}
+	return &timeout.Config{Default: time.Duration(c.Server.RequestTimeout), Routes: routes}
+}
+
+func (c *Config) RecommenderConfig() *recommender.Config {
+	return &recommender.Config{
+		Weights:         recommender.Weights(c.Index.Weights),
+		CandidateFactor: c.Index.CandidateFactor,
+		MinCandidates:   c.Index.MinCandidates,
+	}
+}
+
+func (c *Config) ServiceConfig() *service.Config {
+	return &service.Config{TrashRetention: time.Duration(c.Retention.Trash)}
+}
+
+func (c *Config) AuditConfig() *audit.Config {
+	return &audit.Config{Retention: time.Duration(c.Retention.Audit), TrustProxy: c.Limits.TrustProxy}
+}
+
+func (c *Config) IdempotencyConfig() *idempotency.Config {
+	return &idempotency.Config{TTL: time.Duration(c.Retention.Idempotency)}
+}
+
+func (c *Config) RevisionConfig() *revision.Config {
+	return &revision.Config{MaxRevisions: c.Retention.SongRevisions}
+}
+
+func (c *Config) MetricsConfig() *metrics.Config {
+	return &metrics.Config{
+		Enabled:        c.Metrics.Enabled,
+		Path:           c.Metrics.Path,
+		CatalogRefresh: time.Duration(c.Metrics.CatalogRefresh),
+	}
+}
+
+func (c *Config) TracingConfig() *tracing.Config {
+	return &tracing.Config{
+		Exporter:    c.Tracing.Exporter,
+		Endpoint:    c.Tracing.Endpoint,
+		File:        c.Tracing.File,
+		ServiceName: c.Tracing.ServiceName,
+		SampleRatio: c.Tracing.SampleRatio,
+	}
+}
+
+// LoggingConfig expects a validated configuration, a level that does not
+// parse is info
+func (c *Config) LoggingConfig() *logging.Config {
+	config := &logging.Config{Format: c.Log.Format}
+	config.Level.UnmarshalText([]byte(c.Log.Level))
+	return config
+}
+
+func (c *Config) limits() map[string]string {
+	return map[string]string{
+		ratelimit.GroupRead:   c.Limits.Read,
+		ratelimit.GroupWrite:  c.Limits.Write,
+		ratelimit.GroupSearch: c.Limits.Search,
+		ratelimit.GroupAuth:   c.Limits.Auth,
+		ratelimit.GroupIP:     c.Limits.IP,
+	}
+}
+
+// Redacted returns a copy with every secret that is set replaced
+func (c *Config) Redacted() *Config {
+	clone := *c
+	for _, f := range clone.fields() {
+		if f.secret && f.value.String() != "" {
+			f.value.Set(redacted)
+		}
+	}
+	return &clone
+}
+
+// Print writes the configuration as YAML or TOML, secrets redacted
+func (c *Config) Print(w io.Writer, format string) error {
+	switch format {
+	case "yaml":
+		encoder := yaml.NewEncoder(w)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(c.Redacted()); err != nil {
+			return err
+		}
+		return encoder.Close()
+	case "toml":
+		return toml.NewEncoder(w).Encode(c.Redacted())
+	default:
+		return errors.Errorf("unknown format %q, expected yaml or toml", format)
+	}
+}
+
+func (d Duration) String() string {
+	return time.Duration(d).String()
+}
+
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+func (d *Duration) UnmarshalText(text []byte) error {
+	parsed, err := time.ParseDuration(string(text))
+	if err != nil {
+		return fmt.Errorf("invalid duration %q", text)
+	}
+	*d = Duration(parsed)
+	return nil
+}
@@ -0,0 +1,196 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func load(t *testing.T, args ...string) (*Config, error) {
+	t.Helper()
+	return Load(flag.NewFlagSet("test", flag.ContinueOnError), args)
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// A flag beats the environment, which beats the file, which beats the
+// default
+func TestPrecedence(t *testing.T) {
+	path := writeFile(t, "music-store.yaml", `
+server:
+  host: 10.0.0.1
+  port: 9000
+redis:
+  addresses: [a:7000, b:7000]
+  mode: cluster
+retention:
+  trash: 48h
+index:
+  weights:
+    popularity: 0
+`)
+	t.Setenv(FileEnv, path)
+	t.Setenv("PORT", "9100")
+	t.Setenv("TRASH_RETENTION", "72h")
+	t.Setenv("REDIS_ADDR", "ignored:6379")
+	t.Setenv("ROUTE_TIMEOUTS", "GET  /songs=5s")
+
+	config, err := load(t, "-retention.trash", "96h", "-limits.trust_proxy")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Server.Host != "10.0.0.1" || config.Server.Port != 9100 {
+		t.Errorf("server = %+v", config.Server)
+	}
+	if time.Duration(config.Retention.Trash) != 96*time.Hour {
+		t.Errorf("trash retention = %s", config.Retention.Trash)
+	}
+	if !config.Limits.TrustProxy {
+		t.Error("boolean flag without a value was not applied")
+	}
+	// REDIS_ADDRS is unset, so the single address variable applies
+	if len(config.Redis.Addresses) != 1 || config.Redis.Addresses[0] != "ignored:6379" {
+		t.Errorf("addresses = %q", config.Redis.Addresses)
+	}
+	if config.Index.Weights["popularity"] != 0 || config.Index.Weights["embedding_knn"] != 1 {
+		t.Errorf("weights = %v, the file should override only popularity", config.Index.Weights)
+	}
+	routes := config.TimeoutConfig().Routes
+	if routes["GET /songs"] != 5*time.Second || routes["GET /users/:id/recommendations"] != 30*time.Second {
+		t.Errorf("route timeouts = %v, the variable should add to the defaults", routes)
+	}
+	if time.Duration(config.Auth.AccessTokenTTL) != 15*time.Minute {
+		t.Errorf("access token ttl = %s, want the default", config.Auth.AccessTokenTTL)
+	}
+}
+
+func TestTOMLFile(t *testing.T) {
+	path := writeFile(t, "music-store.toml", `
+[storage]
+backend = "sqlite"
+sqlite_path = "/data/store.db"
+
+[redis.tls]
+enabled = true
+server_name = "redis.internal"
+`)
+	config, err := load(t, "-config", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Storage.SQLitePath != "/data/store.db" {
+		t.Errorf("storage = %+v", config.Storage)
+	}
+	redis := config.RedisConfig()
+	if redis.TLS == nil || redis.TLS.ServerName != "redis.internal" {
+		t.Errorf("tls = %+v", redis.TLS)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := map[string]struct {
+		file string
+		env  map[string]string
+		args []string
+		want string
+	}{
+		"unknown yaml key": {file: "bad.yaml", want: "field prot not found"},
+		"unknown toml key": {file: "bad.toml", want: "unknown key server.prot"},
+		"bad extension":    {file: "bad.json", want: "must end in .yaml, .yml or .toml"},
+		"bad env":          {env: map[string]string{"REDIS_POOL_SIZE": "many"}, want: "REDIS_POOL_SIZE: invalid number"},
+		"bad flag":         {args: []string{"-auth.access_token_ttl", "soon"}, want: "-auth.access_token_ttl: invalid duration"},
+		"extra argument":   {args: []string{"serve"}, want: `unexpected argument "serve"`},
+		"bad route":        {env: map[string]string{"ROUTE_TIMEOUTS": "/songs=5s"}, want: `route "/songs" must be <METHOD> <pattern>`},
+		"bad ratio":        {env: map[string]string{"TRACING_SAMPLE_RATIO": "half"}, want: "TRACING_SAMPLE_RATIO: invalid number"},
+		"bad weight":       {env: map[string]string{"INDEX_WEIGHTS": "popularity:NaN"}, want: `weight for "popularity" must be a finite number`},
+	}
+	contents := map[string]string{
+		"bad.yaml": "server:\n  prot: 1\n",
+		"bad.toml": "[server]\nprot = 1\n",
+		"bad.json": "{}",
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			for key, value := range test.env {
+				t.Setenv(key, value)
+			}
+			args := test.args
+			if test.file != "" {
+				args = append(args, "-config", writeFile(t, test.file, contents[test.file]))
+			}
+			_, err := load(t, args...)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("err = %v, want it to mention %q", err, test.want)
+			}
+		})
+	}
+}
+
+func TestValidateReportsEveryProblem(t *testing.T) {
+	config := Default()
+	config.Server.Port = 0
+	config.Redis.Mode = "sentinel"
+	config.Storage.Backend = "postgres"
+	config.Limits.Write = "lots"
+	config.Limits.Search = "0/1m"
+	config.Index.Weights["trending"] = 1
+	config.Retention.SongRevisions = 0
+	config.Features.TenantsFile = "/nonexistent/tenants.json"
+	config.Tracing.Exporter = "otlp"
+	config.Tracing.Endpoint = "localhost:4318"
+	config.Tracing.SampleRatio = 2
+	config.Log.Level = "loud"
+
+	err := config.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, key := range []string{
+		"server.port", "redis.master_name", "storage.backend", "limits.write", "limits.search",
+		"index.weights", "retention.song_revisions", "features.tenants_file",
+		"tracing.endpoint", "tracing.sample_ratio", "log.level",
+	} {
+		if !strings.Contains(err.Error(), "\n  "+key+": ") {
+			t.Errorf("%s is not reported in:\n%v", key, err)
+		}
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	config := Default()
+	config.Auth.JWTSecret = "s3cret"
+	config.Redis.Password = "hunter2"
+
+	for _, format := range []string{"yaml", "toml"} {
+		var out bytes.Buffer
+		if err := config.Print(&out, format); err != nil {
+			t.Fatal(err)
+		}
+		if strings.Contains(out.String(), "s3cret") || strings.Contains(out.String(), "hunter2") {
+			t.Errorf("%s output leaks a secret:\n%s", format, out.String())
+		}
+		if !strings.Contains(out.String(), redacted) {
+			t.Errorf("%s output does not mark the redacted secrets", format)
+		}
+	}
+	if config.Auth.JWTSecret != "s3cret" {
+		t.Error("printing changed the configuration")
+	}
+}
@@ -0,0 +1,327 @@
+package config
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"music-store/internal/service/recommender"
+	"music-store/internal/timeout"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"github.com/pkg/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// FileEnv names the configuration file when no -config flag is given
+const FileEnv = "CONFIG_FILE"
+
+type (
+	// field binds one setting to its environment variables and flag. The
+	// flag is named after the key.
+	field struct {
+		key    string
+		env    []string // The first one set wins
+		value  flag.Value
+		secret bool
+	}
+
+	// flagValue holds a flag until the file and environment are applied
+	flagValue struct {
+		raw  string
+		bool bool
+	}
+
+	stringValue  string
+	intValue     int
+	floatValue   float64
+	boolValue    bool
+	listValue    []string // Comma separated
+	weightsValue map[string]float64
+	routesValue  map[string]Duration
+)
+
+// Load builds the configuration from the defaults, the file, the
+// environment and args, in increasing precedence. The setting flags are
+// added to flags, which may carry flags of the caller. It does not
+// validate.
+func Load(flags *flag.FlagSet, args []string) (*Config, error) {
+	config := Default()
+	fields := config.fields()
+
+	path := flags.String("config", os.Getenv(FileEnv), "YAML or TOML configuration file (env "+FileEnv+")")
+	pending := make(map[string]*flagValue, len(fields))
+	for _, f := range fields {
+		_, isBool := f.value.(*boolValue)
+		pending[f.key] = &flagValue{bool: isBool}
+		flags.Var(pending[f.key], f.key, "env "+strings.Join(f.env, " or "))
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+	if flags.NArg() > 0 {
+		return nil, errors.Errorf("unexpected argument %q", flags.Arg(0))
+	}
+
+	if *path != "" {
+		if err := config.loadFile(*path); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, f := range fields {
+		for _, name := range f.env {
+			value, ok := os.LookupEnv(name)
+			if !ok || value == "" {
+				continue
+			}
+			if err := f.value.Set(value); err != nil {
+				return nil, errors.Wrapf(err, "environment variable %s", name)
+			}
+			break
+		}
+	}
+
+	for _, f := range fields {
+		if !isSet(flags, f.key) {
+			continue
+		}
+		if err := f.value.Set(pending[f.key].raw); err != nil {
+			return nil, errors.Wrapf(err, "flag -%s", f.key)
+		}
+	}
+	return config, nil
+}
+
+func isSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// loadFile reads path as YAML or TOML by its extension, unknown keys are
+// errors so typos do not go unnoticed
+func (c *Config) loadFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return errors.Wrap(err, "reading configuration file")
+	}
+
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(c); err != nil && err != io.EOF {
+			return errors.Wrapf(err, "parsing %s", path)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), c)
+		if err != nil {
+			return errors.Wrapf(err, "parsing %s", path)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return errors.Errorf("parsing %s: unknown key %s", path, undecoded[0])
+		}
+	default:
+		return errors.Errorf("configuration file %s must end in .yaml, .yml or .toml", path)
+	}
+	return nil
+}
+
+// fields lists every setting. Existing deployments set the variables read
+// before the configuration file existed, so those names are kept.
+func (c *Config) fields() []field {
+	return []field{
+		{key: "server.host", env: []string{"SERVER_HOST"}, value: (*stringValue)(&c.Server.Host)},
+		{key: "server.port", env: []string{"PORT"}, value: (*intValue)(&c.Server.Port)},
+		{key: "server.shutdown_timeout", env: []string{"SHUTDOWN_TIMEOUT"}, value: &c.Server.ShutdownTimeout},
+		{key: "server.request_timeout", env: []string{"REQUEST_TIMEOUT"}, value: &c.Server.RequestTimeout},
+		{key: "server.route_timeouts", env: []string{"ROUTE_TIMEOUTS"}, value: (*routesValue)(&c.Server.RouteTimeouts)},
+
+		{key: "redis.mode", env: []string{"REDIS_MODE"}, value: (*stringValue)(&c.Redis.Mode)},
+		{key: "redis.addresses", env: []string{"REDIS_ADDRS", "REDIS_ADDR"}, value: (*listValue)(&c.Redis.Addresses)},
+		{key: "redis.master_name", env: []string{"REDIS_MASTER_NAME"}, value: (*stringValue)(&c.Redis.MasterName)},
+		{key: "redis.sentinel_password", env: []string{"REDIS_SENTINEL_PASSWORD"}, value: (*stringValue)(&c.Redis.SentinelPassword), secret: true},
+		{key: "redis.username", env: []string{"REDIS_USERNAME"}, value: (*stringValue)(&c.Redis.Username)},
+		{key: "redis.password", env: []string{"REDIS_PASSWORD"}, value: (*stringValue)(&c.Redis.Password), secret: true},
+		{key: "redis.db", env: []string{"REDIS_DB"}, value: (*intValue)(&c.Redis.DB)},
+		{key: "redis.tls.enabled", env: []string{"REDIS_TLS"}, value: (*boolValue)(&c.Redis.TLS.Enabled)},
+		{key: "redis.tls.ca_file", env: []string{"REDIS_TLS_CA_FILE"}, value: (*stringValue)(&c.Redis.TLS.CAFile)},
+		{key: "redis.tls.cert_file", env: []string{"REDIS_TLS_CERT_FILE"}, value: (*stringValue)(&c.Redis.TLS.CertFile)},
+		{key: "redis.tls.key_file", env: []string{"REDIS_TLS_KEY_FILE"}, value: (*stringValue)(&c.Redis.TLS.KeyFile)},
+		{key: "redis.tls.server_name", env: []string{"REDIS_TLS_SERVER_NAME"}, value: (*stringValue)(&c.Redis.TLS.ServerName)},
+		{key: "redis.tls.insecure_skip_verify", env: []string{"REDIS_TLS_INSECURE_SKIP_VERIFY"}, value: (*boolValue)(&c.Redis.TLS.InsecureSkipVerify)},
+		{key: "redis.pool_size", env: []string{"REDIS_POOL_SIZE"}, value: (*intValue)(&c.Redis.PoolSize)},
+		{key: "redis.min_idle_conns", env: []string{"REDIS_MIN_IDLE_CONNS"}, value: (*intValue)(&c.Redis.MinIdleConns)},
+		{key: "redis.dial_timeout", env: []string{"REDIS_DIAL_TIMEOUT"}, value: &c.Redis.DialTimeout},
+		{key: "redis.read_timeout", env: []string{"REDIS_READ_TIMEOUT"}, value: &c.Redis.ReadTimeout},
+		{key: "redis.write_timeout", env: []string{"REDIS_WRITE_TIMEOUT"}, value: &c.Redis.WriteTimeout},
+		{key: "redis.pool_timeout", env: []string{"REDIS_POOL_TIMEOUT"}, value: &c.Redis.PoolTimeout},
+		{key: "redis.max_retries", env: []string{"REDIS_MAX_RETRIES"}, value: (*intValue)(&c.Redis.MaxRetries)},
+		{key: "redis.min_retry_backoff", env: []string{"REDIS_MIN_RETRY_BACKOFF"}, value: &c.Redis.MinRetryBackoff},
+		{key: "redis.max_retry_backoff", env: []string{"REDIS_MAX_RETRY_BACKOFF"}, value: &c.Redis.MaxRetryBackoff},
+
+		{key: "storage.backend", env: []string{"STORAGE_BACKEND"}, value: (*stringValue)(&c.Storage.Backend)},
+		{key: "storage.sqlite_path", env: []string{"SQLITE_PATH"}, value: (*stringValue)(&c.Storage.SQLitePath)},
+
+		{key: "auth.jwt_secret", env: []string{"AUTH_JWT_SECRET"}, value: (*stringValue)(&c.Auth.JWTSecret), secret: true},
+		{key: "auth.jwt_public_key_file", env: []string{"AUTH_JWT_PUBLIC_KEY_FILE"}, value: (*stringValue)(&c.Auth.JWTPublicKeyFile)},
+		{key: "auth.issuer", env: []string{"AUTH_JWT_ISSUER"}, value: (*stringValue)(&c.Auth.Issuer)},
+		{key: "auth.audience", env: []string{"AUTH_JWT_AUDIENCE"}, value: (*stringValue)(&c.Auth.Audience)},
+		{key: "auth.access_token_ttl", env: []string{"AUTH_ACCESS_TOKEN_TTL"}, value: &c.Auth.AccessTokenTTL},
+		{key: "auth.refresh_token_ttl", env: []string{"AUTH_REFRESH_TOKEN_TTL"}, value: &c.Auth.RefreshTokenTTL},
+
+		{key: "limits.read", env: []string{"RATELIMIT_READ"}, value: (*stringValue)(&c.Limits.Read)},
+		{key: "limits.write", env: []string{"RATELIMIT_WRITE"}, value: (*stringValue)(&c.Limits.Write)},
+		{key: "limits.search", env: []string{"RATELIMIT_SEARCH"}, value: (*stringValue)(&c.Limits.Search)},
+		{key: "limits.auth", env: []string{"RATELIMIT_AUTH"}, value: (*stringValue)(&c.Limits.Auth)},
+		{key: "limits.ip", env: []string{"RATELIMIT_IP"}, value: (*stringValue)(&c.Limits.IP)},
+		{key: "limits.api_key_daily_quota", env: []string{"RATELIMIT_API_KEY_DAILY_QUOTA"}, value: (*intValue)(&c.Limits.APIKeyDailyQuota)},
+		{key: "limits.trust_proxy", env: []string{"RATELIMIT_TRUST_PROXY"}, value: (*boolValue)(&c.Limits.TrustProxy)},
+
+		{key: "index.weights", env: []string{"INDEX_WEIGHTS"}, value: (*weightsValue)(&c.Index.Weights)},
+		{key: "index.candidate_factor", env: []string{"INDEX_CANDIDATE_FACTOR"}, value: (*intValue)(&c.Index.CandidateFactor)},
+		{key: "index.min_candidates", env: []string{"INDEX_MIN_CANDIDATES"}, value: (*intValue)(&c.Index.MinCandidates)},
+
+		{key: "retention.trash", env: []string{"TRASH_RETENTION"}, value: &c.Retention.Trash},
+		{key: "retention.audit", env: []string{"AUDIT_RETENTION"}, value: &c.Retention.Audit},
+		{key: "retention.idempotency", env: []string{"IDEMPOTENCY_TTL"}, value: &c.Retention.Idempotency},
+		{key: "retention.song_revisions", env: []string{"SONG_REVISION_LIMIT"}, value: (*intValue)(&c.Retention.SongRevisions)},
+
+		{key: "features.tenants_file", env: []string{"TENANTS_FILE"}, value: (*stringValue)(&c.Features.TenantsFile)},
+		{key: "features.experiments_file", env: []string{"EXPERIMENTS_FILE"}, value: (*stringValue)(&c.Features.ExperimentsFile)},
+
+		{key: "metrics.enabled", env: []string{"METRICS_ENABLED"}, value: (*boolValue)(&c.Metrics.Enabled)},
+		{key: "metrics.path", env: []string{"METRICS_PATH"}, value: (*stringValue)(&c.Metrics.Path)},
+		{key: "metrics.catalog_refresh", env: []string{"METRICS_CATALOG_REFRESH"}, value: &c.Metrics.CatalogRefresh},
+
+		// The OTEL_ names are the ones collectors and SDKs document
+		{key: "tracing.exporter", env: []string{"TRACING_EXPORTER"}, value: (*stringValue)(&c.Tracing.Exporter)},
+		{key: "tracing.endpoint", env: []string{"TRACING_ENDPOINT", "OTEL_EXPORTER_OTLP_ENDPOINT"}, value: (*stringValue)(&c.Tracing.Endpoint)},
+		{key: "tracing.file", env: []string{"TRACING_FILE"}, value: (*stringValue)(&c.Tracing.File)},
+		{key: "tracing.service_name", env: []string{"TRACING_SERVICE_NAME", "OTEL_SERVICE_NAME"}, value: (*stringValue)(&c.Tracing.ServiceName)},
+		{key: "tracing.sample_ratio", env: []string{"TRACING_SAMPLE_RATIO"}, value: (*floatValue)(&c.Tracing.SampleRatio)},
+
+		{key: "log.level", env: []string{"LOG_LEVEL"}, value: (*stringValue)(&c.Log.Level)},
+		{key: "log.format", env: []string{"LOG_FORMAT"}, value: (*stringValue)(&c.Log.Format)},
+	}
+}
+
+func (v *flagValue) String() string   { return v.raw }
+func (v *flagValue) IsBoolFlag() bool { return v.bool }
+
+func (v *flagValue) Set(s string) error {
+	v.raw = s
+	return nil
+}
+
+func (v *stringValue) String() string { return string(*v) }
+
+func (v *stringValue) Set(s string) error {
+	*v = stringValue(s)
+	return nil
+}
+
+func (v *intValue) String() string { return strconv.Itoa(int(*v)) }
+
+func (v *intValue) Set(s string) error {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", s)
+	}
+	*v = intValue(n)
+	return nil
+}
+
+func (v *floatValue) String() string { return strconv.FormatFloat(float64(*v), 'g', -1, 64) }
+
+func (v *floatValue) Set(s string) error {
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", s)
+	}
+	*v = floatValue(f)
+	return nil
+}
+
+func (v *boolValue) String() string { return strconv.FormatBool(bool(*v)) }
+
+func (v *boolValue) Set(s string) error {
+	b, err := strconv.ParseBool(s)
+	if err != nil {
+		return fmt.Errorf("invalid boolean %q", s)
+	}
+	*v = boolValue(b)
+	return nil
+}
+
+func (v *listValue) String() string { return strings.Join(*v, ",") }
+
+func (v *listValue) Set(s string) error {
+	var items []string
+	for _, item := range strings.Split(s, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	*v = items
+	return nil
+}
+
+func (v *weightsValue) String() string {
+	pairs := make([]string, 0, len(*v))
+	for source, weight := range *v {
+		pairs = append(pairs, source+":"+strconv.FormatFloat(weight, 'g', -1, 64))
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set overrides the weights of the sources listed, as the file does
+func (v *weightsValue) Set(s string) error {
+	weights, err := recommender.ParseWeights(s)
+	if err != nil {
+		return err
+	}
+	*v = weightsValue(recommender.Weights(*v).Merge(weights))
+	return nil
+}
+
+func (v *routesValue) String() string {
+	pairs := make([]string, 0, len(*v))
+	for route, d := range *v {
+		pairs = append(pairs, route+"="+d.String())
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// Set overrides the timeouts of the routes listed, as the file does
+func (v *routesValue) Set(s string) error {
+	routes, err := timeout.ParseRoutes(s)
+	if err != nil {
+		return err
+	}
+	merged := make(map[string]Duration, len(*v)+len(routes))
+	for route, d := range *v {
+		merged[route] = d
+	}
+	for route, d := range routes {
+		merged[route] = Duration(d)
+	}
+	*v = merged
+	return nil
+}
+
+func (d *Duration) Set(s string) error {
+	return d.UnmarshalText([]byte(s))
+}
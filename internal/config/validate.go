@@ -0,0 +1,204 @@
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"music-store/internal/logging"
+	"music-store/internal/ratelimit"
+	"music-store/internal/repository"
+	"music-store/internal/service/recommender"
+	"music-store/internal/timeout"
+	"music-store/internal/tracing"
+	"music-store/utils"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// problems collects every invalid setting so they are reported together
+type problems []string
+
+func (p *problems) add(key, format string, args ...interface{}) {
+	*p = append(*p, key+": "+fmt.Sprintf(format, args...))
+}
+
+func (p *problems) positive(key string, d Duration) {
+	if d <= 0 {
+		p.add(key, "must be a positive duration, got %s", d)
+	}
+}
+
+// file reports a path that is set but cannot be read
+func (p *problems) file(key, path string) {
+	if path == "" {
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		p.add(key, "%v", err)
+	}
+}
+
+// Validate reports every invalid setting, named by its key
+func (c *Config) Validate() error {
+	var p problems
+
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		p.add("server.port", "must be between 1 and 65535, got %d", c.Server.Port)
+	}
+	p.positive("server.shutdown_timeout", c.Server.ShutdownTimeout)
+	if c.Server.RequestTimeout < 0 {
+		p.add("server.request_timeout", "must not be negative, 0 disables the timeout")
+	}
+	for route, d := range c.Server.RouteTimeouts {
+		if _, err := timeout.CheckRoute(route); err != nil {
+			p.add("server.route_timeouts", "%v", err)
+		} else if d < 0 {
+			p.add("server.route_timeouts", "timeout of %s must not be negative", route)
+		}
+	}
+
+	c.validateRedis(&p)
+
+	switch c.Storage.Backend {
+	case repository.BackendRedis, repository.BackendMemory:
+	case repository.BackendSQLite:
+		if c.Storage.SQLitePath == "" {
+			p.add("storage.sqlite_path", "is required with the sqlite backend")
+		}
+	default:
+		p.add("storage.backend", "must be redis, memory or sqlite, got %q", c.Storage.Backend)
+	}
+
+	p.file("auth.jwt_public_key_file", c.Auth.JWTPublicKeyFile)
+	p.positive("auth.access_token_ttl", c.Auth.AccessTokenTTL)
+	p.positive("auth.refresh_token_ttl", c.Auth.RefreshTokenTTL)
+
+	for group, value := range c.limits() {
+		if _, err := ratelimit.ParseLimit(value); err != nil {
+			p.add("limits."+group, "%v", err)
+		}
+	}
+	if c.Limits.APIKeyDailyQuota < 0 {
+		p.add("limits.api_key_daily_quota", "must not be negative, 0 disables the quota")
+	}
+
+	known := recommender.DefaultWeights()
+	for source, weight := range c.Index.Weights {
+		if _, ok := known[source]; !ok {
+			p.add("index.weights", "unknown source %q", source)
+		} else if weight < 0 {
+			p.add("index.weights", "weight of %s must not be negative", source)
+		}
+	}
+	if c.Index.CandidateFactor < 1 {
+		p.add("index.candidate_factor", "must be at least 1, got %d", c.Index.CandidateFactor)
+	}
+	if c.Index.MinCandidates < 0 {
+		p.add("index.min_candidates", "must not be negative, got %d", c.Index.MinCandidates)
+	}
+
+	p.positive("retention.trash", c.Retention.Trash)
+	p.positive("retention.audit", c.Retention.Audit)
+	p.positive("retention.idempotency", c.Retention.Idempotency)
+	if c.Retention.SongRevisions < 1 {
+		p.add("retention.song_revisions", "must be at least 1, got %d", c.Retention.SongRevisions)
+	}
+
+	p.file("features.tenants_file", c.Features.TenantsFile)
+	p.file("features.experiments_file", c.Features.ExperimentsFile)
+
+	if c.Metrics.Enabled {
+		if !strings.HasPrefix(c.Metrics.Path, "/") {
+			p.add("metrics.path", "must start with /, got %q", c.Metrics.Path)
+		}
+		p.positive("metrics.catalog_refresh", c.Metrics.CatalogRefresh)
+	}
+
+	switch c.Tracing.Exporter {
+	case tracing.ExporterNone, tracing.ExporterStdout:
+	case tracing.ExporterOTLP:
+		if u, err := url.Parse(c.Tracing.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			p.add("tracing.endpoint", "must be an http or https URL, got %q", c.Tracing.Endpoint)
+		}
+	case tracing.ExporterFile:
+		if c.Tracing.File == "" {
+			p.add("tracing.file", "is required with the file exporter")
+		}
+	default:
+		p.add("tracing.exporter", "must be none, otlp, stdout or file, got %q", c.Tracing.Exporter)
+	}
+	if c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1 {
+		p.add("tracing.sample_ratio", "must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	}
+
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Log.Level)); err != nil {
+		p.add("log.level", "must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	if c.Log.Format != logging.FormatJSON && c.Log.Format != logging.FormatText {
+		p.add("log.format", "must be json or text, got %q", c.Log.Format)
+	}
+
+	if len(p) == 0 {
+		return nil
+	}
+	// Map iteration above is unordered, keep the report stable
+	sort.Strings(p)
+	return errors.Errorf("invalid configuration:\n  %s", strings.Join(p, "\n  "))
+}
+
+func (c *Config) validateRedis(p *problems) {
+	r := c.Redis
+	switch utils.RedisMode(r.Mode) {
+	case utils.RedisStandalone:
+		if len(r.Addresses) > 1 {
+			p.add("redis.addresses", "standalone mode takes one address, got %d", len(r.Addresses))
+		}
+	case utils.RedisSentinel:
+		if r.MasterName == "" {
+			p.add("redis.master_name", "is required in sentinel mode")
+		}
+	case utils.RedisCluster:
+		if r.DB != 0 {
+			p.add("redis.db", "must be 0 in cluster mode, got %d", r.DB)
+		}
+	default:
+		p.add("redis.mode", "must be standalone, sentinel or cluster, got %q", r.Mode)
+	}
+	if len(r.Addresses) == 0 {
+		p.add("redis.addresses", "at least one address is required")
+	}
+	if r.DB < 0 {
+		p.add("redis.db", "must not be negative, got %d", r.DB)
+	}
+
+	if r.TLS.Enabled {
+		p.file("redis.tls.ca_file", r.TLS.CAFile)
+		p.file("redis.tls.cert_file", r.TLS.CertFile)
+		p.file("redis.tls.key_file", r.TLS.KeyFile)
+		if (r.TLS.CertFile == "") != (r.TLS.KeyFile == "") {
+			p.add("redis.tls", "cert_file and key_file must be set together")
+		}
+	}
+
+	if r.PoolSize < 1 {
+		p.add("redis.pool_size", "must be at least 1, got %d", r.PoolSize)
+	}
+	if r.MinIdleConns < 0 || r.MinIdleConns > r.PoolSize {
+		p.add("redis.min_idle_conns", "must be between 0 and the pool size, got %d", r.MinIdleConns)
+	}
+	p.positive("redis.dial_timeout", r.DialTimeout)
+	p.positive("redis.read_timeout", r.ReadTimeout)
+	p.positive("redis.write_timeout", r.WriteTimeout)
+	p.positive("redis.pool_timeout", r.PoolTimeout)
+	// go-redis treats 0 as its default of 3
+	if r.MaxRetries < -1 || r.MaxRetries == 0 {
+		p.add("redis.max_retries", "must be -1 to disable retries or at least 1, got %d", r.MaxRetries)
+	}
+	if r.MinRetryBackoff < 0 || r.MaxRetryBackoff < r.MinRetryBackoff {
+		p.add("redis.min_retry_backoff", "must be between 0 and redis.max_retry_backoff")
+	}
+}
@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"music-store/internal/audit"
+	"music-store/internal/auth"
+	"music-store/internal/handler"
+
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+type AuditController struct {
+	auditLog   audit.Log
+	authorizer *auth.Authorizer
+}
+
+func NewAuditController(auditLog audit.Log, authorizer *auth.Authorizer) *AuditController {
+	return &AuditController{auditLog: auditLog, authorizer: authorizer}
+}
+
+func (c *AuditController) Bind(tr *http.Transport, opts []http.HandlerOption) {
+	tr.GET(
+		"/admin/audit",
+		handler.GetAuditLogHandler(c.auditLog),
+		handler.NewGetAuditLogHandlerOption(withPolicy(opts, c.authorizer, auth.RequireRole("audit.query", auth.RoleAdmin)))...,
+	)
+}
@@ -0,0 +1,44 @@
+package controller
+
+import (
+	"music-store/internal/handler"
+	"music-store/internal/service"
+
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+type AuthController struct {
+	authService service.AuthService
+}
+
+func NewAuthController(authService service.AuthService) *AuthController {
+	return &AuthController{authService: authService}
+}
+
+// Bind registers the account routes. They are how a client obtains
+// credentials, so opts must not carry the authentication filter.
+func (c *AuthController) Bind(tr *http.Transport, opts []http.HandlerOption) {
+	tr.POST(
+		"/auth/signup",
+		handler.SignupHandler(c.authService),
+		handler.NewSignupHandlerOption(opts)...,
+	)
+
+	tr.POST(
+		"/auth/login",
+		handler.LoginHandler(c.authService),
+		handler.NewLoginHandlerOption(opts)...,
+	)
+
+	tr.POST(
+		"/auth/refresh",
+		handler.RefreshTokenHandler(c.authService),
+		handler.NewRefreshTokenHandlerOption(opts)...,
+	)
+
+	tr.POST(
+		"/auth/logout",
+		handler.LogoutHandler(c.authService),
+		handler.NewLogoutHandlerOption(opts)...,
+	)
+}
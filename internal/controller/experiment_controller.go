@@ -0,0 +1,41 @@
+package controller
+
+import (
+	"context"
+	"music-store/internal/auth"
+	"music-store/internal/experiment"
+	"music-store/internal/handler"
+	"music-store/internal/model"
+
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+type ExperimentController struct {
+	experimentService experiment.Service
+	authorizer        *auth.Authorizer
+}
+
+func NewExperimentController(experimentService experiment.Service, authorizer *auth.Authorizer) *ExperimentController {
+	return &ExperimentController{experimentService: experimentService, authorizer: authorizer}
+}
+
+func (c *ExperimentController) Bind(tr *http.Transport, opts []http.HandlerOption) {
+	tr.POST(
+		"/events",
+		handler.TrackEventHandler(c.experimentService),
+		handler.NewTrackEventHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("events.track", eventOwner)))...,
+	)
+
+	tr.GET(
+		"/experiments/:name/summary",
+		handler.GetExperimentSummaryHandler(c.experimentService),
+		handler.NewGetExperimentSummaryHandlerOption(withPolicy(opts, c.authorizer, auth.RequireRole("experiments.summary", auth.RoleAdmin)))...,
+	)
+}
+
+// eventOwner reads the owner from the event body, events are not nested
+// under /users/:id
+func eventOwner(_ context.Context, request interface{}) string {
+	req, _ := request.(model.TrackEventRequest)
+	return req.UserID
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"music-store/internal/auth"
+	"music-store/internal/handler"
+	"music-store/internal/service/onboarding"
+
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+type OnboardingController struct {
+	onboardingService onboarding.Service
+	authorizer        *auth.Authorizer
+}
+
+func NewOnboardingController(onboardingService onboarding.Service, authorizer *auth.Authorizer) *OnboardingController {
+	return &OnboardingController{onboardingService: onboardingService, authorizer: authorizer}
+}
+
+func (c *OnboardingController) Bind(tr *http.Transport, opts []http.HandlerOption) {
+	tr.GET(
+		"/onboarding/picks",
+		handler.GetOnboardingPicksHandler(c.onboardingService),
+		handler.NewGetOnboardingPicksHandlerOption(withPolicy(opts, c.authorizer, auth.Authenticated("onboarding.picks")))...,
+	)
+
+	tr.POST(
+		"/users/:id/onboarding",
+		handler.OnboardUserHandler(c.onboardingService),
+		handler.NewOnboardUserHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.onboarding", auth.PathOwner("id"))))...,
+	)
+}
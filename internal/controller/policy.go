@@ -0,0 +1,12 @@
+package controller
+
+import (
+	"music-store/internal/auth"
+
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+// withPolicy appends the policy enforcement to a copy of the shared options
+func withPolicy(opts []http.HandlerOption, authorizer *auth.Authorizer, policy auth.Policy) []http.HandlerOption {
+	return append(append([]http.HandlerOption{}, opts...), authorizer.Enforce(policy))
+}
@@ -0,0 +1,26 @@
+package controller
+
+import (
+	"music-store/internal/auth"
+	"music-store/internal/handler"
+	"music-store/internal/service/recommender"
+
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+type RecommendationController struct {
+	recommendationService recommender.Service
+	authorizer            *auth.Authorizer
+}
+
+func NewRecommendationController(recommendationService recommender.Service, authorizer *auth.Authorizer) *RecommendationController {
+	return &RecommendationController{recommendationService: recommendationService, authorizer: authorizer}
+}
+
+func (c *RecommendationController) Bind(tr *http.Transport, opts []http.HandlerOption) {
+	tr.GET(
+		"/users/:id/recommendations",
+		handler.GetRecommendationsHandler(c.recommendationService),
+		handler.NewGetRecommendationsHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.recommendations", auth.PathOwner("id"))))...,
+	)
+}
@@ -0,0 +1,32 @@
+package controller
+
+import (
+	"music-store/internal/auth"
+	"music-store/internal/handler"
+	"music-store/internal/revision"
+
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+type RevisionController struct {
+	revisionService revision.Service
+	authorizer      *auth.Authorizer
+}
+
+func NewRevisionController(revisionService revision.Service, authorizer *auth.Authorizer) *RevisionController {
+	return &RevisionController{revisionService: revisionService, authorizer: authorizer}
+}
+
+func (c *RevisionController) Bind(tr *http.Transport, opts []http.HandlerOption) {
+	tr.GET(
+		"/songs/:name/revisions",
+		handler.GetSongRevisionsHandler(c.revisionService),
+		handler.NewGetSongRevisionsHandlerOption(withPolicy(opts, c.authorizer, auth.RequireRole("songs.revisions", auth.RoleCatalogAdmin)))...,
+	)
+
+	tr.POST(
+		"/songs/:name/revisions/:rev/revert",
+		handler.RevertSongHandler(c.revisionService),
+		handler.NewRevertSongHandlerOption(withPolicy(opts, c.authorizer, auth.RequireRole("songs.revert", auth.RoleCatalogAdmin)))...,
+	)
+}
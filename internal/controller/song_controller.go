@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"music-store/internal/auth"
 	"music-store/internal/handler"
 	"music-store/internal/service"
 
@@ -9,39 +10,46 @@ import (
 
 type SongController struct {
 	songService service.SongService
+	authorizer  *auth.Authorizer
 }
 
-func NewSongController(songService service.SongService) *SongController {
-	return &SongController{songService: songService}
+func NewSongController(songService service.SongService, authorizer *auth.Authorizer) *SongController {
+	return &SongController{songService: songService, authorizer: authorizer}
 }
 
 func (c *SongController) Bind(tr *http.Transport, opts []http.HandlerOption) {
 	tr.POST(
 		"/songs",
 		handler.CreateSongHandler(c.songService),
-		handler.NewCreateSongHandlerOption(opts)...,
+		handler.NewCreateSongHandlerOption(withPolicy(opts, c.authorizer, auth.RequireRole("songs.create", auth.RoleCatalogAdmin)))...,
 	)
 
 	tr.GET(
 		"/songs/:name",
 		handler.GetSongHandler(c.songService),
-		handler.NewGetSongHandlerOption(opts)...,
+		handler.NewGetSongHandlerOption(withPolicy(opts, c.authorizer, auth.Authenticated("songs.get")))...,
 	)
 	tr.GET(
 		"/songs",
 		handler.GetAllSongsHandler(c.songService),
-		handler.NewGetAllSongsHandlerOption(opts)...,
+		handler.NewGetAllSongsHandlerOption(withPolicy(opts, c.authorizer, auth.Listing("songs.list")))...,
 	)
 
 	tr.PUT(
 		"/songs/:name",
 		handler.UpdateSongHandler(c.songService),
-		handler.NewUpdateSongHandlerOption(opts)...,
+		handler.NewUpdateSongHandlerOption(withPolicy(opts, c.authorizer, auth.RequireRole("songs.update", auth.RoleCatalogAdmin)))...,
 	)
 
 	tr.DELETE(
 		"/songs/:name",
 		handler.DeleteSongHandler(c.songService),
-		handler.NewDeleteSongHandlerOption(opts)...,
+		handler.NewDeleteSongHandlerOption(withPolicy(opts, c.authorizer, auth.RequireRole("songs.delete", auth.RoleCatalogAdmin)))...,
+	)
+
+	tr.POST(
+		"/songs/:name/restore",
+		handler.RestoreSongHandler(c.songService),
+		handler.NewRestoreSongHandlerOption(withPolicy(opts, c.authorizer, auth.RequireRole("songs.restore", auth.RoleCatalogAdmin)))...,
 	)
 }
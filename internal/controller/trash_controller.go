@@ -0,0 +1,45 @@
+package controller
+
+import (
+	"music-store/internal/auth"
+	"music-store/internal/handler"
+	"music-store/internal/service"
+
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+// TrashController serves the admin view of deleted songs and users,
+// restoring is bound with the songs and users themselves
+type TrashController struct {
+	songService service.SongService
+	userService service.UserService
+	authorizer  *auth.Authorizer
+}
+
+func NewTrashController(songService service.SongService, userService service.UserService, authorizer *auth.Authorizer) *TrashController {
+	return &TrashController{songService: songService, userService: userService, authorizer: authorizer}
+}
+
+func (c *TrashController) Bind(tr *http.Transport, opts []http.HandlerOption) {
+	tr.GET(
+		"/admin/trash/songs",
+		handler.GetTrashHandler(c.songService, c.userService),
+		handler.NewGetTrashHandlerOption(handler.TrashSongs, withPolicy(opts, c.authorizer, auth.RequireRole("trash.list", auth.RoleAdmin)))...,
+	)
+	tr.GET(
+		"/admin/trash/users",
+		handler.GetTrashHandler(c.songService, c.userService),
+		handler.NewGetTrashHandlerOption(handler.TrashUsers, withPolicy(opts, c.authorizer, auth.RequireRole("trash.list", auth.RoleAdmin)))...,
+	)
+
+	tr.DELETE(
+		"/admin/trash/songs/:name",
+		handler.PurgeTrashHandler(c.songService, c.userService),
+		handler.NewPurgeTrashHandlerOption(handler.TrashSongs, "name", withPolicy(opts, c.authorizer, auth.RequireRole("trash.purge", auth.RoleAdmin)))...,
+	)
+	tr.DELETE(
+		"/admin/trash/users/:id",
+		handler.PurgeTrashHandler(c.songService, c.userService),
+		handler.NewPurgeTrashHandlerOption(handler.TrashUsers, "id", withPolicy(opts, c.authorizer, auth.RequireRole("trash.purge", auth.RoleAdmin)))...,
+	)
+}
@@ -1,66 +1,112 @@
 package controller
 
 import (
+	"music-store/internal/auth"
 	"music-store/internal/handler"
+	"music-store/internal/privacy"
 	"music-store/internal/service"
 
 	"github.com/unbxd/go-base/kit/transport/http"
 )
 
 type UserController struct {
-	userService service.UserService
+	userService    service.UserService
+	privacyService privacy.Service
+	authorizer     *auth.Authorizer
 }
 
-func NewUserController(userService service.UserService) *UserController {
-	return &UserController{userService: userService}
+func NewUserController(userService service.UserService, privacyService privacy.Service, authorizer *auth.Authorizer) *UserController {
+	return &UserController{userService: userService, privacyService: privacyService, authorizer: authorizer}
 }
 
 func (c *UserController) Bind(tr *http.Transport, opts []http.HandlerOption) {
 	tr.POST(
 		"/users",
 		handler.CreateUserHandler(c.userService),
-		handler.NewCreateUserHandlerOption(opts)...,
+		handler.NewCreateUserHandlerOption(withPolicy(opts, c.authorizer, auth.RequireRole("users.create", auth.RoleAdmin)))...,
 	)
 
 	tr.GET(
 		"/users/:id",
 		handler.GetUserHandler(c.userService),
-		handler.NewGetUserHandlerOption(opts)...,
+		handler.NewGetUserHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.get", auth.PathOwner("id"))))...,
 	)
 
 	tr.GET(
 		"/users",
 		handler.GetAllUsersHandler(c.userService),
-		handler.NewGetAllUsersHandlerOption(opts)...,
+		handler.NewGetAllUsersHandlerOption(withPolicy(opts, c.authorizer, auth.Listing("users.list")))...,
 	)
 
 	tr.PUT(
 		"/users/:id",
 		handler.UpdateUserHandler(c.userService),
-		handler.NewUpdateUserHandlerOption(opts)...,
+		handler.NewUpdateUserHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.update", auth.PathOwner("id"))))...,
 	)
 
 	tr.DELETE(
 		"/users/:id",
-		handler.DeleteUserHandler(c.userService),
-		handler.NewDeleteUserHandlerOption(opts)...,
+		handler.DeleteUserHandler(c.userService, c.privacyService),
+		handler.NewDeleteUserHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.delete", auth.PathOwner("id"))))...,
+	)
+
+	tr.POST(
+		"/users/:id/restore",
+		handler.RestoreUserHandler(c.userService),
+		handler.NewRestoreUserHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.restore", auth.PathOwner("id"))))...,
 	)
 
 	tr.POST(
 		"/users/:id/like/:song_name",
 		handler.LikeSongHandler(c.userService),
-		handler.NewLikeSongHandlerOption(opts)...,
+		handler.NewLikeSongHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.like", auth.PathOwner("id"))))...,
 	)
 
 	tr.DELETE(
 		"/users/:id/unlike/:song_name",
 		handler.UnlikeSongHandler(c.userService),
-		handler.NewUnlikeSongHandlerOption(opts)...,
+		handler.NewUnlikeSongHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.unlike", auth.PathOwner("id"))))...,
 	)
 
 	tr.GET(
 		"/users/:id/liked_songs",
 		handler.GetLikedSongsHandler(c.userService),
-		handler.NewGetLikedSongsHandlerOption(opts)...,
+		handler.NewGetLikedSongsHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.liked_songs", auth.PathOwner("id"))))...,
+	)
+
+	tr.POST(
+		"/users/:id/dislike/:song_name",
+		handler.DislikeSongHandler(c.userService),
+		handler.NewDislikeSongHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.dislike", auth.PathOwner("id"))))...,
+	)
+
+	tr.DELETE(
+		"/users/:id/undislike/:song_name",
+		handler.UndislikeSongHandler(c.userService),
+		handler.NewUndislikeSongHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.undislike", auth.PathOwner("id"))))...,
+	)
+
+	tr.POST(
+		"/users/:id/hide_artist/:artist",
+		handler.HideArtistHandler(c.userService),
+		handler.NewHideArtistHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.hide_artist", auth.PathOwner("id"))))...,
+	)
+
+	tr.DELETE(
+		"/users/:id/unhide_artist/:artist",
+		handler.UnhideArtistHandler(c.userService),
+		handler.NewUnhideArtistHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.unhide_artist", auth.PathOwner("id"))))...,
+	)
+
+	tr.GET(
+		"/users/:id/negative_feedback",
+		handler.GetNegativeFeedbackHandler(c.userService),
+		handler.NewGetNegativeFeedbackHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.negative_feedback", auth.PathOwner("id"))))...,
+	)
+
+	tr.GET(
+		"/users/:id/export",
+		handler.ExportUserHandler(c.privacyService),
+		handler.NewExportUserHandlerOption(withPolicy(opts, c.authorizer, auth.RequireOwner("users.export", auth.PathOwner("id"))))...,
 	)
 }
@@ -0,0 +1,156 @@
+// Package experiment buckets users into A/B test variants and records the
+// impressions and outcomes needed to compare them.
+package experiment
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"os"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ConfigKey is the Redis key holding the experiment configuration when no
+// file is configured
+const ConfigKey = "experiments:config"
+
+type (
+	Variant struct {
+		Name    string             `json:"name"`
+		Traffic int                `json:"traffic"`           // Relative share of users
+		Weights map[string]float64 `json:"weights,omitempty"` // Recommender source weights
+	}
+
+	Experiment struct {
+		Name     string    `json:"name"`
+		Variants []Variant `json:"variants"`
+	}
+
+	Config struct {
+		Experiments []Experiment `json:"experiments"`
+	}
+
+	Assignment struct {
+		Experiment string
+		Variant    *Variant
+	}
+)
+
+// LoadFile reads the configuration from a JSON file
+func LoadFile(path string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parse(data)
+}
+
+// LoadRedis reads the configuration stored as JSON under ConfigKey. A
+// missing key yields an empty configuration.
+func LoadRedis(redisClient redis.UniversalClient) (*Config, error) {
+	data, err := redisClient.Get(context.Background(), ConfigKey).Bytes()
+	if err == redis.Nil {
+		return &Config{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return parse(data)
+}
+
+// Load reads the file at path, or Redis when no path is given
+func Load(path string, redisClient redis.UniversalClient) (*Config, error) {
+	if path != "" {
+		return LoadFile(path)
+	}
+	return LoadRedis(redisClient)
+}
+
+func parse(data []byte) (*Config, error) {
+	var cfg Config
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return nil, err
+	}
+	if err := cfg.validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+func (c *Config) validate() error {
+	seen := make(map[string]struct{}, len(c.Experiments))
+	for _, e := range c.Experiments {
+		if e.Name == "" {
+			return fmt.Errorf("experiment without a name")
+		}
+		if _, ok := seen[e.Name]; ok {
+			return fmt.Errorf("experiment %q is defined twice", e.Name)
+		}
+		seen[e.Name] = struct{}{}
+
+		total := 0
+		for _, v := range e.Variants {
+			if v.Name == "" || v.Traffic < 0 {
+				return fmt.Errorf("experiment %q has a variant without a name or with negative traffic", e.Name)
+			}
+			if err := validateWeights(v.Weights); err != nil {
+				return fmt.Errorf("experiment %q variant %q: %v", e.Name, v.Name, err)
+			}
+			total += v.Traffic
+		}
+		if total == 0 {
+			return fmt.Errorf("experiment %q has no traffic", e.Name)
+		}
+	}
+	return nil
+}
+
+// validateWeights holds variant weights to the rule of
+// recommender.ParseWeights, and rejects weights that switch off every source
+// they name
+func validateWeights(weights map[string]float64) error {
+	total := 0.0
+	for source, weight := range weights {
+		if math.IsNaN(weight) || math.IsInf(weight, 0) || weight < 0 {
+			return fmt.Errorf("weight for %q must be a finite number, zero or more", source)
+		}
+		total += weight
+	}
+	if len(weights) > 0 && total == 0 {
+		return fmt.Errorf("weights must not all be zero")
+	}
+	return nil
+}
+
+// Assign deterministically maps a user onto one variant. The experiment name
+// is part of the hash so that buckets are independent across experiments.
+func (e *Experiment) Assign(userID string) *Variant {
+	total := 0
+	for _, v := range e.Variants {
+		total += v.Traffic
+	}
+
+	h := fnv.New32a()
+	h.Write([]byte(e.Name + "/" + userID))
+	bucket := int(h.Sum32() % uint32(total))
+
+	for i := range e.Variants {
+		bucket -= e.Variants[i].Traffic
+		if bucket < 0 {
+			return &e.Variants[i]
+		}
+	}
+	return nil // Unreachable while validate holds
+}
+
+func (c *Config) experiment(name string) *Experiment {
+	for i := range c.Experiments {
+		if c.Experiments[i].Name == name {
+			return &c.Experiments[i]
+		}
+	}
+	return nil
+}
@@ -0,0 +1,30 @@
+package experiment
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseRejectsBadConfigs(t *testing.T) {
+	tests := map[string]struct {
+		config string
+		want   string
+	}{
+		"no name":          {config: `{"experiments":[{"variants":[{"name":"a","traffic":1}]}]}`, want: "without a name"},
+		"no traffic":       {config: `{"experiments":[{"name":"e","variants":[{"name":"a","traffic":0}]}]}`, want: "has no traffic"},
+		"negative weight":  {config: `{"experiments":[{"name":"e","variants":[{"name":"a","traffic":1,"weights":{"popularity":-1}}]}]}`, want: `weight for "popularity" must be a finite number`},
+		"all zero weights": {config: `{"experiments":[{"name":"e","variants":[{"name":"a","traffic":1,"weights":{"popularity":0,"co_likes":0}}]}]}`, want: "must not all be zero"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := parse([]byte(test.config))
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("err = %v, want it to mention %q", err, test.want)
+			}
+		})
+	}
+
+	if _, err := parse([]byte(`{"experiments":[{"name":"e","variants":[{"name":"a","traffic":1,"weights":{"popularity":0,"co_likes":1}}]}]}`)); err != nil {
+		t.Errorf("weights switching off one source were rejected: %v", err)
+	}
+}
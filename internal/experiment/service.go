@@ -0,0 +1,294 @@
+package experiment
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"music-store/internal/model"
+	"music-store/internal/tenant"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// Event types
+const (
+	EventImpression = "impression"
+	EventClick      = "click"
+	EventLike       = "like"
+	EventPlay       = "play"
+)
+
+const (
+	// Outcomes only count towards a variant when the song was recommended
+	// to the user within this window
+	attributionWindow = 24 * time.Hour
+
+	queueSize = 1024
+)
+
+var (
+	ErrUnknownExperiment = errors.New("unknown experiment")
+	ErrUnknownEventType  = errors.New("unknown event type")
+)
+
+type Service interface {
+	Assign(userID string) []*Assignment
+	LogImpressions(ctx context.Context, userID string, assignments []*Assignment, songNames []string)
+	TrackEvent(ctx context.Context, event *model.TrackEventRequest) (string, error)
+	GetSummary(ctx context.Context, name string) (*model.GetExperimentSummaryResponse, error)
+	// ExportUser returns what is held about a user. Variant counters are
+	// aggregates and not part of it.
+	ExportUser(ctx context.Context, userID string) (*model.ExperimentUserData, error)
+	// ForgetUser removes the attribution state of a user
+	ForgetUser(ctx context.Context, userID string) (int, error)
+	// Close stops accepting events and waits until the queued ones are written
+	Close() error
+}
+
+// event is the unit of work for the background writer and also the shape
+// of the logged event line
+type event struct {
+	Type        string                        `json:"type"`
+	Tenant      string                        `json:"tenant,omitempty"`
+	UserID      string                        `json:"user_id"`
+	SongNames   []string                      `json:"song_names"`
+	Assignments []*model.ExperimentAssignment `json:"assignments"`
+	Timestamp   time.Time                     `json:"timestamp"`
+}
+
+type experimentService struct {
+	config      *Config
+	redisClient redis.UniversalClient
+
+	mu     sync.RWMutex
+	closed bool
+	events chan *event
+	done   chan struct{}
+}
+
+func NewService(config *Config, redisClient redis.UniversalClient) Service {
+	s := &experimentService{
+		config:      config,
+		redisClient: redisClient,
+		events:      make(chan *event, queueSize),
+		done:        make(chan struct{}),
+	}
+	go s.run()
+	return s
+}
+
+func (s *experimentService) Assign(userID string) []*Assignment {
+	assignments := make([]*Assignment, 0, len(s.config.Experiments))
+	for i := range s.config.Experiments {
+		e := &s.config.Experiments[i]
+		assignments = append(assignments, &Assignment{Experiment: e.Name, Variant: e.Assign(userID)})
+	}
+	return assignments
+}
+
+func (s *experimentService) LogImpressions(ctx context.Context, userID string, assignments []*Assignment, songNames []string) {
+	if len(assignments) == 0 || len(songNames) == 0 {
+		return
+	}
+	s.enqueue(&event{
+		Type:        EventImpression,
+		Tenant:      tenant.ID(ctx),
+		UserID:      userID,
+		SongNames:   songNames,
+		Assignments: ToModel(assignments),
+		Timestamp:   time.Now().UTC(),
+	})
+}
+
+func (s *experimentService) TrackEvent(ctx context.Context, req *model.TrackEventRequest) (string, error) {
+	switch req.Type {
+	case EventClick, EventLike, EventPlay:
+	default:
+		return "Error tracking event", errors.Wrap(ErrUnknownEventType, req.Type)
+	}
+
+	s.enqueue(&event{
+		Type:        req.Type,
+		Tenant:      tenant.ID(ctx),
+		UserID:      req.UserID,
+		SongNames:   []string{req.SongName},
+		Assignments: ToModel(s.Assign(req.UserID)),
+		Timestamp:   time.Now().UTC(),
+	})
+	return "success", nil
+}
+
+func (s *experimentService) GetSummary(ctx context.Context, name string) (*model.GetExperimentSummaryResponse, error) {
+	e := s.config.experiment(name)
+	if e == nil {
+		return nil, errors.Wrap(ErrUnknownExperiment, name)
+	}
+
+	resp := &model.GetExperimentSummaryResponse{Experiment: e.Name}
+	for _, v := range e.Variants {
+		counts, err := s.redisClient.HGetAll(ctx, countersKey(tenant.ID(ctx), e.Name, v.Name)).Result()
+		if err != nil {
+			return nil, err
+		}
+
+		summary := &model.VariantSummary{Variant: v.Name}
+		for field, target := range map[string]*int64{
+			EventImpression: &summary.Impressions,
+			EventClick:      &summary.Clicks,
+			EventLike:       &summary.Likes,
+			EventPlay:       &summary.Plays,
+		} {
+			fmt.Sscan(counts[field], target)
+		}
+		if summary.Impressions > 0 {
+			impressions := float64(summary.Impressions)
+			summary.ClickThroughRate = float64(summary.Clicks) / impressions
+			summary.LikeThroughRate = float64(summary.Likes) / impressions
+			summary.PlayThroughRate = float64(summary.Plays) / impressions
+		}
+		resp.Variants = append(resp.Variants, summary)
+	}
+	return resp, nil
+}
+
+func (s *experimentService) ExportUser(ctx context.Context, userID string) (*model.ExperimentUserData, error) {
+	shown, err := s.redisClient.SMembers(ctx, seenKey(tenant.ID(ctx), userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	outcomes, err := s.redisClient.SMembers(ctx, outcomesKey(tenant.ID(ctx), userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	return &model.ExperimentUserData{Assignments: ToModel(s.Assign(userID)), RecentlyShown: shown, RecentOutcomes: outcomes}, nil
+}
+
+func (s *experimentService) ForgetUser(ctx context.Context, userID string) (int, error) {
+	// One DEL per key, they may hash to different slots in cluster mode
+	var removed int
+	for _, key := range []string{seenKey(tenant.ID(ctx), userID), outcomesKey(tenant.ID(ctx), userID)} {
+		n, err := s.redisClient.Del(ctx, key).Result()
+		if err != nil {
+			return removed, err
+		}
+		removed += int(n)
+	}
+	return removed, nil
+}
+
+func (s *experimentService) Close() error {
+	s.mu.Lock()
+	if !s.closed {
+		s.closed = true
+		close(s.events)
+	}
+	s.mu.Unlock()
+
+	<-s.done
+	return nil
+}
+
+// enqueue hands the event to the background writer without blocking the
+// request. Events are dropped when the queue is full.
+func (s *experimentService) enqueue(e *event) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.closed {
+		return
+	}
+
+	select {
+	case s.events <- e:
+	default:
+		slog.Warn("experiment event queue full, dropping the event", "type", e.Type, "user", e.UserID)
+	}
+}
+
+func (s *experimentService) run() {
+	defer close(s.done)
+	for e := range s.events {
+		if err := s.write(e); err != nil {
+			slog.Error("failed to record experiment event", "type", e.Type, "error", err)
+		}
+	}
+}
+
+func (s *experimentService) write(e *event) error {
+	slog.Info("experiment event", "event", e)
+
+	ctx := context.Background()
+	seenKey := seenKey(e.Tenant, e.UserID)
+
+	if e.Type == EventImpression {
+		_, err := s.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+			for _, a := range e.Assignments {
+				pipe.HIncrBy(ctx, countersKey(e.Tenant, a.Experiment, a.Variant), EventImpression, int64(len(e.SongNames)))
+			}
+			members := make([]interface{}, len(e.SongNames))
+			for i, name := range e.SongNames {
+				members[i] = name
+			}
+			pipe.SAdd(ctx, seenKey, members...)
+			pipe.Expire(ctx, seenKey, attributionWindow)
+			return nil
+		})
+		return err
+	}
+
+	// Only attribute outcomes for songs the user was actually shown
+	seen, err := s.redisClient.SIsMember(ctx, seenKey, e.SongNames[0]).Result()
+	if err != nil || !seen {
+		return err
+	}
+
+	// and only once per variant, a song played ten times is one play
+	outcomesKey := outcomesKey(e.Tenant, e.UserID)
+	added := make([]*redis.IntCmd, len(e.Assignments))
+	_, err = s.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, a := range e.Assignments {
+			added[i] = pipe.SAdd(ctx, outcomesKey, fmt.Sprintf("%s:%s:%s:%s", e.Type, a.Experiment, a.Variant, e.SongNames[0]))
+		}
+		pipe.Expire(ctx, outcomesKey, attributionWindow)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	_, err = s.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, a := range e.Assignments {
+			if added[i].Val() == 1 {
+				pipe.HIncrBy(ctx, countersKey(e.Tenant, a.Experiment, a.Variant), e.Type, 1)
+			}
+		}
+		return nil
+	})
+	return err
+}
+
+// Experiments are configured once, but the attribution state and the
+// counters are kept per tenant
+func seenKey(tenantID, userID string) string {
+	return tenant.Prefix(tenantID) + fmt.Sprintf("experiment:seen:%s", userID)
+}
+
+// outcomesKey holds the outcomes already counted for a user, as
+// <type>:<experiment>:<variant>:<song>
+func outcomesKey(tenantID, userID string) string {
+	return tenant.Prefix(tenantID) + fmt.Sprintf("experiment:outcomes:%s", userID)
+}
+
+func countersKey(tenantID, experiment, variant string) string {
+	return tenant.Prefix(tenantID) + fmt.Sprintf("experiment:%s:%s", experiment, variant)
+}
+
+// ToModel converts assignments into their API representation
+func ToModel(assignments []*Assignment) []*model.ExperimentAssignment {
+	out := make([]*model.ExperimentAssignment, 0, len(assignments))
+	for _, a := range assignments {
+		out = append(out, &model.ExperimentAssignment{Experiment: a.Experiment, Variant: a.Variant.Name})
+	}
+	return out
+}
@@ -0,0 +1,48 @@
+package experiment
+
+import (
+	"context"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"music-store/internal/model"
+)
+
+func TestOutcomesCountOncePerSong(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	config := &Config{Experiments: []Experiment{{Name: "ranking", Variants: []Variant{{Name: "control", Traffic: 1}}}}}
+	s := NewService(config, redisClient)
+	ctx := context.Background()
+
+	s.LogImpressions(ctx, "u1", s.Assign("u1"), []string{"a", "b"})
+	for _, req := range []*model.TrackEventRequest{
+		{UserID: "u1", SongName: "a", Type: EventLike},
+		{UserID: "u1", SongName: "a", Type: EventLike},
+		{UserID: "u1", SongName: "a", Type: EventPlay},
+		{UserID: "u1", SongName: "a", Type: EventPlay},
+		{UserID: "u1", SongName: "b", Type: EventPlay},
+	} {
+		if _, err := s.TrackEvent(ctx, req); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s.Close()
+
+	summary, err := s.GetSummary(ctx, "ranking")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := summary.Variants[0]
+	if got.Impressions != 2 || got.Likes != 1 || got.Plays != 2 {
+		t.Errorf("impressions, likes, plays = %d, %d, %d, want 2, 1, 2", got.Impressions, got.Likes, got.Plays)
+	}
+
+	if n, err := s.ForgetUser(ctx, "u1"); err != nil || n != 2 {
+		t.Errorf("ForgetUser = %d, %v, want both keys removed", n, err)
+	}
+}
@@ -0,0 +1,25 @@
+package experiment
+
+import (
+	"context"
+	"music-store/internal/model"
+	"music-store/internal/service"
+)
+
+// likeTrackingUserService reports successful likes as experiment outcomes
+type likeTrackingUserService struct {
+	service.UserService
+	experiments Service
+}
+
+func NewLikeTrackingUserService(next service.UserService, experiments Service) service.UserService {
+	return &likeTrackingUserService{UserService: next, experiments: experiments}
+}
+
+func (s *likeTrackingUserService) LikeSong(ctx context.Context, userID, songName string) (string, error) {
+	msg, err := s.UserService.LikeSong(ctx, userID, songName)
+	if err == nil && msg == "success" {
+		s.experiments.TrackEvent(ctx, &model.TrackEventRequest{UserID: userID, SongName: songName, Type: EventLike})
+	}
+	return msg, err
+}
@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"music-store/internal/audit"
+	"music-store/internal/model"
+	net_http "net/http"
+	"strconv"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/unbxd/go-base/kit/endpoint"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+func MakeGetAuditLogEndpoint(l audit.Log) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.GetAuditLogRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to GetAuditLogRequest",
+			)
+		}
+		resp, err := l.Query(ctx, &req)
+		if err != nil {
+			return model.GetAuditLogResponse{Err: err}, nil
+		}
+		return *resp, nil
+	}
+}
+
+func GetAuditLogHandler(l audit.Log) http.Handler {
+	return http.Handler(MakeGetAuditLogEndpoint(l))
+}
+
+func NewGetAuditLogHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(GetAuditLogDecoderFunc),
+		http.HandlerWithEncoder(GetAuditLogEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+// GetAuditLogDecoderFunc accepts since as an RFC 3339 time or as a duration
+// back from now, e.g. since=24h
+func GetAuditLogDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	query := r.URL.Query()
+	req := model.GetAuditLogRequest{Target: query.Get("target"), Actor: query.Get("actor")}
+
+	if since := query.Get("since"); since != "" {
+		if t, err := time.Parse(time.RFC3339, since); err == nil {
+			req.Since = t
+		} else if d, err := time.ParseDuration(since); err == nil {
+			req.Since = time.Now().Add(-d)
+		} else {
+			return nil, errors.Wrap(errBadRequest, "since must be an RFC 3339 time or a duration")
+		}
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			return nil, errors.Wrap(errBadRequest, "limit must be a positive integer")
+		}
+		req.Limit = n
+	}
+	return req, nil
+}
+
+func GetAuditLogEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"music-store/internal/model"
+	"music-store/internal/repository"
+	"music-store/internal/service"
+	net_http "net/http"
+
+	"github.com/pkg/errors"
+	"github.com/unbxd/go-base/kit/endpoint"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+// Auth endpoints return their errors instead of embedding them in the
+// response, clients need the status code to tell a bad password from an outage
+
+func MakeSignupEndpoint(s service.AuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.SignupRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to SignupRequest",
+			)
+		}
+		resp, err := s.Signup(ctx, &req)
+		if err != nil {
+			return nil, err
+		}
+		return *resp, nil
+	}
+}
+
+func MakeLoginEndpoint(s service.AuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.LoginRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to LoginRequest",
+			)
+		}
+		tokens, err := s.Login(ctx, &req)
+		if err != nil {
+			return nil, err
+		}
+		return model.LoginResponse{Tokens: tokens}, nil
+	}
+}
+
+func MakeRefreshTokenEndpoint(s service.AuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.RefreshTokenRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to RefreshTokenRequest",
+			)
+		}
+		tokens, err := s.Refresh(ctx, &req)
+		if err != nil {
+			return nil, err
+		}
+		return model.RefreshTokenResponse{Tokens: tokens}, nil
+	}
+}
+
+func MakeLogoutEndpoint(s service.AuthService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.LogoutRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to LogoutRequest",
+			)
+		}
+		msg, err := s.Logout(ctx, &req)
+		if err != nil {
+			return nil, err
+		}
+		return model.LogoutResponse{Msg: msg}, nil
+	}
+}
+
+func SignupHandler(service service.AuthService) http.Handler {
+	return http.Handler(MakeSignupEndpoint(service))
+}
+
+func LoginHandler(service service.AuthService) http.Handler {
+	return http.Handler(MakeLoginEndpoint(service))
+}
+
+func RefreshTokenHandler(service service.AuthService) http.Handler {
+	return http.Handler(MakeRefreshTokenEndpoint(service))
+}
+
+func LogoutHandler(service service.AuthService) http.Handler {
+	return http.Handler(MakeLogoutEndpoint(service))
+}
+
+func NewSignupHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(SignupDecoderFunc),
+		http.HandlerWithEncoder(authEncoderFunc),
+		http.HandlerWithErrorEncoder(authErrorEncoder),
+	}, opts...)
+}
+
+func NewLoginHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(LoginDecoderFunc),
+		http.HandlerWithEncoder(authEncoderFunc),
+		http.HandlerWithErrorEncoder(authErrorEncoder),
+	}, opts...)
+}
+
+func NewRefreshTokenHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(RefreshTokenDecoderFunc),
+		http.HandlerWithEncoder(authEncoderFunc),
+		http.HandlerWithErrorEncoder(authErrorEncoder),
+	}, opts...)
+}
+
+func NewLogoutHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(LogoutDecoderFunc),
+		http.HandlerWithEncoder(authEncoderFunc),
+		http.HandlerWithErrorEncoder(authErrorEncoder),
+	}, opts...)
+}
+
+func SignupDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	var req model.SignupRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.Wrap(errBadRequest, err.Error())
+	}
+	return req, nil
+}
+
+func LoginDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	var req model.LoginRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.Wrap(errBadRequest, err.Error())
+	}
+	return req, nil
+}
+
+func RefreshTokenDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	var req model.RefreshTokenRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.Wrap(errBadRequest, err.Error())
+	}
+	return req, nil
+}
+
+func LogoutDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	var req model.LogoutRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.Wrap(errBadRequest, err.Error())
+	}
+	return req, nil
+}
+
+func authEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func authErrorEncoder(ctx context.Context, err error, w net_http.ResponseWriter) {
+	var code int
+	switch errors.Cause(err) {
+	case service.ErrInvalidSignup:
+		code = net_http.StatusBadRequest
+	case repository.ErrUsernameTaken:
+		code = net_http.StatusConflict
+	default:
+		errorEncoder(ctx, err, w)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
+}
@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"music-store/internal/experiment"
+	"music-store/internal/model"
+	net_http "net/http"
+
+	"github.com/pkg/errors"
+	"github.com/unbxd/go-base/kit/endpoint"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+func MakeTrackEventEndpoint(s experiment.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.TrackEventRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to TrackEventRequest",
+			)
+		}
+		msg, err := s.TrackEvent(ctx, &req)
+		return model.TrackEventResponse{Msg: msg, Err: err}, nil
+	}
+}
+
+func MakeGetExperimentSummaryEndpoint(s experiment.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.GetExperimentSummaryRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to GetExperimentSummaryRequest",
+			)
+		}
+		summary, err := s.GetSummary(ctx, req.Name)
+		if err != nil {
+			return model.GetExperimentSummaryResponse{Err: err}, nil
+		}
+		return *summary, nil
+	}
+}
+
+func TrackEventHandler(service experiment.Service) http.Handler {
+	return http.Handler(MakeTrackEventEndpoint(service))
+}
+
+func GetExperimentSummaryHandler(service experiment.Service) http.Handler {
+	return http.Handler(MakeGetExperimentSummaryEndpoint(service))
+}
+
+func NewTrackEventHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(TrackEventDecoderFunc),
+		http.HandlerWithEncoder(TrackEventEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+func NewGetExperimentSummaryHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(GetExperimentSummaryDecoderFunc),
+		http.HandlerWithEncoder(GetExperimentSummaryEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+func TrackEventDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	var req model.TrackEventRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.Wrap(errBadRequest, err.Error())
+	}
+	return req, nil
+}
+
+func GetExperimentSummaryDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	return model.GetExperimentSummaryRequest{Name: http.Parameters(r).ByName("name")}, nil
+}
+
+func TrackEventEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func GetExperimentSummaryEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,99 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"music-store/internal/model"
+	"music-store/internal/service/onboarding"
+	net_http "net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/unbxd/go-base/kit/endpoint"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+func MakeGetOnboardingPicksEndpoint(s onboarding.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.GetOnboardingPicksRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to GetOnboardingPicksRequest",
+			)
+		}
+		picks, err := s.GetPicks(ctx, &req)
+		if err != nil {
+			return model.GetOnboardingPicksResponse{Err: err}, nil
+		}
+		return *picks, nil
+	}
+}
+
+func MakeOnboardUserEndpoint(s onboarding.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.OnboardUserRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to OnboardUserRequest",
+			)
+		}
+		msg, err := s.Onboard(ctx, &req)
+		return model.OnboardUserResponse{Msg: msg, Err: err}, nil
+	}
+}
+
+func GetOnboardingPicksHandler(service onboarding.Service) http.Handler {
+	return http.Handler(MakeGetOnboardingPicksEndpoint(service))
+}
+
+func OnboardUserHandler(service onboarding.Service) http.Handler {
+	return http.Handler(MakeOnboardUserEndpoint(service))
+}
+
+func NewGetOnboardingPicksHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(GetOnboardingPicksDecoderFunc),
+		http.HandlerWithEncoder(GetOnboardingPicksEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+func NewOnboardUserHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(OnboardUserDecoderFunc),
+		http.HandlerWithEncoder(OnboardUserEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+func GetOnboardingPicksDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	req := model.GetOnboardingPicksRequest{}
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, errors.Wrap(errBadRequest, "limit must be an integer")
+		}
+		req.Limit = n
+	}
+	return req, nil
+}
+
+func OnboardUserDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	var req model.OnboardUserRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		return nil, errors.Wrap(errBadRequest, err.Error())
+	}
+	// Get ID from path parameter
+	req.UserID = http.Parameters(r).ByName("id")
+	return req, nil
+}
+
+func GetOnboardingPicksEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func OnboardUserEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
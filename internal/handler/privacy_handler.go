@@ -0,0 +1,89 @@
+package handler
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"fmt"
+	"music-store/internal/model"
+	"music-store/internal/privacy"
+	net_http "net/http"
+
+	"github.com/pkg/errors"
+	"github.com/unbxd/go-base/kit/endpoint"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+func MakeExportUserEndpoint(p privacy.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.ExportUserRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to ExportUserRequest",
+			)
+		}
+		export, err := p.Export(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		return export, nil
+	}
+}
+
+func ExportUserHandler(privacy privacy.Service) http.Handler {
+	return http.Handler(MakeExportUserEndpoint(privacy))
+}
+
+func NewExportUserHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(ExportUserDecoderFunc),
+		http.HandlerWithEncoder(ExportUserEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+func ExportUserDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	return model.ExportUserRequest{ID: http.Parameters(r).ByName("id")}, nil
+}
+
+// ExportUserEncoderFunc writes a zip archive with a manifest and one JSON
+// file per section
+func ExportUserEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	export, ok := response.(*model.UserExport)
+	if !ok {
+		return errors.New("unexpected export response")
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="user-%s-export.zip"`, export.UserID))
+	w.Header().Set("Cache-Control", "no-store")
+
+	archive := zip.NewWriter(w)
+	names := make([]string, 0, len(export.Sections))
+	for _, section := range export.Sections {
+		names = append(names, section.Name)
+		if err := writeZipJSON(archive, section.Name+".json", section.Data); err != nil {
+			return err
+		}
+	}
+
+	manifest := map[string]interface{}{
+		"user_id":     export.UserID,
+		"exported_at": export.ExportedAt,
+		"sections":    names,
+	}
+	if err := writeZipJSON(archive, "manifest.json", manifest); err != nil {
+		return err
+	}
+	return archive.Close()
+}
+
+func writeZipJSON(archive *zip.Writer, name string, v interface{}) error {
+	f, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	encoder := json.NewEncoder(f)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
@@ -0,0 +1,90 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"music-store/internal/model"
+	"music-store/internal/service/recommender"
+	net_http "net/http"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/unbxd/go-base/kit/endpoint"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+func MakeGetRecommendationsEndpoint(s recommender.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.GetRecommendationsRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to GetRecommendationsRequest",
+			)
+		}
+		recs, err := s.Recommend(ctx, &req)
+		if err != nil {
+			return model.GetRecommendationsResponse{Err: err}, nil
+		}
+		return model.GetRecommendationsResponse{Recommendations: recs.Recommendations, Experiments: recs.Experiments}, nil
+	}
+}
+
+func GetRecommendationsHandler(service recommender.Service) http.Handler {
+	return http.Handler(MakeGetRecommendationsEndpoint(service))
+}
+
+func NewGetRecommendationsHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(GetRecommendationsDecoderFunc),
+		http.HandlerWithEncoder(GetRecommendationsEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+// GetRecommendationsDecoderFunc reads the optional query parameters
+// limit, debug and weights, e.g. ?weights=embedding_knn:1,popularity:0.5
+func GetRecommendationsDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	req := model.GetRecommendationsRequest{UserID: http.Parameters(r).ByName("id")}
+	query := r.URL.Query()
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			return nil, errors.Wrap(errBadRequest, "limit must be an integer")
+		}
+		req.Limit = n
+	}
+
+	req.Debug, _ = strconv.ParseBool(query.Get("debug"))
+
+	if weights := query.Get("weights"); weights != "" {
+		parsed, err := recommender.ParseWeights(weights)
+		if err != nil {
+			return nil, errors.Wrap(errBadRequest, err.Error())
+		}
+		req.Weights = parsed
+	}
+	return req, nil
+}
+
+func GetRecommendationsEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	if resp, ok := response.(model.GetRecommendationsResponse); ok {
+		setExperimentHeader(w, resp.Experiments)
+	}
+	return json.NewEncoder(w).Encode(response)
+}
+
+// setExperimentHeader exposes the assigned variants as
+// X-Experiment-Variant: experiment=variant, other=variant
+func setExperimentHeader(w net_http.ResponseWriter, assignments []*model.ExperimentAssignment) {
+	if len(assignments) == 0 {
+		return
+	}
+	pairs := make([]string, len(assignments))
+	for i, a := range assignments {
+		pairs[i] = a.Experiment + "=" + a.Variant
+	}
+	w.Header().Set("X-Experiment-Variant", strings.Join(pairs, ", "))
+}
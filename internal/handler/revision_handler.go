@@ -0,0 +1,88 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"music-store/internal/model"
+	"music-store/internal/revision"
+	net_http "net/http"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/unbxd/go-base/kit/endpoint"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+func MakeGetSongRevisionsEndpoint(s revision.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.GetSongRevisionsRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to GetSongRevisionsRequest",
+			)
+		}
+		resp, err := s.GetRevisions(ctx, req.Name)
+		if err != nil {
+			return model.GetSongRevisionsResponse{Err: err}, nil
+		}
+		return *resp, nil
+	}
+}
+
+func MakeRevertSongEndpoint(s revision.Service) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.RevertSongRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to RevertSongRequest",
+			)
+		}
+		msg, err := s.Revert(ctx, req.Name, req.Rev)
+		if err != nil {
+			return nil, err
+		}
+		return model.RevertSongResponse{Msg: msg}, nil
+	}
+}
+
+func GetSongRevisionsHandler(s revision.Service) http.Handler {
+	return http.Handler(MakeGetSongRevisionsEndpoint(s))
+}
+
+func RevertSongHandler(s revision.Service) http.Handler {
+	return http.Handler(MakeRevertSongEndpoint(s))
+}
+
+func NewGetSongRevisionsHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(GetSongRevisionsDecoderFunc),
+		http.HandlerWithEncoder(RevisionEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+func NewRevertSongHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(RevertSongDecoderFunc),
+		http.HandlerWithEncoder(RevisionEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+func GetSongRevisionsDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	return model.GetSongRevisionsRequest{Name: http.Parameters(r).ByName("name")}, nil
+}
+
+func RevertSongDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	params := http.Parameters(r)
+	rev, err := strconv.ParseInt(params.ByName("rev"), 10, 64)
+	if err != nil || rev < 1 {
+		return nil, errors.Wrap(errBadRequest, "rev must be a positive integer")
+	}
+	return model.RevertSongRequest{Name: params.ByName("name"), Rev: rev}, nil
+}
+
+func RevisionEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
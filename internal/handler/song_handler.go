@@ -3,6 +3,7 @@ package handler
 import (
 	"context"
 	"encoding/json"
+	"music-store/internal/api"
 	"music-store/internal/model"
 	"music-store/internal/service"
 	net_http "net/http"
@@ -13,7 +14,8 @@ import (
 )
 
 var (
-	errBadRequest = errors.New("bad request")
+	// errBadRequest is answered with 400 by every error encoder
+	errBadRequest = api.NewError(net_http.StatusBadRequest, "bad request")
 )
 
 func MakeCreateSongEndpoint(s service.SongService) endpoint.Endpoint {
@@ -38,14 +40,20 @@ func MakeGetSongEndpoint(s service.SongService) endpoint.Endpoint {
 			)
 		}
 		song, err := s.GetSong(ctx, req.Name)
-		return model.GetSongResponse{Song: song.Song, Err: err}, nil
+		if err != nil {
+			return model.GetSongResponse{Song: nil, Err: err}, nil
+		}
+		return model.GetSongResponse{Song: song.Song, Err: nil}, nil
 	}
 }
 
 func MakeGetAllSongsEndpoint(s service.SongService) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		songs, err := s.GetAllSongs(ctx)
-		return model.GetSongListResponse{Songs: songs.Songs, Err: err}, nil
+		if err != nil {
+			return model.GetSongListResponse{Songs: nil, Err: err}, nil
+		}
+		return model.GetSongListResponse{Songs: songs.Songs, Err: nil}, nil
 	}
 }
 
@@ -138,7 +146,7 @@ func NewDeleteSongHandlerOption(opts []http.HandlerOption) []http.HandlerOption
 func CreateSongDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
 	var req model.CreateSongRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, err
+		return nil, errors.Wrap(errBadRequest, err.Error())
 	}
 	return req, nil
 }
@@ -154,7 +162,7 @@ func GetAllSongsDecoderFunc(ctx context.Context, r *net_http.Request) (interface
 func UpdateSongDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
 	var req model.UpdateSongRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, err
+		return nil, errors.Wrap(errBadRequest, err.Error())
 	}
 	// Extract the name from path parameter
 	name := http.Parameters(r).ByName("name")
@@ -196,9 +204,19 @@ func DeleteSongEncoderFunc(ctx context.Context, w net_http.ResponseWriter, respo
 	return json.NewEncoder(w).Encode(response)
 }
 
+// statusCoder is implemented by errors that map onto a specific HTTP status
+type statusCoder interface {
+	StatusCode() int
+}
+
 // Error encoder
 func errorEncoder(ctx context.Context, err error, w net_http.ResponseWriter) {
+	code := net_http.StatusInternalServerError
+	if sc, ok := errors.Cause(err).(statusCoder); ok {
+		code = sc.StatusCode()
+	}
+
 	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(net_http.StatusInternalServerError)
+	w.WriteHeader(code)
 	json.NewEncoder(w).Encode(map[string]string{"error": err.Error()})
 }
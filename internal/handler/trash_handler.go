@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"music-store/internal/model"
+	"music-store/internal/service"
+	net_http "net/http"
+
+	"github.com/pkg/errors"
+	"github.com/unbxd/go-base/kit/endpoint"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+const (
+	TrashSongs = "songs"
+	TrashUsers = "users"
+)
+
+func MakeRestoreSongEndpoint(s service.SongService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.RestoreSongRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to RestoreSongRequest",
+			)
+		}
+		msg, err := s.RestoreSong(ctx, req.Name)
+		if err != nil {
+			return nil, err
+		}
+		return model.RestoreSongResponse{Msg: msg}, nil
+	}
+}
+
+func MakeRestoreUserEndpoint(s service.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.RestoreUserRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to RestoreUserRequest",
+			)
+		}
+		msg, err := s.RestoreUser(ctx, req.ID)
+		if err != nil {
+			return nil, err
+		}
+		return model.RestoreUserResponse{Msg: msg}, nil
+	}
+}
+
+func MakeGetTrashEndpoint(songs service.SongService, users service.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.GetTrashRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to GetTrashRequest",
+			)
+		}
+
+		var resp *model.GetTrashResponse
+		var err error
+		if req.Kind == TrashSongs {
+			resp, err = songs.GetTrashedSongs(ctx)
+		} else {
+			resp, err = users.GetTrashedUsers(ctx)
+		}
+		if err != nil {
+			return model.GetTrashResponse{Err: err}, nil
+		}
+		return *resp, nil
+	}
+}
+
+func MakePurgeTrashEndpoint(songs service.SongService, users service.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.PurgeTrashRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to PurgeTrashRequest",
+			)
+		}
+
+		var msg string
+		var err error
+		if req.Kind == TrashSongs {
+			msg, err = songs.PurgeSong(ctx, req.Key)
+		} else {
+			msg, err = users.PurgeUser(ctx, req.Key)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return model.PurgeTrashResponse{Msg: msg}, nil
+	}
+}
+
+func RestoreSongHandler(service service.SongService) http.Handler {
+	return http.Handler(MakeRestoreSongEndpoint(service))
+}
+
+func RestoreUserHandler(service service.UserService) http.Handler {
+	return http.Handler(MakeRestoreUserEndpoint(service))
+}
+
+func GetTrashHandler(songs service.SongService, users service.UserService) http.Handler {
+	return http.Handler(MakeGetTrashEndpoint(songs, users))
+}
+
+func PurgeTrashHandler(songs service.SongService, users service.UserService) http.Handler {
+	return http.Handler(MakePurgeTrashEndpoint(songs, users))
+}
+
+func NewRestoreSongHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(RestoreSongDecoderFunc),
+		http.HandlerWithEncoder(TrashEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+func NewRestoreUserHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(RestoreUserDecoderFunc),
+		http.HandlerWithEncoder(TrashEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+// NewGetTrashHandlerOption lists the trash of one kind, songs or users
+func NewGetTrashHandlerOption(kind string, opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(func(ctx context.Context, r *net_http.Request) (interface{}, error) {
+			return model.GetTrashRequest{Kind: kind}, nil
+		}),
+		http.HandlerWithEncoder(TrashEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+// NewPurgeTrashHandlerOption purges one entry of a kind, keyed by the
+// path parameter param
+func NewPurgeTrashHandlerOption(kind, param string, opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(func(ctx context.Context, r *net_http.Request) (interface{}, error) {
+			return model.PurgeTrashRequest{Kind: kind, Key: http.Parameters(r).ByName(param)}, nil
+		}),
+		http.HandlerWithEncoder(TrashEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+func RestoreSongDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	return model.RestoreSongRequest{Name: http.Parameters(r).ByName("name")}, nil
+}
+
+func RestoreUserDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	return model.RestoreUserRequest{ID: http.Parameters(r).ByName("id")}, nil
+}
+
+func TrashEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
@@ -4,8 +4,10 @@ import (
 	"context"
 	"encoding/json"
 	"music-store/internal/model"
+	"music-store/internal/privacy"
 	"music-store/internal/service"
 	net_http "net/http"
+	"strconv"
 
 	"github.com/pkg/errors"
 	"github.com/unbxd/go-base/kit/endpoint"
@@ -64,7 +66,9 @@ func MakeUpdateUserEndpoint(s service.UserService) endpoint.Endpoint {
 	}
 }
 
-func MakeDeleteUserEndpoint(s service.UserService) endpoint.Endpoint {
+// MakeDeleteUserEndpoint erases the user through p when the request asks
+// for it, otherwise only the user record is deleted
+func MakeDeleteUserEndpoint(s service.UserService, p privacy.Service) endpoint.Endpoint {
 	return func(ctx context.Context, request interface{}) (interface{}, error) {
 		req, ok := request.(model.DeleteUserRequest)
 		if !ok {
@@ -72,6 +76,13 @@ func MakeDeleteUserEndpoint(s service.UserService) endpoint.Endpoint {
 				errBadRequest, "failed to cast object to DeleteUserRequest",
 			)
 		}
+		if req.Erase {
+			tombstone, err := p.Erase(ctx, req.ID)
+			if err != nil {
+				return nil, err
+			}
+			return model.DeleteUserResponse{Msg: "success", Tombstone: tombstone}, nil
+		}
 		msg, err := s.DeleteUser(ctx, req.ID)
 		return model.DeleteUserResponse{Msg: msg, Err: err}, nil
 	}
@@ -117,6 +128,74 @@ func MakeGetLikedSongsEndpoint(s service.UserService) endpoint.Endpoint {
 	}
 }
 
+func MakeDislikeSongEndpoint(s service.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.DislikeSongRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to DislikeSongRequest",
+			)
+		}
+		msg, err := s.DislikeSong(ctx, req.UserID, req.SongName)
+		return model.DislikeSongResponse{Msg: msg, Err: err}, nil
+	}
+}
+
+func MakeUndislikeSongEndpoint(s service.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.UndislikeSongRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to UndislikeSongRequest",
+			)
+		}
+		msg, err := s.UndislikeSong(ctx, req.UserID, req.SongName)
+		return model.UndislikeSongResponse{Msg: msg, Err: err}, nil
+	}
+}
+
+func MakeHideArtistEndpoint(s service.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.HideArtistRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to HideArtistRequest",
+			)
+		}
+		msg, err := s.HideArtist(ctx, req.UserID, req.Artist)
+		return model.HideArtistResponse{Msg: msg, Err: err}, nil
+	}
+}
+
+func MakeUnhideArtistEndpoint(s service.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.UnhideArtistRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to UnhideArtistRequest",
+			)
+		}
+		msg, err := s.UnhideArtist(ctx, req.UserID, req.Artist)
+		return model.UnhideArtistResponse{Msg: msg, Err: err}, nil
+	}
+}
+
+func MakeGetNegativeFeedbackEndpoint(s service.UserService) endpoint.Endpoint {
+	return func(ctx context.Context, request interface{}) (interface{}, error) {
+		req, ok := request.(model.GetNegativeFeedbackRequest)
+		if !ok {
+			return nil, errors.Wrap(
+				errBadRequest, "failed to cast object to GetNegativeFeedbackRequest",
+			)
+		}
+		feedback, err := s.GetNegativeFeedback(ctx, req.UserID)
+		if err != nil {
+			return model.GetNegativeFeedbackResponse{Err: err}, nil
+		}
+		return *feedback, nil
+	}
+}
+
 func CreateUserHandler(service service.UserService) http.Handler {
 	return http.Handler(MakeCreateUserEndpoint(service))
 }
@@ -133,8 +212,8 @@ func UpdateUserHandler(service service.UserService) http.Handler {
 	return http.Handler(MakeUpdateUserEndpoint(service))
 }
 
-func DeleteUserHandler(service service.UserService) http.Handler {
-	return http.Handler(MakeDeleteUserEndpoint(service))
+func DeleteUserHandler(service service.UserService, privacy privacy.Service) http.Handler {
+	return http.Handler(MakeDeleteUserEndpoint(service, privacy))
 }
 
 func LikeSongHandler(service service.UserService) http.Handler {
@@ -149,6 +228,26 @@ func GetLikedSongsHandler(service service.UserService) http.Handler {
 	return http.Handler(MakeGetLikedSongsEndpoint(service))
 }
 
+func DislikeSongHandler(service service.UserService) http.Handler {
+	return http.Handler(MakeDislikeSongEndpoint(service))
+}
+
+func UndislikeSongHandler(service service.UserService) http.Handler {
+	return http.Handler(MakeUndislikeSongEndpoint(service))
+}
+
+func HideArtistHandler(service service.UserService) http.Handler {
+	return http.Handler(MakeHideArtistEndpoint(service))
+}
+
+func UnhideArtistHandler(service service.UserService) http.Handler {
+	return http.Handler(MakeUnhideArtistEndpoint(service))
+}
+
+func GetNegativeFeedbackHandler(service service.UserService) http.Handler {
+	return http.Handler(MakeGetNegativeFeedbackEndpoint(service))
+}
+
 func NewCreateUserHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
 	return append([]http.HandlerOption{
 		http.HandlerWithDecoder(CreateUserDecoderFunc),
@@ -213,11 +312,51 @@ func NewDeleteUserHandlerOption(opts []http.HandlerOption) []http.HandlerOption
 	}, opts...)
 }
 
+func NewDislikeSongHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(DislikeSongDecoderFunc),
+		http.HandlerWithEncoder(DislikeSongEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+func NewUndislikeSongHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(UndislikeSongDecoderFunc),
+		http.HandlerWithEncoder(UndislikeSongEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+func NewHideArtistHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(HideArtistDecoderFunc),
+		http.HandlerWithEncoder(HideArtistEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+func NewUnhideArtistHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(UnhideArtistDecoderFunc),
+		http.HandlerWithEncoder(UnhideArtistEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
+func NewGetNegativeFeedbackHandlerOption(opts []http.HandlerOption) []http.HandlerOption {
+	return append([]http.HandlerOption{
+		http.HandlerWithDecoder(GetNegativeFeedbackDecoderFunc),
+		http.HandlerWithEncoder(GetNegativeFeedbackEncoderFunc),
+		http.HandlerWithErrorEncoder(errorEncoder),
+	}, opts...)
+}
+
 // Decoder functions
 func CreateUserDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
 	var req model.CreateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, err
+		return nil, errors.Wrap(errBadRequest, err.Error())
 	}
 	return req, nil
 }
@@ -234,7 +373,7 @@ func GetAllUsersDecoderFunc(ctx context.Context, r *net_http.Request) (interface
 func UpdateUserDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
 	var req model.UpdateUserRequest
 	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		return nil, err
+		return nil, errors.Wrap(errBadRequest, err.Error())
 	}
 	// Get ID from path parameter
 	req.ID = http.Parameters(r).ByName("id")
@@ -243,7 +382,15 @@ func UpdateUserDecoderFunc(ctx context.Context, r *net_http.Request) (interface{
 
 func DeleteUserDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
 	id := http.Parameters(r).ByName("id")
-	return model.DeleteUserRequest{ID: id}, nil
+	req := model.DeleteUserRequest{ID: id}
+	if erase := r.URL.Query().Get("erase"); erase != "" {
+		parsed, err := strconv.ParseBool(erase)
+		if err != nil {
+			return nil, errors.Wrap(errBadRequest, "erase must be a boolean")
+		}
+		req.Erase = parsed
+	}
+	return req, nil
 }
 
 func LikeSongDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
@@ -258,6 +405,26 @@ func GetLikedSongsDecoderFunc(ctx context.Context, r *net_http.Request) (interfa
 	return model.GetLikedSongsRequest{UserID: http.Parameters(r).ByName("id")}, nil
 }
 
+func DislikeSongDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	return model.DislikeSongRequest{UserID: http.Parameters(r).ByName("id"), SongName: http.Parameters(r).ByName("song_name")}, nil
+}
+
+func UndislikeSongDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	return model.UndislikeSongRequest{UserID: http.Parameters(r).ByName("id"), SongName: http.Parameters(r).ByName("song_name")}, nil
+}
+
+func HideArtistDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	return model.HideArtistRequest{UserID: http.Parameters(r).ByName("id"), Artist: http.Parameters(r).ByName("artist")}, nil
+}
+
+func UnhideArtistDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	return model.UnhideArtistRequest{UserID: http.Parameters(r).ByName("id"), Artist: http.Parameters(r).ByName("artist")}, nil
+}
+
+func GetNegativeFeedbackDecoderFunc(ctx context.Context, r *net_http.Request) (interface{}, error) {
+	return model.GetNegativeFeedbackRequest{UserID: http.Parameters(r).ByName("id")}, nil
+}
+
 // Encoder functions
 func CreateUserEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
 	w.Header().Set("Content-Type", "application/json")
@@ -298,3 +465,28 @@ func DeleteUserEncoderFunc(ctx context.Context, w net_http.ResponseWriter, respo
 	w.Header().Set("Content-Type", "application/json")
 	return json.NewEncoder(w).Encode(response)
 }
+
+func DislikeSongEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func UndislikeSongEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func HideArtistEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func UnhideArtistEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
+
+func GetNegativeFeedbackEncoderFunc(ctx context.Context, w net_http.ResponseWriter, response interface{}) error {
+	w.Header().Set("Content-Type", "application/json")
+	return json.NewEncoder(w).Encode(response)
+}
@@ -0,0 +1,174 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log/slog"
+	"music-store/internal/auth"
+	net_http "net/http"
+
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+// NewHandlerOption replays the stored response when a POST, PUT or DELETE
+// repeats an Idempotency-Key. It must come after the auth filter, keys are
+// scoped to the client that sent them. Requests without the header or
+// without a principal, and Redis errors, go through as usual. It does not
+// belong on the account routes, their responses carry tokens.
+func (s *Store) NewHandlerOption() http.HandlerOption {
+	return http.HandlerWithFilter(func(next net_http.Handler) net_http.Handler {
+		return net_http.HandlerFunc(func(w net_http.ResponseWriter, r *net_http.Request) {
+			key := r.Header.Get(Header)
+			principal, _ := auth.PrincipalFromContext(r.Context())
+			if key == "" || !isWrite(r.Method) || principal == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxKeyLength {
+				writeError(w, net_http.StatusBadRequest, "Idempotency-Key is too long")
+				return
+			}
+
+			body, err := io.ReadAll(net_http.MaxBytesReader(w, r.Body, maxBodySize))
+			var tooLarge *net_http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				writeError(w, net_http.StatusRequestEntityTooLarge, "request body is too large")
+				return
+			}
+			if err != nil {
+				writeError(w, net_http.StatusBadRequest, "failed to read request body")
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			ctx := r.Context()
+			redisKey := redisKey(ctx, clientOf(principal), key)
+			fingerprint := fingerprintOf(r, body)
+
+			existing, err := s.begin(ctx, redisKey, fingerprint)
+			if err != nil {
+				slog.WarnContext(ctx, "idempotency check failed, serving the request as is", "error", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+			if existing != nil {
+				switch {
+				case existing.Fingerprint != fingerprint:
+					writeError(w, net_http.StatusUnprocessableEntity, "Idempotency-Key was used for a different request")
+				case existing.State == stateInProgress:
+					w.Header().Set("Retry-After", "1")
+					writeError(w, net_http.StatusConflict, "a request with this Idempotency-Key is in progress")
+				default:
+					replay(w, existing)
+				}
+				return
+			}
+
+			// Headers set by the filters before, like the rate limits, are
+			// current on replays and not stored
+			outer := w.Header().Clone()
+			recorder := &responseRecorder{ResponseWriter: w, status: net_http.StatusOK}
+			next.ServeHTTP(recorder, r)
+
+			// Server errors are not final, the client may retry them. Neither
+			// is a request past its deadline, the timeout filter answered
+			// 504 whatever status the handler wrote, and the key is released
+			// with a context that is still live.
+			if recorder.status >= net_http.StatusInternalServerError || ctx.Err() != nil {
+				err = s.release(context.WithoutCancel(ctx), redisKey)
+			} else {
+				err = s.finish(ctx, redisKey, &entry{
+					Fingerprint: fingerprint,
+					Status:      recorder.status,
+					Header:      addedHeaders(outer, w.Header()),
+					Body:        recorder.body.Bytes(),
+				})
+			}
+			if err != nil {
+				slog.ErrorContext(ctx, "idempotency store failed", "error", err)
+			}
+		})
+	})
+}
+
+func isWrite(method string) bool {
+	return method == net_http.MethodPost || method == net_http.MethodPut || method == net_http.MethodDelete
+}
+
+func clientOf(principal *auth.Principal) string {
+	if principal.Kind == auth.KindAPIKey {
+		return "key:" + principal.KeyID
+	}
+	return "user:" + principal.Subject
+}
+
+// fingerprintOf identifies the request a key was first used for
+func fingerprintOf(r *net_http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func addedHeaders(before, after net_http.Header) map[string][]string {
+	added := map[string][]string{}
+	for name, values := range after {
+		if !equal(before[name], values) {
+			added[name] = values
+		}
+	}
+	return added
+}
+
+func equal(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func replay(w net_http.ResponseWriter, e *entry) {
+	for name, values := range e.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set("Idempotent-Replayed", "true")
+	w.WriteHeader(e.Status)
+	w.Write(e.Body)
+}
+
+// responseRecorder keeps a copy of the response it passes through
+type responseRecorder struct {
+	net_http.ResponseWriter
+	status      int
+	body        bytes.Buffer
+	wroteHeader bool
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status, r.wroteHeader = status, true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func writeError(w net_http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
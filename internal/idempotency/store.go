@@ -0,0 +1,97 @@
+// Package idempotency lets clients retry writes safely. The first response
+// to an Idempotency-Key is stored and replayed for every repeat.
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"music-store/internal/tenant"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Header is the request header carrying the key
+const Header = "Idempotency-Key"
+
+const (
+	stateInProgress = "in_progress"
+	stateDone       = "done"
+	maxKeyLength    = 255
+	// maxBodySize bounds the body buffered to fingerprint a request
+	maxBodySize = 1 << 20
+	// lockTTL bounds how long a crashed request blocks its key
+	lockTTL = time.Minute
+)
+
+type (
+	// Config holds the idempotency settings
+	Config struct {
+		TTL time.Duration // How long a response is replayed
+	}
+
+	// Store keeps one entry per client and key
+	Store struct {
+		redisClient redis.UniversalClient
+		ttl         time.Duration
+	}
+
+	// entry is a request being served or the response it got
+	entry struct {
+		State       string              `json:"state"`
+		Fingerprint string              `json:"fingerprint"`
+		Status      int                 `json:"status,omitempty"`
+		Header      map[string][]string `json:"header,omitempty"`
+		Body        []byte              `json:"body,omitempty"`
+	}
+)
+
+func NewStore(config *Config, redisClient redis.UniversalClient) *Store {
+	return &Store{redisClient: redisClient, ttl: config.TTL}
+}
+
+// begin claims the key for a request. It returns nil when the claim
+// succeeded, otherwise the entry already holding the key.
+func (s *Store) begin(ctx context.Context, key, fingerprint string) (*entry, error) {
+	data, err := json.Marshal(&entry{State: stateInProgress, Fingerprint: fingerprint})
+	if err != nil {
+		return nil, err
+	}
+	ok, err := s.redisClient.SetNX(ctx, key, data, lockTTL).Result()
+	if err != nil || ok {
+		return nil, err
+	}
+
+	existing, err := s.redisClient.Get(ctx, key).Bytes()
+	if err == redis.Nil {
+		return s.begin(ctx, key, fingerprint) // Expired in between
+	}
+	if err != nil {
+		return nil, err
+	}
+	var e entry
+	if err := json.Unmarshal(existing, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// finish stores the response for replays
+func (s *Store) finish(ctx context.Context, key string, e *entry) error {
+	e.State = stateDone
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	return s.redisClient.Set(ctx, key, data, s.ttl).Err()
+}
+
+// release frees the key so the request can be retried
+func (s *Store) release(ctx context.Context, key string) error {
+	return s.redisClient.Del(ctx, key).Err()
+}
+
+func redisKey(ctx context.Context, client, key string) string {
+	return tenant.Key(ctx, fmt.Sprintf("idempotency:%s:%s", client, key))
+}
@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	net_http "net/http"
+	"sync"
+	"time"
+
+	tmux "github.com/dimfeld/httptreemux/v5"
+	"github.com/gofrs/uuid"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+// HeaderRequestID carries the request ID in both directions
+const HeaderRequestID = "X-Request-ID"
+
+// maxRequestIDLength bounds IDs taken from clients, longer ones are replaced
+const maxRequestIDLength = 128
+
+// accessLog collects what the inner filters learn about the request, such
+// as the principal, for the line written once it is answered
+type accessLog struct {
+	mu    sync.Mutex
+	attrs []slog.Attr
+}
+
+type accessLogKey struct{}
+
+// Annotate adds attrs to the access log line of the request served with
+// ctx. It does nothing outside a request.
+func Annotate(ctx context.Context, attrs ...slog.Attr) {
+	if l, ok := ctx.Value(accessLogKey{}).(*accessLog); ok {
+		l.mu.Lock()
+		l.attrs = append(l.attrs, attrs...)
+		l.mu.Unlock()
+	}
+}
+
+// statusWriter remembers the status code the handler answered with
+type statusWriter struct {
+	net_http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = net_http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// NewHandlerOption gives every request an ID, the one in its X-Request-ID
+// header when it has a usable one, returns it in the response and logs the
+// request once it is answered. It must come before the other filters so
+// that their logs carry the ID and their answers are logged.
+func NewHandlerOption() http.HandlerOption {
+	return http.HandlerWithFilter(func(next net_http.Handler) net_http.Handler {
+		return net_http.HandlerFunc(func(w net_http.ResponseWriter, r *net_http.Request) {
+			start := time.Now()
+
+			id := r.Header.Get(HeaderRequestID)
+			if !validRequestID(id) {
+				id = uuid.Must(uuid.NewV4()).String()
+			}
+			// go-base copies the header into the endpoint context, keep it
+			// in line with ours
+			r.Header.Set(HeaderRequestID, id)
+			w.Header().Set(HeaderRequestID, id)
+
+			access := &accessLog{}
+			ctx := context.WithValue(WithRequestID(r.Context(), id), accessLogKey{}, access)
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			if sw.status == 0 {
+				sw.status = net_http.StatusOK
+			}
+			level := slog.LevelInfo
+			if sw.status >= net_http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			access.mu.Lock()
+			defer access.mu.Unlock()
+			attrs := append([]slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("route", tmux.ContextRoute(ctx)),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", sw.status),
+				slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			}, access.attrs...)
+			slog.LogAttrs(ctx, level, "request", attrs...)
+		})
+	})
+}
+
+// validRequestID accepts printable ASCII without spaces, so that a client
+// cannot forge log lines or headers with it
+func validRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,174 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"music-store/internal/repository"
+	"music-store/internal/service"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	catalogSongs = prometheus.NewDesc("music_store_songs",
+		"Songs in the catalog.", []string{"tenant"}, nil)
+	catalogUsers = prometheus.NewDesc("music_store_users",
+		"Registered users.", []string{"tenant"}, nil)
+	catalogIndexed = prometheus.NewDesc("music_store_vector_index_size",
+		"Songs with an embedding, which the nearest neighbour search ranks.", []string{"tenant"}, nil)
+
+	likesTotal = prometheus.NewCounter(prometheus.CounterOpts{
+		Namespace: "music_store",
+		Name:      "song_likes_total",
+		Help:      "Songs liked.",
+	})
+	recentLikes = &window{}
+)
+
+func init() {
+	Registry.MustRegister(likesTotal, prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: "music_store",
+		Name:      "song_likes_per_minute",
+		Help:      "Songs liked in the last 60 seconds.",
+	}, func() float64 { return float64(recentLikes.count(time.Now())) }))
+}
+
+// Catalog is what the domain gauges report for one tenant
+type Catalog struct {
+	Songs        int
+	Users        int
+	IndexedSongs int
+}
+
+// CatalogFunc counts the catalog of a tenant
+type CatalogFunc func(ctx context.Context, tenantID string) (*Catalog, error)
+
+// CountCatalog lists the songs and users of the tenant. Listings scan the
+// keyspace, so the collector calls it at most once per refresh interval.
+func CountCatalog(songs repository.SongRepository, users repository.UserRepository) CatalogFunc {
+	return func(ctx context.Context, tenantID string) (*Catalog, error) {
+		songList, err := songs.ForTenant(tenantID).GetAllSongs(ctx)
+		if err != nil {
+			return nil, err
+		}
+		userList, err := users.ForTenant(tenantID).GetAllUsers(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		catalog := &Catalog{Songs: len(songList.Songs), Users: len(userList.Users)}
+		for _, song := range songList.Songs {
+			if len(song.Embedding) > 0 {
+				catalog.IndexedSongs++
+			}
+		}
+		return catalog, nil
+	}
+}
+
+// domainCollector reports the catalog of every tenant, counted again when
+// a scrape finds the counts older than refresh
+type domainCollector struct {
+	tenants []string
+	count   CatalogFunc
+	refresh time.Duration
+
+	mu       sync.Mutex
+	counted  time.Time
+	catalogs map[string]*Catalog
+}
+
+// NewDomainCollector exports the catalog sizes of tenants, the empty ID
+// being the default tenant
+func NewDomainCollector(tenants []string, count CatalogFunc, refresh time.Duration) prometheus.Collector {
+	return &domainCollector{tenants: tenants, count: count, refresh: refresh, catalogs: map[string]*Catalog{}}
+}
+
+func (c *domainCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- catalogSongs
+	ch <- catalogUsers
+	ch <- catalogIndexed
+}
+
+func (c *domainCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if time.Since(c.counted) >= c.refresh {
+		c.recount()
+	}
+	for tenantID, catalog := range c.catalogs {
+		ch <- prometheus.MustNewConstMetric(catalogSongs, prometheus.GaugeValue, float64(catalog.Songs), tenantID)
+		ch <- prometheus.MustNewConstMetric(catalogUsers, prometheus.GaugeValue, float64(catalog.Users), tenantID)
+		ch <- prometheus.MustNewConstMetric(catalogIndexed, prometheus.GaugeValue, float64(catalog.IndexedSongs), tenantID)
+	}
+}
+
+// recount keeps the previous counts of a tenant that cannot be counted
+func (c *domainCollector) recount() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	for _, tenantID := range c.tenants {
+		catalog, err := c.count(ctx, tenantID)
+		if err != nil {
+			slog.WarnContext(ctx, "counting the catalog failed", "tenant", tenantID, "error", err)
+			continue
+		}
+		c.catalogs[tenantID] = catalog
+	}
+	c.counted = time.Now()
+}
+
+// window counts events over the last minute in one second buckets
+type window struct {
+	mu      sync.Mutex
+	seconds [60]int64 // The second each bucket was last used in
+	counts  [60]int
+}
+
+func (w *window) add(now time.Time) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	second := now.Unix()
+	i := second % 60
+	if w.seconds[i] != second {
+		w.seconds[i] = second
+		w.counts[i] = 0
+	}
+	w.counts[i]++
+}
+
+func (w *window) count(now time.Time) int {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	total := 0
+	for i, second := range w.seconds {
+		if now.Unix()-second < 60 {
+			total += w.counts[i]
+		}
+	}
+	return total
+}
+
+type likeCountingUserService struct {
+	service.UserService
+}
+
+// NewUserService counts the likes going through next
+func NewUserService(next service.UserService) service.UserService {
+	return &likeCountingUserService{UserService: next}
+}
+
+func (s *likeCountingUserService) LikeSong(ctx context.Context, userID, songName string) (string, error) {
+	msg, err := s.UserService.LikeSong(ctx, userID, songName)
+	if err == nil && msg == "success" {
+		likesTotal.Inc()
+		recentLikes.add(time.Now())
+	}
+	return msg, err
+}
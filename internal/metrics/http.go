@@ -0,0 +1,72 @@
+package metrics
+
+import (
+	net_http "net/http"
+	"strconv"
+	"time"
+
+	tmux "github.com/dimfeld/httptreemux/v5"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+var (
+	requestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "music_store",
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Requests served, by route pattern, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	requestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "music_store",
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Time from the first filter until the handler returned, by route pattern and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+)
+
+func init() {
+	Registry.MustRegister(requestsTotal, requestDuration)
+}
+
+// statusWriter remembers the status code the handler answered with
+type statusWriter struct {
+	net_http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = net_http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// NewHandlerOption counts and times requests. It must come before the
+// other filters so that their answers, such as 401, 429 and 504, are
+// counted.
+func NewHandlerOption() http.HandlerOption {
+	return http.HandlerWithFilter(func(next net_http.Handler) net_http.Handler {
+		return net_http.HandlerFunc(func(w net_http.ResponseWriter, r *net_http.Request) {
+			start := time.Now()
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r)
+
+			route := tmux.ContextRoute(r.Context())
+			if sw.status == 0 {
+				sw.status = net_http.StatusOK
+			}
+			requestsTotal.WithLabelValues(route, r.Method, strconv.Itoa(sw.status)).Inc()
+			requestDuration.WithLabelValues(route, r.Method).Observe(time.Since(start).Seconds())
+		})
+	})
+}
@@ -0,0 +1,81 @@
+// Package metrics holds the Prometheus collectors of the music store. The
+// process-wide ones are registered on Registry when the package is loaded,
+// those reading a Redis client or a storage belong to the application.
+package metrics
+
+import (
+	"context"
+	net_http "net/http"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Reasons an operation was abandoned
+const (
+	ReasonCancelled = "cancelled" // The client went away
+	ReasonTimeout   = "timeout"   // The route's deadline passed
+)
+
+// Registry collects every metric of the process
+var Registry = prometheus.NewRegistry()
+
+var (
+	requestsAborted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "music_store",
+		Subsystem: "http",
+		Name:      "requests_aborted_total",
+		Help:      "Requests whose context was cancelled or timed out before the handler returned.",
+	}, []string{"route", "method", "reason"})
+
+	redisAborted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "music_store",
+		Subsystem: "redis",
+		Name:      "commands_aborted_total",
+		Help:      "Redis commands and pipelines abandoned because their context was cancelled or timed out.",
+	}, []string{"command", "reason"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		requestsAborted, redisAborted,
+	)
+}
+
+// Reason tells why ctx ended, ok is false while it is still live
+func Reason(ctx context.Context) (reason string, ok bool) {
+	switch err := ctx.Err(); {
+	case err == nil:
+		return "", false
+	case errors.Is(err, context.DeadlineExceeded):
+		return ReasonTimeout, true
+	default:
+		return ReasonCancelled, true
+	}
+}
+
+// RequestAborted counts a request to the route pattern that ended for
+// reason
+func RequestAborted(route, method, reason string) {
+	requestsAborted.WithLabelValues(route, method, reason).Inc()
+}
+
+// Config controls the metrics endpoint
+type Config struct {
+	Enabled bool
+	Path    string
+	// CatalogRefresh is how old the catalog gauges may get, counting lists
+	// every song and user
+	CatalogRefresh time.Duration
+}
+
+// Handler serves Registry and the collectors of an application in the
+// Prometheus text format
+func Handler(collectors *prometheus.Registry) net_http.Handler {
+	return promhttp.HandlerFor(prometheus.Gatherers{Registry, collectors}, promhttp.HandlerOpts{})
+}
@@ -0,0 +1,63 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	poolHits = prometheus.NewDesc("music_store_redis_pool_hits_total",
+		"Times a free connection was found in the pool.", nil, nil)
+	poolMisses = prometheus.NewDesc("music_store_redis_pool_misses_total",
+		"Times no free connection was found in the pool.", nil, nil)
+	poolTimeouts = prometheus.NewDesc("music_store_redis_pool_timeouts_total",
+		"Times waiting for a connection timed out.", nil, nil)
+	poolWaits = prometheus.NewDesc("music_store_redis_pool_waits_total",
+		"Times a caller waited for a connection.", nil, nil)
+	poolWaitSeconds = prometheus.NewDesc("music_store_redis_pool_wait_seconds_total",
+		"Time spent waiting for connections.", nil, nil)
+	poolConns = prometheus.NewDesc("music_store_redis_pool_connections",
+		"Connections in the pool.", nil, nil)
+	poolIdleConns = prometheus.NewDesc("music_store_redis_pool_idle_connections",
+		"Idle connections in the pool.", nil, nil)
+	poolStaleConns = prometheus.NewDesc("music_store_redis_pool_stale_connections_total",
+		"Stale connections removed from the pool.", nil, nil)
+)
+
+// poolCollector reads the pool statistics of a client at every scrape. A
+// cluster client sums the pools of all its nodes.
+type poolCollector struct {
+	client redis.UniversalClient
+}
+
+// NewPoolCollector exports the connection pool statistics of client
+func NewPoolCollector(client redis.UniversalClient) prometheus.Collector {
+	return &poolCollector{client: client}
+}
+
+func (c *poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		poolHits, poolMisses, poolTimeouts, poolWaits, poolWaitSeconds, poolConns, poolIdleConns, poolStaleConns,
+	} {
+		ch <- desc
+	}
+}
+
+func (c *poolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.client.PoolStats()
+	counter := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.CounterValue, value)
+	}
+	gauge := func(desc *prometheus.Desc, value float64) {
+		ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, value)
+	}
+
+	counter(poolHits, float64(stats.Hits))
+	counter(poolMisses, float64(stats.Misses))
+	counter(poolTimeouts, float64(stats.Timeouts))
+	counter(poolWaits, float64(stats.WaitCount))
+	counter(poolWaitSeconds, float64(stats.WaitDurationNs)/1e9)
+	gauge(poolConns, float64(stats.TotalConns))
+	gauge(poolIdleConns, float64(stats.IdleConns))
+	counter(poolStaleConns, float64(stats.StaleConns))
+}
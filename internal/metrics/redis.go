@@ -0,0 +1,53 @@
+package metrics
+
+import (
+	"context"
+	"net"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisHook counts the commands that fail because their context ended. A
+// command failing for any other reason is left to the caller.
+type redisHook struct{}
+
+// NewRedisHook returns the hook to add to every Redis client
+func NewRedisHook() redis.Hook {
+	return redisHook{}
+}
+
+func (redisHook) DialHook(next redis.DialHook) redis.DialHook {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := next(ctx, network, addr)
+		if err != nil {
+			countRedis(ctx, "dial")
+		}
+		return conn, err
+	}
+}
+
+func (redisHook) ProcessHook(next redis.ProcessHook) redis.ProcessHook {
+	return func(ctx context.Context, cmd redis.Cmder) error {
+		err := next(ctx, cmd)
+		if err != nil && err != redis.Nil {
+			countRedis(ctx, cmd.Name())
+		}
+		return err
+	}
+}
+
+func (redisHook) ProcessPipelineHook(next redis.ProcessPipelineHook) redis.ProcessPipelineHook {
+	return func(ctx context.Context, cmds []redis.Cmder) error {
+		err := next(ctx, cmds)
+		if err != nil && err != redis.Nil {
+			countRedis(ctx, "pipeline")
+		}
+		return err
+	}
+}
+
+func countRedis(ctx context.Context, command string) {
+	if reason, ok := Reason(ctx); ok {
+		redisAborted.WithLabelValues(command, reason).Inc()
+	}
+}
@@ -0,0 +1,249 @@
+package metrics
+
+import (
+	"context"
+	"log/slog"
+	"music-store/internal/model"
+	"music-store/internal/repository"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	repositoryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "music_store",
+		Subsystem: "repository",
+		Name:      "duration_seconds",
+		Help:      "Time spent in repository methods, which is time spent in the storage backend.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5},
+	}, []string{"repository", "method"})
+
+	repositoryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "music_store",
+		Subsystem: "repository",
+		Name:      "errors_total",
+		Help:      "Repository calls that failed. Missing records and conflicts are answers, not errors.",
+	}, []string{"repository", "method"})
+)
+
+func init() {
+	Registry.MustRegister(repositoryDuration, repositoryErrors)
+}
+
+// observe records a call of method that started at start, failures are
+// logged with the request they belong to
+func observe(ctx context.Context, repo, method string, start time.Time, err error) {
+	elapsed := time.Since(start)
+	repositoryDuration.WithLabelValues(repo, method).Observe(elapsed.Seconds())
+	switch err {
+	case nil, redis.Nil, repository.ErrNotFound, repository.ErrAlreadyExists, repository.ErrUsernameTaken:
+		slog.DebugContext(ctx, "repository call", "repository", repo, "method", method, "duration_ms", float64(elapsed.Microseconds())/1000)
+	default:
+		repositoryErrors.WithLabelValues(repo, method).Inc()
+		slog.ErrorContext(ctx, "repository call failed", "repository", repo, "method", method, "error", err)
+	}
+}
+
+type songRepository struct {
+	next repository.SongRepository
+}
+
+// NewSongRepository times every call to next
+func NewSongRepository(next repository.SongRepository) repository.SongRepository {
+	return &songRepository{next: next}
+}
+
+func (r *songRepository) ForTenant(tenantID string) repository.SongRepository {
+	return &songRepository{next: r.next.ForTenant(tenantID)}
+}
+
+func (r *songRepository) CreateSong(ctx context.Context, song *model.CreateSongRequest) (msg string, err error) {
+	defer func(start time.Time) { observe(ctx, "songs", "CreateSong", start, err) }(time.Now())
+	return r.next.CreateSong(ctx, song)
+}
+
+func (r *songRepository) GetSong(ctx context.Context, name string) (resp *model.GetSongResponse, err error) {
+	defer func(start time.Time) { observe(ctx, "songs", "GetSong", start, err) }(time.Now())
+	return r.next.GetSong(ctx, name)
+}
+
+func (r *songRepository) GetAllSongs(ctx context.Context) (resp *model.GetSongListResponse, err error) {
+	defer func(start time.Time) { observe(ctx, "songs", "GetAllSongs", start, err) }(time.Now())
+	return r.next.GetAllSongs(ctx)
+}
+
+func (r *songRepository) UpdateSong(ctx context.Context, song *model.UpdateSongRequest) (msg string, err error) {
+	defer func(start time.Time) { observe(ctx, "songs", "UpdateSong", start, err) }(time.Now())
+	return r.next.UpdateSong(ctx, song)
+}
+
+func (r *songRepository) DeleteSong(ctx context.Context, name string) (msg string, err error) {
+	defer func(start time.Time) { observe(ctx, "songs", "DeleteSong", start, err) }(time.Now())
+	return r.next.DeleteSong(ctx, name)
+}
+
+func (r *songRepository) TrashSong(ctx context.Context, entry *model.TrashedSong, ttl time.Duration) (msg string, err error) {
+	defer func(start time.Time) { observe(ctx, "songs", "TrashSong", start, err) }(time.Now())
+	return r.next.TrashSong(ctx, entry, ttl)
+}
+
+func (r *songRepository) GetTrashedSong(ctx context.Context, name string) (entry *model.TrashedSong, err error) {
+	defer func(start time.Time) { observe(ctx, "songs", "GetTrashedSong", start, err) }(time.Now())
+	return r.next.GetTrashedSong(ctx, name)
+}
+
+func (r *songRepository) GetTrashedSongs(ctx context.Context) (entries []*model.TrashedSong, err error) {
+	defer func(start time.Time) { observe(ctx, "songs", "GetTrashedSongs", start, err) }(time.Now())
+	return r.next.GetTrashedSongs(ctx)
+}
+
+func (r *songRepository) ModifyTrashedSong(ctx context.Context, name string, fn func(entry *model.TrashedSong) bool) (err error) {
+	defer func(start time.Time) { observe(ctx, "songs", "ModifyTrashedSong", start, err) }(time.Now())
+	return r.next.ModifyTrashedSong(ctx, name, fn)
+}
+
+func (r *songRepository) RestoreSong(ctx context.Context, name string) (entry *model.TrashedSong, err error) {
+	defer func(start time.Time) { observe(ctx, "songs", "RestoreSong", start, err) }(time.Now())
+	return r.next.RestoreSong(ctx, name)
+}
+
+func (r *songRepository) PurgeSong(ctx context.Context, name string) (msg string, err error) {
+	defer func(start time.Time) { observe(ctx, "songs", "PurgeSong", start, err) }(time.Now())
+	return r.next.PurgeSong(ctx, name)
+}
+
+type userRepository struct {
+	next repository.UserRepository
+}
+
+// NewUserRepository times every call to next
+func NewUserRepository(next repository.UserRepository) repository.UserRepository {
+	return &userRepository{next: next}
+}
+
+func (r *userRepository) ForTenant(tenantID string) repository.UserRepository {
+	return &userRepository{next: r.next.ForTenant(tenantID)}
+}
+
+func (r *userRepository) CreateUser(ctx context.Context, user *model.CreateUserRequest) (msg string, err error) {
+	defer func(start time.Time) { observe(ctx, "users", "CreateUser", start, err) }(time.Now())
+	return r.next.CreateUser(ctx, user)
+}
+
+func (r *userRepository) GetUser(ctx context.Context, id string) (resp *model.GetUserResponse, err error) {
+	defer func(start time.Time) { observe(ctx, "users", "GetUser", start, err) }(time.Now())
+	return r.next.GetUser(ctx, id)
+}
+
+func (r *userRepository) GetAllUsers(ctx context.Context) (resp *model.GetUserListResponse, err error) {
+	defer func(start time.Time) { observe(ctx, "users", "GetAllUsers", start, err) }(time.Now())
+	return r.next.GetAllUsers(ctx)
+}
+
+func (r *userRepository) UpdateUser(ctx context.Context, user *model.UpdateUserRequest) (msg string, err error) {
+	defer func(start time.Time) { observe(ctx, "users", "UpdateUser", start, err) }(time.Now())
+	return r.next.UpdateUser(ctx, user)
+}
+
+func (r *userRepository) ModifyUser(ctx context.Context, id string, fn func(user *model.User) bool) (err error) {
+	defer func(start time.Time) { observe(ctx, "users", "ModifyUser", start, err) }(time.Now())
+	return r.next.ModifyUser(ctx, id, fn)
+}
+
+func (r *userRepository) DeleteUser(ctx context.Context, id string) (msg string, err error) {
+	defer func(start time.Time) { observe(ctx, "users", "DeleteUser", start, err) }(time.Now())
+	return r.next.DeleteUser(ctx, id)
+}
+
+func (r *userRepository) TrashUser(ctx context.Context, entry *model.TrashedUser, ttl time.Duration) (msg string, err error) {
+	defer func(start time.Time) { observe(ctx, "users", "TrashUser", start, err) }(time.Now())
+	return r.next.TrashUser(ctx, entry, ttl)
+}
+
+func (r *userRepository) GetTrashedUser(ctx context.Context, id string) (entry *model.TrashedUser, err error) {
+	defer func(start time.Time) { observe(ctx, "users", "GetTrashedUser", start, err) }(time.Now())
+	return r.next.GetTrashedUser(ctx, id)
+}
+
+func (r *userRepository) GetTrashedUsers(ctx context.Context) (entries []*model.TrashedUser, err error) {
+	defer func(start time.Time) { observe(ctx, "users", "GetTrashedUsers", start, err) }(time.Now())
+	return r.next.GetTrashedUsers(ctx)
+}
+
+func (r *userRepository) RestoreUser(ctx context.Context, id string) (entry *model.TrashedUser, err error) {
+	defer func(start time.Time) { observe(ctx, "users", "RestoreUser", start, err) }(time.Now())
+	return r.next.RestoreUser(ctx, id)
+}
+
+func (r *userRepository) PurgeUser(ctx context.Context, id string) (msg string, err error) {
+	defer func(start time.Time) { observe(ctx, "users", "PurgeUser", start, err) }(time.Now())
+	return r.next.PurgeUser(ctx, id)
+}
+
+type credentialRepository struct {
+	next repository.CredentialRepository
+}
+
+// NewCredentialRepository times every call to next
+func NewCredentialRepository(next repository.CredentialRepository) repository.CredentialRepository {
+	return &credentialRepository{next: next}
+}
+
+func (r *credentialRepository) ForTenant(tenantID string) repository.CredentialRepository {
+	return &credentialRepository{next: r.next.ForTenant(tenantID)}
+}
+
+func (r *credentialRepository) CreateCredential(ctx context.Context, credential *model.Credential) (msg string, err error) {
+	defer func(start time.Time) { observe(ctx, "credentials", "CreateCredential", start, err) }(time.Now())
+	return r.next.CreateCredential(ctx, credential)
+}
+
+func (r *credentialRepository) GetCredential(ctx context.Context, username string) (credential *model.Credential, err error) {
+	defer func(start time.Time) { observe(ctx, "credentials", "GetCredential", start, err) }(time.Now())
+	return r.next.GetCredential(ctx, username)
+}
+
+func (r *credentialRepository) DeleteCredential(ctx context.Context, username string) (msg string, err error) {
+	defer func(start time.Time) { observe(ctx, "credentials", "DeleteCredential", start, err) }(time.Now())
+	return r.next.DeleteCredential(ctx, username)
+}
+
+type refreshTokenRepository struct {
+	next repository.RefreshTokenRepository
+}
+
+// NewRefreshTokenRepository times every call to next
+func NewRefreshTokenRepository(next repository.RefreshTokenRepository) repository.RefreshTokenRepository {
+	return &refreshTokenRepository{next: next}
+}
+
+func (r *refreshTokenRepository) ForTenant(tenantID string) repository.RefreshTokenRepository {
+	return &refreshTokenRepository{next: r.next.ForTenant(tenantID)}
+}
+
+func (r *refreshTokenRepository) SaveRefreshToken(ctx context.Context, token *model.RefreshToken) (err error) {
+	defer func(start time.Time) { observe(ctx, "refresh_tokens", "SaveRefreshToken", start, err) }(time.Now())
+	return r.next.SaveRefreshToken(ctx, token)
+}
+
+func (r *refreshTokenRepository) ConsumeRefreshToken(ctx context.Context, id string) (token *model.RefreshToken, err error) {
+	defer func(start time.Time) { observe(ctx, "refresh_tokens", "ConsumeRefreshToken", start, err) }(time.Now())
+	return r.next.ConsumeRefreshToken(ctx, id)
+}
+
+func (r *refreshTokenRepository) UsedRefreshTokenFamily(ctx context.Context, id string) (family string, err error) {
+	defer func(start time.Time) { observe(ctx, "refresh_tokens", "UsedRefreshTokenFamily", start, err) }(time.Now())
+	return r.next.UsedRefreshTokenFamily(ctx, id)
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, family string) (err error) {
+	defer func(start time.Time) { observe(ctx, "refresh_tokens", "RevokeFamily", start, err) }(time.Now())
+	return r.next.RevokeFamily(ctx, family)
+}
+
+func (r *refreshTokenRepository) RevokeUserTokens(ctx context.Context, userID string) (err error) {
+	defer func(start time.Time) { observe(ctx, "refresh_tokens", "RevokeUserTokens", start, err) }(time.Now())
+	return r.next.RevokeUserTokens(ctx, userID)
+}
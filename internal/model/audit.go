@@ -0,0 +1,42 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+type (
+	// AuditRecord describes one mutation and who made it
+	AuditRecord struct {
+		ID        string         `json:"id"` // Stream entry ID, ordered by time
+		Actor     string         `json:"actor"`
+		ActorKind string         `json:"actor_kind,omitempty"`
+		KeyID     string         `json:"key_id,omitempty"`
+		Action    string         `json:"action"`
+		Target    string         `json:"target"` // Redis key of the mutated record
+		Changes   []*FieldChange `json:"changes,omitempty"`
+		Timestamp time.Time      `json:"timestamp"`
+		RequestID string         `json:"request_id,omitempty"`
+		ClientIP  string         `json:"client_ip,omitempty"`
+	}
+
+	// FieldChange holds the JSON value of a field before and after a
+	// mutation, either side is omitted when the field did not exist
+	FieldChange struct {
+		Field  string          `json:"field"`
+		Before json.RawMessage `json:"before,omitempty"`
+		After  json.RawMessage `json:"after,omitempty"`
+	}
+
+	GetAuditLogRequest struct {
+		Target string    `json:"target"`
+		Actor  string    `json:"actor"`
+		Since  time.Time `json:"since"`
+		Limit  int       `json:"limit"`
+	}
+
+	GetAuditLogResponse struct {
+		Records []*AuditRecord `json:"records"`
+		Err     error          `json:"error,omitempty"`
+	}
+)
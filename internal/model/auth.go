@@ -0,0 +1,72 @@
+package model
+
+import "time"
+
+type (
+	// Credential is stored apart from the User so the password hash can
+	// never leak through the user endpoints
+	Credential struct {
+		UserID       string    `json:"user_id"`
+		Username     string    `json:"username"`
+		PasswordHash string    `json:"password_hash"`
+		Roles        []string  `json:"roles,omitempty"`
+		CreatedAt    time.Time `json:"created_at"`
+	}
+
+	// RefreshToken is stored under the SHA-256 digest of the opaque token
+	RefreshToken struct {
+		ID        string    `json:"id"`
+		UserID    string    `json:"user_id"`
+		Family    string    `json:"family"` // Shared by every token rotated from the same login
+		Roles     []string  `json:"roles,omitempty"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	AuthTokens struct {
+		AccessToken  string `json:"access_token"`
+		RefreshToken string `json:"refresh_token"`
+		TokenType    string `json:"token_type"`
+		ExpiresIn    int    `json:"expires_in"` // Access token lifetime in seconds
+	}
+
+	SignupRequest struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+		Name     string `json:"name"`
+	}
+
+	SignupResponse struct {
+		UserID string      `json:"user_id,omitempty"`
+		Tokens *AuthTokens `json:"tokens,omitempty"`
+		Err    error       `json:"error,omitempty"`
+	}
+
+	LoginRequest struct {
+		Username string `json:"username"`
+		Password string `json:"password"`
+	}
+
+	LoginResponse struct {
+		Tokens *AuthTokens `json:"tokens,omitempty"`
+		Err    error       `json:"error,omitempty"`
+	}
+
+	RefreshTokenRequest struct {
+		RefreshToken string `json:"refresh_token"`
+	}
+
+	RefreshTokenResponse struct {
+		Tokens *AuthTokens `json:"tokens,omitempty"`
+		Err    error       `json:"error,omitempty"`
+	}
+
+	LogoutRequest struct {
+		RefreshToken string `json:"refresh_token"`
+		All          bool   `json:"all,omitempty"` // Revoke every session of the user
+	}
+
+	LogoutResponse struct {
+		Msg string `json:"msg"`
+		Err error  `json:"error,omitempty"`
+	}
+)
@@ -0,0 +1,47 @@
+package model
+
+type (
+	ExperimentAssignment struct {
+		Experiment string `json:"experiment"`
+		Variant    string `json:"variant"`
+	}
+
+	TrackEventRequest struct {
+		UserID   string `json:"user_id"`
+		SongName string `json:"song_name"`
+		Type     string `json:"type"` // click, like or play
+	}
+
+	TrackEventResponse struct {
+		Msg string `json:"msg"`
+		Err error  `json:"error,omitempty"`
+	}
+
+	VariantSummary struct {
+		Variant          string  `json:"variant"`
+		Impressions      int64   `json:"impressions"`
+		Clicks           int64   `json:"clicks"`
+		Likes            int64   `json:"likes"`
+		Plays            int64   `json:"plays"`
+		ClickThroughRate float64 `json:"click_through_rate"`
+		LikeThroughRate  float64 `json:"like_through_rate"`
+		PlayThroughRate  float64 `json:"play_through_rate"`
+	}
+
+	GetExperimentSummaryRequest struct {
+		Name string `json:"name"`
+	}
+
+	GetExperimentSummaryResponse struct {
+		Experiment string            `json:"experiment,omitempty"`
+		Variants   []*VariantSummary `json:"variants,omitempty"`
+		Err        error             `json:"error,omitempty"`
+	}
+
+	// ExperimentUserData is the experiment state held about one user
+	ExperimentUserData struct {
+		Assignments    []*ExperimentAssignment `json:"assignments"`
+		RecentlyShown  []string                `json:"recently_shown"`  // Recommended songs still open for attribution
+		RecentOutcomes []string                `json:"recent_outcomes"` // Outcomes already counted, as <type>:<experiment>:<variant>:<song>
+	}
+)
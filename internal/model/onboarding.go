@@ -0,0 +1,29 @@
+package model
+
+type (
+	OnboardingPick struct {
+		Song    *Song `json:"song"`
+		Cluster int   `json:"cluster"` // Embedding cluster the song was drawn from, -1 if it has no embedding
+	}
+
+	GetOnboardingPicksRequest struct {
+		Limit int `json:"limit,omitempty"`
+	}
+
+	GetOnboardingPicksResponse struct {
+		Picks  []*OnboardingPick `json:"picks,omitempty"`
+		Genres []string          `json:"genres,omitempty"` // Every genre in the catalog, for genre selection
+		Err    error             `json:"error,omitempty"`
+	}
+
+	OnboardUserRequest struct {
+		UserID    string   `json:"user_id"`
+		SongNames []string `json:"song_names,omitempty"`
+		Genres    []string `json:"genres,omitempty"`
+	}
+
+	OnboardUserResponse struct {
+		Msg string `json:"msg"`
+		Err error  `json:"error,omitempty"`
+	}
+)
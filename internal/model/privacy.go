@@ -0,0 +1,31 @@
+package model
+
+import "time"
+
+type (
+	// UserExport is everything held about a user, one section per store
+	UserExport struct {
+		UserID     string           `json:"user_id"`
+		ExportedAt time.Time        `json:"exported_at"`
+		Sections   []*ExportSection `json:"sections"`
+	}
+
+	ExportSection struct {
+		Name string      `json:"name"`
+		Data interface{} `json:"data"`
+	}
+
+	ExportUserRequest struct {
+		ID string `json:"id"`
+	}
+
+	// ErasureTombstone is kept after a user is erased as proof of the
+	// deletion. It holds no personal data beyond the user ID.
+	ErasureTombstone struct {
+		UserID    string         `json:"user_id"`
+		ErasedAt  time.Time      `json:"erased_at"`
+		ErasedBy  string         `json:"erased_by,omitempty"`
+		RequestID string         `json:"request_id,omitempty"`
+		Removed   map[string]int `json:"removed"` // Records removed per store
+	}
+)
@@ -0,0 +1,23 @@
+package model
+
+type (
+	Recommendation struct {
+		SongName   string             `json:"song_name"`
+		Score      float64            `json:"score"`
+		Sources    []string           `json:"sources,omitempty"`    // Populated only in debug mode
+		Components map[string]float64 `json:"components,omitempty"` // Weighted score per source, debug mode only
+	}
+
+	GetRecommendationsRequest struct {
+		UserID  string             `json:"user_id"`
+		Limit   int                `json:"limit,omitempty"`
+		Weights map[string]float64 `json:"weights,omitempty"` // Overrides the default weight per source
+		Debug   bool               `json:"debug,omitempty"`
+	}
+
+	GetRecommendationsResponse struct {
+		Recommendations []*Recommendation       `json:"recommendations,omitempty"`
+		Experiments     []*ExperimentAssignment `json:"experiments,omitempty"`
+		Err             error                   `json:"error,omitempty"`
+	}
+)
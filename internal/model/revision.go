@@ -0,0 +1,33 @@
+package model
+
+import "time"
+
+type (
+	// SongRevision is the state of a song before one of its updates
+	SongRevision struct {
+		Rev       int64          `json:"rev"`
+		Song      *Song          `json:"song"`
+		Actor     string         `json:"actor,omitempty"`
+		Timestamp time.Time      `json:"timestamp"`
+		Changes   []*FieldChange `json:"changes,omitempty"` // What the update changed
+	}
+
+	GetSongRevisionsRequest struct {
+		Name string `json:"name"`
+	}
+
+	GetSongRevisionsResponse struct {
+		Revisions []*SongRevision `json:"revisions"` // Newest first
+		Err       error           `json:"error,omitempty"`
+	}
+
+	RevertSongRequest struct {
+		Name string `json:"name"`
+		Rev  int64  `json:"rev"`
+	}
+
+	RevertSongResponse struct {
+		Msg string `json:"msg"`
+		Err error  `json:"error,omitempty"`
+	}
+)
@@ -2,8 +2,11 @@ package model
 
 type (
 	Song struct {
-		Name      string    `json:"name"`
-		Embedding []float64 `json:"embedding"`
+		Name        string    `json:"name"`
+		Artist      string    `json:"artist,omitempty"`
+		Genre       string    `json:"genre,omitempty"`
+		Embedding   []float64 `json:"embedding"`
+		ReleaseDate string    `json:"release_date,omitempty"` // YYYY-MM-DD
 	}
 
 	GetSongRequest struct {
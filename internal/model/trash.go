@@ -0,0 +1,60 @@
+package model
+
+import "time"
+
+type (
+	// TrashedSong is a deleted song kept until its retention expires
+	TrashedSong struct {
+		Song      *Song     `json:"song"`
+		LikedBy   []string  `json:"liked_by,omitempty"` // Users whose like is given back on restore
+		DeletedAt time.Time `json:"deleted_at"`
+		DeletedBy string    `json:"deleted_by,omitempty"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	// TrashedUser is a deleted user kept until its retention expires
+	TrashedUser struct {
+		User      *User     `json:"user"`
+		DeletedAt time.Time `json:"deleted_at"`
+		DeletedBy string    `json:"deleted_by,omitempty"`
+		ExpiresAt time.Time `json:"expires_at"`
+	}
+
+	RestoreSongRequest struct {
+		Name string `json:"name"`
+	}
+
+	RestoreSongResponse struct {
+		Msg string `json:"msg"`
+		Err error  `json:"error,omitempty"`
+	}
+
+	RestoreUserRequest struct {
+		ID string `json:"id"`
+	}
+
+	RestoreUserResponse struct {
+		Msg string `json:"msg"`
+		Err error  `json:"error,omitempty"`
+	}
+
+	GetTrashRequest struct {
+		Kind string `json:"kind"` // songs or users
+	}
+
+	GetTrashResponse struct {
+		Songs []*TrashedSong `json:"songs,omitempty"`
+		Users []*TrashedUser `json:"users,omitempty"`
+		Err   error          `json:"error,omitempty"`
+	}
+
+	PurgeTrashRequest struct {
+		Kind string `json:"kind"` // songs or users
+		Key  string `json:"key"`  // Song name or user ID
+	}
+
+	PurgeTrashResponse struct {
+		Msg string `json:"msg"`
+		Err error  `json:"error,omitempty"`
+	}
+)
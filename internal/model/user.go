@@ -2,10 +2,14 @@ package model
 
 type (
 	User struct {
-		ID         string    `json:"id"`
-		Name       string    `json:"name"`
-		LikedSongs []string  `json:"liked_songs,omitempty"`
-		Embedding  []float64 `json:"embedding,omitempty"`
+		ID            string    `json:"id"`
+		Name          string    `json:"name"`
+		Username      string    `json:"username,omitempty"` // Set for users who signed up with a password
+		LikedSongs    []string  `json:"liked_songs,omitempty"`
+		DislikedSongs []string  `json:"disliked_songs,omitempty"`
+		HiddenArtists []string  `json:"hidden_artists,omitempty"`
+		Genres        []string  `json:"genres,omitempty"` // Picked during onboarding
+		Embedding     []float64 `json:"embedding,omitempty"`
 	}
 
 	GetUserRequest struct {
@@ -47,12 +51,14 @@ type (
 	}
 
 	DeleteUserRequest struct {
-		ID string `json:"id"`
+		ID    string `json:"id"`
+		Erase bool   `json:"erase,omitempty"` // Remove every artifact derived from the user
 	}
 
 	DeleteUserResponse struct {
-		Msg string `json:"msg"`
-		Err error  `json:"error,omitempty"`
+		Msg       string            `json:"msg"`
+		Tombstone *ErasureTombstone `json:"tombstone,omitempty"`
+		Err       error             `json:"error,omitempty"`
 	}
 
 	LikeSongRequest struct {
@@ -83,4 +89,54 @@ type (
 		LikedSongs []string `json:"liked_songs,omitempty"`
 		Err        error    `json:"error,omitempty"`
 	}
+
+	DislikeSongRequest struct {
+		UserID   string `json:"user_id"`
+		SongName string `json:"song_name"`
+	}
+
+	DislikeSongResponse struct {
+		Msg string `json:"msg"`
+		Err error  `json:"error,omitempty"`
+	}
+
+	UndislikeSongRequest struct {
+		UserID   string `json:"user_id"`
+		SongName string `json:"song_name"`
+	}
+
+	UndislikeSongResponse struct {
+		Msg string `json:"msg"`
+		Err error  `json:"error,omitempty"`
+	}
+
+	HideArtistRequest struct {
+		UserID string `json:"user_id"`
+		Artist string `json:"artist"`
+	}
+
+	HideArtistResponse struct {
+		Msg string `json:"msg"`
+		Err error  `json:"error,omitempty"`
+	}
+
+	UnhideArtistRequest struct {
+		UserID string `json:"user_id"`
+		Artist string `json:"artist"`
+	}
+
+	UnhideArtistResponse struct {
+		Msg string `json:"msg"`
+		Err error  `json:"error,omitempty"`
+	}
+
+	GetNegativeFeedbackRequest struct {
+		UserID string `json:"user_id"`
+	}
+
+	GetNegativeFeedbackResponse struct {
+		DislikedSongs []string `json:"disliked_songs"`
+		HiddenArtists []string `json:"hidden_artists"`
+		Err           error    `json:"error,omitempty"`
+	}
 )
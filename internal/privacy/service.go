@@ -0,0 +1,181 @@
+// Package privacy exports and erases everything held about a user. Each
+// store holding personal data contributes a Source.
+package privacy
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"music-store/internal/api"
+	"music-store/internal/audit"
+	"music-store/internal/auth"
+	"music-store/internal/model"
+	"music-store/internal/repository"
+	"music-store/internal/tenant"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+var ErrUserNotFound error = api.NewError(404, "user not found")
+
+type (
+	// Source is a store holding data about users. Erase returns the number
+	// of records it removed.
+	Source interface {
+		Name() string
+		Export(ctx context.Context, user *model.User) (interface{}, error)
+		Erase(ctx context.Context, user *model.User) (int, error)
+	}
+
+	Service interface {
+		Export(ctx context.Context, userID string) (*model.UserExport, error)
+		// Erase removes the user from every source and records a tombstone.
+		// Erasing an already erased user returns the existing tombstone.
+		Erase(ctx context.Context, userID string) (*model.ErasureTombstone, error)
+	}
+
+	privacyService struct {
+		userRepository repository.UserRepository
+		redisClient    redis.UniversalClient
+		auditLog       audit.Log
+		sources        []Source
+	}
+)
+
+// NewService records erasures in auditLog, after the user's own records are
+// forgotten by the audit source
+func NewService(userRepository repository.UserRepository, redisClient redis.UniversalClient, auditLog audit.Log, sources ...Source) Service {
+	return &privacyService{userRepository: userRepository, redisClient: redisClient, auditLog: auditLog, sources: sources}
+}
+
+// Export collects the profile, taste data and every source. Plays are only
+// kept as aggregate experiment counters and there are no playlists, so
+// neither has a section of its own.
+func (s *privacyService) Export(ctx context.Context, userID string) (*model.UserExport, error) {
+	user, err := s.user(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	export := &model.UserExport{
+		UserID:     user.ID,
+		ExportedAt: time.Now().UTC(),
+		Sections: []*model.ExportSection{
+			{Name: "profile", Data: map[string]interface{}{
+				"id":       user.ID,
+				"name":     user.Name,
+				"username": user.Username,
+				"genres":   user.Genres,
+			}},
+			{Name: "likes", Data: map[string]interface{}{
+				"liked_songs":    user.LikedSongs,
+				"disliked_songs": user.DislikedSongs,
+				"hidden_artists": user.HiddenArtists,
+			}},
+			{Name: "embedding", Data: user.Embedding},
+		},
+	}
+
+	for _, source := range s.sources {
+		data, err := source.Export(ctx, user)
+		if err != nil {
+			return nil, errors.Wrapf(err, "exporting %s", source.Name())
+		}
+		export.Sections = append(export.Sections, &model.ExportSection{Name: source.Name(), Data: data})
+	}
+	return export, nil
+}
+
+// Erase removes the user record last, so a failed erasure can be retried
+func (s *privacyService) Erase(ctx context.Context, userID string) (*model.ErasureTombstone, error) {
+	user, err := s.user(ctx, userID)
+	if err == ErrUserNotFound {
+		if tombstone, err := s.tombstone(ctx, userID); err == nil {
+			return tombstone, nil
+		}
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	tombstone := &model.ErasureTombstone{
+		UserID:    user.ID,
+		RequestID: api.ContextString(ctx, http.ContextKeyRequestXRequestID),
+		Removed:   map[string]int{},
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		tombstone.ErasedBy = principal.Subject
+	}
+
+	for _, source := range s.sources {
+		n, err := source.Erase(ctx, user)
+		if err != nil {
+			return nil, errors.Wrapf(err, "erasing %s", source.Name())
+		}
+		tombstone.Removed[source.Name()] = n
+	}
+
+	// Erasure bypasses the trash, and empties it for a deleted user
+	users := s.userRepository.ForTenant(tenant.ID(ctx))
+	if _, err := users.DeleteUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	if _, err := users.PurgeUser(ctx, user.ID); err != nil {
+		return nil, err
+	}
+	tombstone.Removed["user"] = 1
+	tombstone.ErasedAt = time.Now().UTC()
+
+	data, err := json.Marshal(tombstone)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redisClient.Set(ctx, tombstoneKey(ctx, user.ID), data, 0).Err(); err != nil {
+		return nil, err
+	}
+
+	// Like the tombstone, the record keeps none of the erased data
+	audit.Record(ctx, s.auditLog, "user.erase", "user:"+user.ID, nil, nil)
+	return tombstone, nil
+}
+
+// user falls back to the trash, deleted users can still be exported and
+// erased until the trash expires
+func (s *privacyService) user(ctx context.Context, id string) (*model.User, error) {
+	users := s.userRepository.ForTenant(tenant.ID(ctx))
+	resp, err := users.GetUser(ctx, id)
+	if err == repository.ErrNotFound {
+		entry, err := users.GetTrashedUser(ctx, id)
+		if err == repository.ErrNotFound {
+			return nil, ErrUserNotFound
+		}
+		if err != nil {
+			return nil, err
+		}
+		return entry.User, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return resp.User, nil
+}
+
+func (s *privacyService) tombstone(ctx context.Context, id string) (*model.ErasureTombstone, error) {
+	data, err := s.redisClient.Get(ctx, tombstoneKey(ctx, id)).Bytes()
+	if err != nil {
+		return nil, err
+	}
+	var tombstone model.ErasureTombstone
+	if err := json.Unmarshal(data, &tombstone); err != nil {
+		return nil, err
+	}
+	return &tombstone, nil
+}
+
+func tombstoneKey(ctx context.Context, id string) string {
+	return tenant.Key(ctx, fmt.Sprintf("tombstone:user:%s", id))
+}
@@ -0,0 +1,218 @@
+package privacy
+
+import (
+	"context"
+	"music-store/internal/audit"
+	"music-store/internal/auth"
+	"music-store/internal/experiment"
+	"music-store/internal/model"
+	"music-store/internal/repository"
+	"music-store/internal/revision"
+	"music-store/internal/tenant"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// accountSource holds the login of users who signed up with a password
+type accountSource struct {
+	credentialRepository   repository.CredentialRepository
+	refreshTokenRepository repository.RefreshTokenRepository
+}
+
+func NewAccountSource(credentialRepository repository.CredentialRepository, refreshTokenRepository repository.RefreshTokenRepository) Source {
+	return &accountSource{credentialRepository: credentialRepository, refreshTokenRepository: refreshTokenRepository}
+}
+
+func (s *accountSource) Name() string { return "account" }
+
+// Export leaves out the password hash
+func (s *accountSource) Export(ctx context.Context, user *model.User) (interface{}, error) {
+	if user.Username == "" {
+		return nil, nil
+	}
+	credential, err := s.credentialRepository.ForTenant(tenant.ID(ctx)).GetCredential(ctx, user.Username)
+	if err == redis.Nil {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{
+		"username":   credential.Username,
+		"roles":      credential.Roles,
+		"created_at": credential.CreatedAt,
+	}, nil
+}
+
+// Erase frees the username and ends every session of the user
+func (s *accountSource) Erase(ctx context.Context, user *model.User) (int, error) {
+	if err := s.refreshTokenRepository.ForTenant(tenant.ID(ctx)).RevokeUserTokens(ctx, user.ID); err != nil {
+		return 0, err
+	}
+	if user.Username == "" {
+		return 0, nil
+	}
+	if _, err := s.credentialRepository.ForTenant(tenant.ID(ctx)).DeleteCredential(ctx, user.Username); err != nil {
+		return 0, err
+	}
+	return 1, nil
+}
+
+type experimentSource struct {
+	experiments experiment.Service
+}
+
+func NewExperimentSource(experiments experiment.Service) Source {
+	return &experimentSource{experiments: experiments}
+}
+
+func (s *experimentSource) Name() string { return "experiments" }
+
+func (s *experimentSource) Export(ctx context.Context, user *model.User) (interface{}, error) {
+	return s.experiments.ExportUser(ctx, user.ID)
+}
+
+func (s *experimentSource) Erase(ctx context.Context, user *model.User) (int, error) {
+	return s.experiments.ForgetUser(ctx, user.ID)
+}
+
+// auditSource covers records the user made and records about the user
+type auditSource struct {
+	log audit.Log
+}
+
+func NewAuditSource(auditLog audit.Log) Source {
+	return &auditSource{log: auditLog}
+}
+
+func (s *auditSource) Name() string { return "audit" }
+
+func (s *auditSource) Export(ctx context.Context, user *model.User) (interface{}, error) {
+	about, err := s.log.Query(ctx, &model.GetAuditLogRequest{Target: "user:" + user.ID, Limit: -1})
+	if err != nil {
+		return nil, err
+	}
+	by, err := s.log.Query(ctx, &model.GetAuditLogRequest{Actor: user.ID, Limit: -1})
+	if err != nil {
+		return nil, err
+	}
+	return map[string]interface{}{"about_user": about.Records, "by_user": by.Records}, nil
+}
+
+func (s *auditSource) Erase(ctx context.Context, user *model.User) (int, error) {
+	return s.log.Forget(ctx, user.ID, "user:"+user.ID)
+}
+
+type revisionSource struct {
+	store revision.Store
+}
+
+// NewRevisionSource covers song revisions made by the user. Erasure keeps
+// the catalog history and only drops the user's name from it.
+func NewRevisionSource(store revision.Store) Source {
+	return &revisionSource{store: store}
+}
+
+func (s *revisionSource) Name() string { return "song_revisions" }
+
+func (s *revisionSource) Export(ctx context.Context, user *model.User) (interface{}, error) {
+	return s.store.ByActor(ctx, user.ID)
+}
+
+func (s *revisionSource) Erase(ctx context.Context, user *model.User) (int, error) {
+	return s.store.ForgetActor(ctx, user.ID)
+}
+
+type apiKeySource struct {
+	keys *auth.APIKeyStore
+}
+
+func NewAPIKeySource(keys *auth.APIKeyStore) Source {
+	return &apiKeySource{keys: keys}
+}
+
+func (s *apiKeySource) Name() string { return "api_keys" }
+
+func (s *apiKeySource) Export(ctx context.Context, user *model.User) (interface{}, error) {
+	return s.keys.ListBySubject(ctx, user.ID)
+}
+
+func (s *apiKeySource) Erase(ctx context.Context, user *model.User) (int, error) {
+	keys, err := s.keys.ListBySubject(ctx, user.ID)
+	if err != nil {
+		return 0, err
+	}
+	for _, key := range keys {
+		if err := s.keys.Revoke(ctx, key.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(keys), nil
+}
+
+// songTrashSource covers the likes kept with deleted songs, which are given
+// back when a song is restored
+type songTrashSource struct {
+	songRepository repository.SongRepository
+}
+
+func NewSongTrashSource(songRepository repository.SongRepository) Source {
+	return &songTrashSource{songRepository: songRepository}
+}
+
+func (s *songTrashSource) Name() string { return "song_trash" }
+
+// Export lists the deleted songs the user liked
+func (s *songTrashSource) Export(ctx context.Context, user *model.User) (interface{}, error) {
+	entries, err := s.songRepository.ForTenant(tenant.ID(ctx)).GetTrashedSongs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	liked := []string{}
+	for _, entry := range entries {
+		if indexOf(entry.LikedBy, user.ID) >= 0 {
+			liked = append(liked, entry.Song.Name)
+		}
+	}
+	return map[string]interface{}{"liked_songs": liked}, nil
+}
+
+func (s *songTrashSource) Erase(ctx context.Context, user *model.User) (int, error) {
+	songs := s.songRepository.ForTenant(tenant.ID(ctx))
+	entries, err := songs.GetTrashedSongs(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if indexOf(entry.LikedBy, user.ID) < 0 {
+			continue
+		}
+		err := songs.ModifyTrashedSong(ctx, entry.Song.Name, func(entry *model.TrashedSong) bool {
+			i := indexOf(entry.LikedBy, user.ID)
+			if i < 0 {
+				return false
+			}
+			entry.LikedBy = append(entry.LikedBy[:i], entry.LikedBy[i+1:]...)
+			return true
+		})
+		if err == repository.ErrNotFound {
+			continue // Restored or expired since
+		}
+		if err != nil {
+			return removed, err
+		}
+		removed++
+	}
+	return removed, nil
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+	return -1
+}
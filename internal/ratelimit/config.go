@@ -0,0 +1,59 @@
+package ratelimit
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Route groups
+const (
+	GroupRead   = "read"
+	GroupWrite  = "write"
+	GroupSearch = "search" // Listings, each one scans the whole keyspace
+	GroupAuth   = "auth"   // Signup and login, keyed by IP
+	GroupIP     = "ip"     // Every request by IP, before it is authenticated
+)
+
+type (
+	// Limit allows Requests per sliding Window
+	Limit struct {
+		Requests int
+		Window   time.Duration
+	}
+
+	// Config holds the limit of every route group and the daily quota of
+	// API keys. A group without a limit is not limited.
+	Config struct {
+		Groups     map[string]Limit
+		DailyQuota int  // Requests per API key per UTC day, 0 disables
+		TrustProxy bool // Identify anonymous clients by X-Forwarded-For
+	}
+)
+
+// ParseLimit parses "<requests>/<window>". "off" disables the limit, a
+// count of zero is rejected rather than read as off.
+func ParseLimit(s string) (Limit, error) {
+	if s == "off" {
+		return Limit{}, nil
+	}
+	requests, window, ok := strings.Cut(s, "/")
+	if !ok {
+		return Limit{}, errors.Errorf("invalid limit %q, expected <requests>/<window>", s)
+	}
+	n, err := strconv.Atoi(requests)
+	if err != nil || n < 1 {
+		return Limit{}, errors.Errorf("invalid request count in limit %q, use off to disable it", s)
+	}
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return Limit{}, errors.Errorf("invalid window in limit %q", s)
+	}
+	return Limit{Requests: n, Window: d}, nil
+}
+
+func (l Limit) enabled() bool {
+	return l.Requests > 0 && l.Window > 0
+}
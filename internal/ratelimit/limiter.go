@@ -0,0 +1,148 @@
+// Package ratelimit throttles clients with a Redis sliding window per route
+// group and enforces daily quotas on API keys.
+package ratelimit
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"music-store/internal/tenant"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// slidingWindow keeps one sorted set entry per request, scored by its time in
+// milliseconds, and admits a request if fewer than the limit remain in the
+// window. It returns {allowed, remaining, milliseconds until a slot frees}.
+var slidingWindow = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local window = tonumber(ARGV[2])
+local limit = tonumber(ARGV[3])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now - window)
+local count = redis.call('ZCARD', KEYS[1])
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', KEYS[1], now, ARGV[4])
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', KEYS[1], window)
+
+local reset = window
+local oldest = redis.call('ZRANGE', KEYS[1], 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, limit - count, reset}
+`)
+
+// Result describes the state of a window or quota after a request
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	Reset     time.Duration // Until the next request would be admitted
+}
+
+type Limiter struct {
+	redisClient redis.UniversalClient
+	config      *Config
+	now         func() time.Time
+}
+
+func NewLimiter(config *Config, redisClient redis.UniversalClient) *Limiter {
+	return &Limiter{redisClient: redisClient, config: config, now: time.Now}
+}
+
+// Allow records a request by client in the window of group. Groups without a
+// limit always allow and return nil.
+func (l *Limiter) Allow(ctx context.Context, group, client string) (*Result, error) {
+	limit := l.limit(ctx, group)
+	if !limit.enabled() {
+		return nil, nil
+	}
+
+	member := make([]byte, 8)
+	if _, err := rand.Read(member); err != nil {
+		return nil, err
+	}
+	now := l.now().UnixMilli()
+
+	values, err := slidingWindow.Run(ctx, l.redisClient,
+		[]string{tenant.Key(ctx, fmt.Sprintf("ratelimit:%s:%s", group, client))},
+		now, limit.Window.Milliseconds(), limit.Requests, fmt.Sprintf("%d-%s", now, hex.EncodeToString(member)),
+	).Int64Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	return &Result{
+		Allowed:   values[0] == 1,
+		Limit:     limit.Requests,
+		Remaining: int(values[1]),
+		Reset:     time.Duration(values[2]) * time.Millisecond,
+	}, nil
+}
+
+// ConsumeQuota counts a request against the daily quota of an API key. The
+// quota resets at midnight UTC. Returns nil when quotas are disabled.
+func (l *Limiter) ConsumeQuota(ctx context.Context, keyID string) (*Result, error) {
+	quota := l.config.DailyQuota
+	if t := tenant.FromContext(ctx); t.DailyQuota != nil {
+		quota = *t.DailyQuota
+	}
+	if quota <= 0 {
+		return nil, nil
+	}
+
+	now := l.now().UTC()
+	key := tenant.Key(ctx, fmt.Sprintf("quota:%s:%s", keyID, now.Format("2006-01-02")))
+
+	pipe := l.redisClient.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, 48*time.Hour)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return nil, err
+	}
+
+	used := int(incr.Val())
+	remaining := quota - used
+	if remaining < 0 {
+		remaining = 0
+	}
+	midnight := time.Date(now.Year(), now.Month(), now.Day()+1, 0, 0, 0, 0, time.UTC)
+
+	return &Result{
+		Allowed:   used <= quota,
+		Limit:     quota,
+		Remaining: remaining,
+		Reset:     midnight.Sub(now),
+	}, nil
+}
+
+// limit returns the limit of group, overridden by the tenant in ctx.
+// CheckTenants makes sure the overrides parse.
+func (l *Limiter) limit(ctx context.Context, group string) Limit {
+	if raw, ok := tenant.FromContext(ctx).Limits[group]; ok {
+		if limit, err := ParseLimit(raw); err == nil {
+			return limit
+		}
+	}
+	return l.config.Groups[group]
+}
+
+// CheckTenants validates the limit overrides of every tenant
+func CheckTenants(registry *tenant.Registry) error {
+	for _, t := range registry.Tenants {
+		for group, raw := range t.Limits {
+			if _, err := ParseLimit(raw); err != nil {
+				return errors.Wrapf(err, "tenant %s, group %s", t.ID, group)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,490 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"sync"
+	"testing"
+	"time"
+
+	"music-store/internal/model"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// backend is one storage backend under test. expire moves the backend's
+// clock past a trash TTL.
+type backend struct {
+	name string
+	open func(t *testing.T) (*Storage, func(time.Duration))
+}
+
+var backends = []backend{
+	{name: BackendRedis, open: func(t *testing.T) (*Storage, func(time.Duration)) {
+		server := miniredis.RunT(t)
+		client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+		t.Cleanup(func() { client.Close() })
+		return openStorage(t, &Config{Backend: BackendRedis}, client), server.FastForward
+	}},
+	{name: BackendMemory, open: func(t *testing.T) (*Storage, func(time.Duration)) {
+		return openStorage(t, &Config{Backend: BackendMemory}, nil), time.Sleep
+	}},
+	{name: BackendSQLite, open: func(t *testing.T) (*Storage, func(time.Duration)) {
+		path := filepath.Join(t.TempDir(), "music-store.db")
+		return openStorage(t, &Config{Backend: BackendSQLite, SQLitePath: path}, nil), time.Sleep
+	}},
+}
+
+func openStorage(t *testing.T, config *Config, client redis.UniversalClient) *Storage {
+	t.Helper()
+	storage, err := Open(config, client)
+	if err != nil {
+		t.Fatalf("opening %s storage: %v", config.Backend, err)
+	}
+	t.Cleanup(func() { storage.Close() })
+	return storage
+}
+
+// TestConformance runs the same cases against every backend, so they can
+// be swapped without the services noticing
+func TestConformance(t *testing.T) {
+	cases := []struct {
+		name string
+		run  func(t *testing.T, storage *Storage, expire func(time.Duration))
+	}{
+		{"SongCRUD", testSongCRUD},
+		{"UserCRUD", testUserCRUD},
+		{"ModifyUser", testModifyUser},
+		{"ConcurrentModifyUser", testConcurrentModifyUser},
+		{"TenantIsolation", testTenantIsolation},
+		{"SongTrash", testSongTrash},
+		{"UserTrash", testUserTrash},
+		{"TrashExpiry", testTrashExpiry},
+		{"Cancelled", testCancelled},
+	}
+
+	for _, b := range backends {
+		b := b
+		t.Run(b.name, func(t *testing.T) {
+			for _, c := range cases {
+				c := c
+				t.Run(c.name, func(t *testing.T) {
+					storage, expire := b.open(t)
+					c.run(t, storage, expire)
+				})
+			}
+		})
+	}
+}
+
+func testSongCRUD(t *testing.T, storage *Storage, _ func(time.Duration)) {
+	ctx := context.Background()
+	songs := storage.Songs
+
+	if _, err := songs.GetSong(ctx, "missing"); err != ErrNotFound {
+		t.Fatalf("GetSong(missing) error = %v, want ErrNotFound", err)
+	}
+
+	a := model.Song{Name: "a", Artist: "Ann", Genre: "rock", Embedding: []float64{1, 0}, ReleaseDate: "2024-01-02"}
+	b := model.Song{Name: "b", Embedding: []float64{0, 1}}
+	mustSucceed(t, "CreateSong")(songs.CreateSong(ctx, &model.CreateSongRequest{Song: a}))
+	mustSucceed(t, "CreateSong")(songs.CreateSong(ctx, &model.CreateSongRequest{Song: b}))
+
+	got, err := songs.GetSong(ctx, "a")
+	if err != nil {
+		t.Fatalf("GetSong(a): %v", err)
+	}
+	if !reflect.DeepEqual(*got.Song, a) {
+		t.Fatalf("GetSong(a) = %+v, want %+v", *got.Song, a)
+	}
+
+	// The path name wins, the body name is only a fallback
+	a.Genre = "jazz"
+	mustSucceed(t, "UpdateSong")(songs.UpdateSong(ctx, &model.UpdateSongRequest{Name: "a", Song: a}))
+	b.Artist = "Bob"
+	mustSucceed(t, "UpdateSong")(songs.UpdateSong(ctx, &model.UpdateSongRequest{Song: b}))
+
+	assertSongs(t, songs, a, b)
+
+	mustSucceed(t, "DeleteSong")(songs.DeleteSong(ctx, "a"))
+	mustSucceed(t, "DeleteSong")(songs.DeleteSong(ctx, "a"))
+	if _, err := songs.GetSong(ctx, "a"); err != ErrNotFound {
+		t.Fatalf("GetSong after delete error = %v, want ErrNotFound", err)
+	}
+	assertSongs(t, songs, b)
+}
+
+func testUserCRUD(t *testing.T, storage *Storage, _ func(time.Duration)) {
+	ctx := context.Background()
+	users := storage.Users
+
+	if _, err := users.GetUser(ctx, "missing"); err != ErrNotFound {
+		t.Fatalf("GetUser(missing) error = %v, want ErrNotFound", err)
+	}
+
+	u1 := model.User{ID: "u1", Name: "One", LikedSongs: []string{"a"}}
+	u2 := model.User{ID: "u2", Name: "Two"}
+	mustSucceed(t, "CreateUser")(users.CreateUser(ctx, &model.CreateUserRequest{User: u1}))
+	mustSucceed(t, "CreateUser")(users.CreateUser(ctx, &model.CreateUserRequest{User: u2}))
+
+	got, err := users.GetUser(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetUser(u1): %v", err)
+	}
+	if !reflect.DeepEqual(*got.User, u1) {
+		t.Fatalf("GetUser(u1) = %+v, want %+v", *got.User, u1)
+	}
+
+	// An update without an ID in the body takes the one from the path
+	update := model.User{Name: "Two", DislikedSongs: []string{"b"}}
+	mustSucceed(t, "UpdateUser")(users.UpdateUser(ctx, &model.UpdateUserRequest{ID: "u2", User: update}))
+	u2.DislikedSongs = []string{"b"}
+
+	assertUsers(t, users, u1, u2)
+
+	mustSucceed(t, "DeleteUser")(users.DeleteUser(ctx, "u1"))
+	mustSucceed(t, "DeleteUser")(users.DeleteUser(ctx, "u1"))
+	assertUsers(t, users, u2)
+}
+
+func testModifyUser(t *testing.T, storage *Storage, _ func(time.Duration)) {
+	ctx := context.Background()
+	users := storage.Users
+
+	err := users.ModifyUser(ctx, "missing", func(*model.User) bool { return true })
+	if err != ErrNotFound {
+		t.Fatalf("ModifyUser(missing) error = %v, want ErrNotFound", err)
+	}
+
+	mustSucceed(t, "CreateUser")(users.CreateUser(ctx, &model.CreateUserRequest{User: model.User{ID: "u1", Name: "One"}}))
+	if err := users.ModifyUser(ctx, "u1", func(user *model.User) bool {
+		user.LikedSongs = append(user.LikedSongs, "a")
+		return true
+	}); err != nil {
+		t.Fatalf("ModifyUser: %v", err)
+	}
+	assertUsers(t, users, model.User{ID: "u1", Name: "One", LikedSongs: []string{"a"}})
+
+	// Returning false discards the change
+	if err := users.ModifyUser(ctx, "u1", func(user *model.User) bool {
+		user.Name = "Changed"
+		return false
+	}); err != nil {
+		t.Fatalf("ModifyUser: %v", err)
+	}
+	assertUsers(t, users, model.User{ID: "u1", Name: "One", LikedSongs: []string{"a"}})
+}
+
+func testConcurrentModifyUser(t *testing.T, storage *Storage, _ func(time.Duration)) {
+	ctx := context.Background()
+	users := storage.Users
+	mustSucceed(t, "CreateUser")(users.CreateUser(ctx, &model.CreateUserRequest{User: model.User{ID: "u1"}}))
+
+	const writers = 20
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for i := 0; i < writers; i++ {
+		wg.Add(1)
+		go func(song string) {
+			defer wg.Done()
+			errs <- users.ModifyUser(ctx, "u1", func(user *model.User) bool {
+				user.LikedSongs = append(user.LikedSongs, song)
+				return true
+			})
+		}(fmt.Sprintf("song-%02d", i))
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("ModifyUser: %v", err)
+		}
+	}
+
+	got, err := users.GetUser(ctx, "u1")
+	if err != nil {
+		t.Fatalf("GetUser: %v", err)
+	}
+	if len(got.User.LikedSongs) != writers {
+		t.Fatalf("got %d likes after %d concurrent modifications: %v", len(got.User.LikedSongs), writers, got.User.LikedSongs)
+	}
+}
+
+func testTenantIsolation(t *testing.T, storage *Storage, _ func(time.Duration)) {
+	ctx := context.Background()
+	acme := storage.Songs.ForTenant("acme")
+	mustSucceed(t, "CreateSong")(storage.Songs.CreateSong(ctx, &model.CreateSongRequest{Song: model.Song{Name: "a"}}))
+	mustSucceed(t, "CreateSong")(acme.CreateSong(ctx, &model.CreateSongRequest{Song: model.Song{Name: "b"}}))
+
+	assertSongs(t, storage.Songs, model.Song{Name: "a"})
+	assertSongs(t, acme, model.Song{Name: "b"})
+	if _, err := acme.GetSong(ctx, "a"); err != ErrNotFound {
+		t.Fatalf("tenant read another tenant's song, error = %v", err)
+	}
+
+	acmeUsers := storage.Users.ForTenant("acme")
+	mustSucceed(t, "CreateUser")(acmeUsers.CreateUser(ctx, &model.CreateUserRequest{User: model.User{ID: "u1"}}))
+	assertUsers(t, storage.Users)
+	assertUsers(t, storage.Users.ForTenant("globex"))
+	assertUsers(t, acmeUsers, model.User{ID: "u1"})
+}
+
+func testSongTrash(t *testing.T, storage *Storage, _ func(time.Duration)) {
+	ctx := context.Background()
+	songs := storage.Songs
+	song := model.Song{Name: "a", Artist: "Ann"}
+	mustSucceed(t, "CreateSong")(songs.CreateSong(ctx, &model.CreateSongRequest{Song: song}))
+
+	deletedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	entry := &model.TrashedSong{Song: &song, LikedBy: []string{"u1"}, DeletedAt: deletedAt, DeletedBy: "admin", ExpiresAt: deletedAt.Add(time.Hour)}
+	mustSucceed(t, "TrashSong")(songs.TrashSong(ctx, entry, time.Hour))
+
+	if _, err := songs.GetSong(ctx, "a"); err != ErrNotFound {
+		t.Fatalf("GetSong of trashed song error = %v, want ErrNotFound", err)
+	}
+	assertSongs(t, songs)
+
+	trashed, err := songs.GetTrashedSong(ctx, "a")
+	if err != nil {
+		t.Fatalf("GetTrashedSong: %v", err)
+	}
+	if !reflect.DeepEqual(trashed, entry) {
+		t.Fatalf("GetTrashedSong = %+v, want %+v", trashed, entry)
+	}
+	all, err := songs.GetTrashedSongs(ctx)
+	if err != nil || len(all) != 1 || all[0].Song.Name != "a" {
+		t.Fatalf("GetTrashedSongs = %v, %v, want the trashed song", all, err)
+	}
+	if all, _ := songs.ForTenant("acme").GetTrashedSongs(ctx); len(all) != 0 {
+		t.Fatalf("tenant sees another tenant's trash: %v", all)
+	}
+
+	err = songs.ModifyTrashedSong(ctx, "a", func(entry *model.TrashedSong) bool {
+		entry.LikedBy = append(entry.LikedBy, "u2")
+		return true
+	})
+	if err != nil {
+		t.Fatalf("ModifyTrashedSong: %v", err)
+	}
+	if trashed, _ := songs.GetTrashedSong(ctx, "a"); !reflect.DeepEqual(trashed.LikedBy, []string{"u1", "u2"}) {
+		t.Fatalf("LikedBy after ModifyTrashedSong = %v, want [u1 u2]", trashed.LikedBy)
+	}
+	songs.ModifyTrashedSong(ctx, "a", func(entry *model.TrashedSong) bool {
+		entry.LikedBy = entry.LikedBy[:1]
+		return true
+	})
+	if err := songs.ModifyTrashedSong(ctx, "missing", func(*model.TrashedSong) bool { return true }); err != ErrNotFound {
+		t.Fatalf("ModifyTrashedSong of missing entry error = %v, want ErrNotFound", err)
+	}
+
+	// A song created under the same name blocks the restore
+	mustSucceed(t, "CreateSong")(songs.CreateSong(ctx, &model.CreateSongRequest{Song: model.Song{Name: "a", Artist: "New"}}))
+	if _, err := songs.RestoreSong(ctx, "a"); err != ErrAlreadyExists {
+		t.Fatalf("RestoreSong over a live song error = %v, want ErrAlreadyExists", err)
+	}
+	mustSucceed(t, "DeleteSong")(songs.DeleteSong(ctx, "a"))
+
+	restored, err := songs.RestoreSong(ctx, "a")
+	if err != nil {
+		t.Fatalf("RestoreSong: %v", err)
+	}
+	if !reflect.DeepEqual(restored.LikedBy, []string{"u1"}) {
+		t.Fatalf("RestoreSong LikedBy = %v, want [u1]", restored.LikedBy)
+	}
+	assertSongs(t, songs, song)
+	if _, err := songs.GetTrashedSong(ctx, "a"); err != ErrNotFound {
+		t.Fatalf("GetTrashedSong after restore error = %v, want ErrNotFound", err)
+	}
+	if _, err := songs.RestoreSong(ctx, "a"); err != ErrNotFound {
+		t.Fatalf("second RestoreSong error = %v, want ErrNotFound", err)
+	}
+
+	mustSucceed(t, "TrashSong")(songs.TrashSong(ctx, entry, time.Hour))
+	mustSucceed(t, "PurgeSong")(songs.PurgeSong(ctx, "a"))
+	mustSucceed(t, "PurgeSong")(songs.PurgeSong(ctx, "a"))
+	if _, err := songs.GetTrashedSong(ctx, "a"); err != ErrNotFound {
+		t.Fatalf("GetTrashedSong after purge error = %v, want ErrNotFound", err)
+	}
+}
+
+func testUserTrash(t *testing.T, storage *Storage, _ func(time.Duration)) {
+	ctx := context.Background()
+	users := storage.Users
+	user := model.User{ID: "u1", Name: "One", LikedSongs: []string{"a"}}
+	mustSucceed(t, "CreateUser")(users.CreateUser(ctx, &model.CreateUserRequest{User: user}))
+
+	deletedAt := time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC)
+	entry := &model.TrashedUser{User: &user, DeletedAt: deletedAt, ExpiresAt: deletedAt.Add(time.Hour)}
+	mustSucceed(t, "TrashUser")(users.TrashUser(ctx, entry, time.Hour))
+	assertUsers(t, users)
+
+	if got, err := users.GetTrashedUser(ctx, "u1"); err != nil || !reflect.DeepEqual(got, entry) {
+		t.Fatalf("GetTrashedUser = %+v, %v, want %+v", got, err, entry)
+	}
+	trashed, err := users.GetTrashedUsers(ctx)
+	if err != nil || len(trashed) != 1 || !reflect.DeepEqual(trashed[0], entry) {
+		t.Fatalf("GetTrashedUsers = %v, %v, want the trashed user", trashed, err)
+	}
+
+	mustSucceed(t, "CreateUser")(users.CreateUser(ctx, &model.CreateUserRequest{User: model.User{ID: "u1"}}))
+	if _, err := users.RestoreUser(ctx, "u1"); err != ErrAlreadyExists {
+		t.Fatalf("RestoreUser over a live user error = %v, want ErrAlreadyExists", err)
+	}
+	mustSucceed(t, "DeleteUser")(users.DeleteUser(ctx, "u1"))
+
+	if _, err := users.RestoreUser(ctx, "u1"); err != nil {
+		t.Fatalf("RestoreUser: %v", err)
+	}
+	assertUsers(t, users, user)
+
+	mustSucceed(t, "TrashUser")(users.TrashUser(ctx, entry, time.Hour))
+	mustSucceed(t, "PurgeUser")(users.PurgeUser(ctx, "u1"))
+	if _, err := users.RestoreUser(ctx, "u1"); err != ErrNotFound {
+		t.Fatalf("RestoreUser after purge error = %v, want ErrNotFound", err)
+	}
+}
+
+// A request that went away does no more work
+func testCancelled(t *testing.T, storage *Storage, _ func(time.Duration)) {
+	mustSucceed(t, "CreateUser")(storage.Users.CreateUser(context.Background(), &model.CreateUserRequest{User: model.User{ID: "u1"}}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := storage.Songs.CreateSong(ctx, &model.CreateSongRequest{Song: model.Song{Name: "a"}}); !errors.Is(err, context.Canceled) {
+		t.Errorf("CreateSong error = %v, want context.Canceled", err)
+	}
+	if _, err := storage.Songs.GetAllSongs(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("GetAllSongs error = %v, want context.Canceled", err)
+	}
+	called := false
+	err := storage.Users.ModifyUser(ctx, "u1", func(*model.User) bool { called = true; return true })
+	if !errors.Is(err, context.Canceled) || called {
+		t.Errorf("ModifyUser error = %v, called = %t, want context.Canceled before reading", err, called)
+	}
+	if _, err := storage.Users.TrashUser(ctx, &model.TrashedUser{User: &model.User{ID: "u1"}}, time.Hour); !errors.Is(err, context.Canceled) {
+		t.Errorf("TrashUser error = %v, want context.Canceled", err)
+	}
+
+	assertSongs(t, storage.Songs)
+	assertUsers(t, storage.Users, model.User{ID: "u1"})
+}
+
+func testTrashExpiry(t *testing.T, storage *Storage, expire func(time.Duration)) {
+	ctx := context.Background()
+	song := model.Song{Name: "a"}
+	mustSucceed(t, "CreateSong")(storage.Songs.CreateSong(ctx, &model.CreateSongRequest{Song: song}))
+	mustSucceed(t, "TrashSong")(storage.Songs.TrashSong(ctx, &model.TrashedSong{Song: &song}, 50*time.Millisecond))
+	user := model.User{ID: "u1"}
+	mustSucceed(t, "CreateUser")(storage.Users.CreateUser(ctx, &model.CreateUserRequest{User: user}))
+	mustSucceed(t, "TrashUser")(storage.Users.TrashUser(ctx, &model.TrashedUser{User: &user}, 50*time.Millisecond))
+	// Modifying an entry keeps its expiry
+	if err := storage.Songs.ModifyTrashedSong(ctx, "a", func(entry *model.TrashedSong) bool {
+		entry.LikedBy = []string{"u1"}
+		return true
+	}); err != nil {
+		t.Fatalf("ModifyTrashedSong: %v", err)
+	}
+
+	expire(100 * time.Millisecond)
+
+	if _, err := storage.Songs.GetTrashedSong(ctx, "a"); err != ErrNotFound {
+		t.Fatalf("GetTrashedSong after expiry error = %v, want ErrNotFound", err)
+	}
+	if all, err := storage.Songs.GetTrashedSongs(ctx); err != nil || len(all) != 0 {
+		t.Fatalf("GetTrashedSongs after expiry = %v, %v, want none", all, err)
+	}
+	if _, err := storage.Users.RestoreUser(ctx, "u1"); err != ErrNotFound {
+		t.Fatalf("RestoreUser after expiry error = %v, want ErrNotFound", err)
+	}
+}
+
+// TestSQLiteMigrations checks that reopening a database leaves its schema
+// and data alone
+func TestSQLiteMigrations(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "music-store.db")
+	config := &Config{Backend: BackendSQLite, SQLitePath: path}
+
+	storage, err := Open(config, nil)
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	mustSucceed(t, "CreateSong")(storage.Songs.CreateSong(ctx, &model.CreateSongRequest{Song: model.Song{Name: "a"}}))
+	storage.Close()
+
+	storage = openStorage(t, config, nil)
+	assertSongs(t, storage.Songs, model.Song{Name: "a"})
+
+	documents := storage.Songs.(*documentSongRepository).documents.(*sqliteDocuments)
+	var version int
+	if err := documents.db.QueryRow(`SELECT MAX(version) FROM schema_migrations`).Scan(&version); err != nil {
+		t.Fatalf("reading schema version: %v", err)
+	}
+	if version != len(migrations) {
+		t.Fatalf("schema version = %d, want %d", version, len(migrations))
+	}
+}
+
+func TestOpenUnknownBackend(t *testing.T) {
+	if _, err := Open(&Config{Backend: "cassandra"}, nil); err == nil {
+		t.Fatal("Open accepted an unknown backend")
+	}
+}
+
+func mustSucceed(t *testing.T, op string) func(string, error) {
+	t.Helper()
+	return func(msg string, err error) {
+		t.Helper()
+		if err != nil || msg != "success" {
+			t.Fatalf("%s = %q, %v, want success", op, msg, err)
+		}
+	}
+}
+
+// assertSongs compares the listing with want, ignoring order since Redis
+// lists keys unordered
+func assertSongs(t *testing.T, songs SongRepository, want ...model.Song) {
+	t.Helper()
+	ctx := context.Background()
+	resp, err := songs.GetAllSongs(ctx)
+	if err != nil {
+		t.Fatalf("GetAllSongs: %v", err)
+	}
+	got := make([]model.Song, 0, len(resp.Songs))
+	for _, song := range resp.Songs {
+		got = append(got, *song)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].Name < got[j].Name })
+	if want == nil {
+		want = []model.Song{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetAllSongs = %+v, want %+v", got, want)
+	}
+}
+
+func assertUsers(t *testing.T, users UserRepository, want ...model.User) {
+	t.Helper()
+	ctx := context.Background()
+	resp, err := users.GetAllUsers(ctx)
+	if err != nil {
+		t.Fatalf("GetAllUsers: %v", err)
+	}
+	got := make([]model.User, 0, len(resp.Users))
+	for _, user := range resp.Users {
+		got = append(got, *user)
+	}
+	sort.Slice(got, func(i, j int) bool { return got[i].ID < got[j].ID })
+	if want == nil {
+		want = []model.User{}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("GetAllUsers = %+v, want %+v", got, want)
+	}
+}
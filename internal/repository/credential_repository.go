@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"music-store/internal/model"
+	"music-store/internal/tenant"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+var ErrUsernameTaken = errors.New("username already taken")
+
+type CredentialRepository interface {
+	// ForTenant returns the repository scoped to the keyspace of a tenant.
+	// The repository from the constructor serves the default tenant.
+	ForTenant(tenantID string) CredentialRepository
+	CreateCredential(ctx context.Context, credential *model.Credential) (string, error)
+	GetCredential(ctx context.Context, username string) (*model.Credential, error)
+	DeleteCredential(ctx context.Context, username string) (string, error)
+}
+
+type credentialRepository struct {
+	redisClient redis.UniversalClient
+	prefix      string // Tenant key prefix, empty for the default tenant
+}
+
+func NewCredentialRepository(redisClient redis.UniversalClient) CredentialRepository {
+	return &credentialRepository{redisClient: redisClient}
+}
+
+func (r *credentialRepository) ForTenant(tenantID string) CredentialRepository {
+	return &credentialRepository{redisClient: r.redisClient, prefix: tenant.Prefix(tenantID)}
+}
+
+func (r *credentialRepository) CreateCredential(ctx context.Context, credential *model.Credential) (string, error) {
+	credentialJSON, err := json.Marshal(credential)
+	if err != nil {
+		return "Error marshaling credential data", err
+	}
+
+	// Store in Redis using namespaced key: credential:{username}, only if it is free
+	key := r.prefix + fmt.Sprintf("credential:%s", credential.Username)
+	created, err := r.redisClient.SetNX(ctx, key, credentialJSON, 0).Result()
+	if err != nil {
+		return "Error creating credential", err
+	}
+	if !created {
+		return "Username already taken", ErrUsernameTaken
+	}
+	return "success", nil
+}
+
+func (r *credentialRepository) GetCredential(ctx context.Context, username string) (*model.Credential, error) {
+	key := r.prefix + fmt.Sprintf("credential:%s", username)
+	credentialJSON, err := r.redisClient.Get(ctx, key).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var credential model.Credential
+	if err := json.Unmarshal([]byte(credentialJSON), &credential); err != nil {
+		return nil, err
+	}
+	return &credential, nil
+}
+
+func (r *credentialRepository) DeleteCredential(ctx context.Context, username string) (string, error) {
+	key := r.prefix + fmt.Sprintf("credential:%s", username)
+	_, err := r.redisClient.Del(ctx, key).Result()
+	if err != nil {
+		return "Error deleting credential", err
+	}
+	return "success", nil
+}
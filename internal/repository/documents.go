@@ -0,0 +1,345 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"music-store/internal/model"
+	"time"
+)
+
+// Record kinds of a documents store
+const (
+	kindSong = "song"
+	kindUser = "user"
+)
+
+// documents is a store of JSON records by kind and key, with a trash beside
+// the live records. The memory and SQLite backends implement it, and share
+// the repositories below so they behave the same.
+type documents interface {
+	forTenant(tenantID string) documents
+	// get returns ErrNotFound for a missing record
+	get(ctx context.Context, kind, key string) ([]byte, error)
+	put(ctx context.Context, kind, key string, data []byte) error
+	// modify replaces a record with what fn returns, atomically. A nil
+	// result leaves the record alone. It returns ErrNotFound for a missing
+	// record.
+	modify(ctx context.Context, kind, key string, fn func(data []byte) ([]byte, error)) error
+	delete(ctx context.Context, kind, key string) error
+	// list returns every record of a kind ordered by key
+	list(ctx context.Context, kind string) ([][]byte, error)
+	// trash replaces the live record with entry until ttl passes
+	trash(ctx context.Context, kind, key string, entry []byte, ttl time.Duration) error
+	// getTrash returns ErrNotFound for a missing or expired entry
+	getTrash(ctx context.Context, kind, key string) ([]byte, error)
+	listTrash(ctx context.Context, kind string) ([][]byte, error)
+	// modifyTrash is modify for trash entries, it keeps their expiry
+	modifyTrash(ctx context.Context, kind, key string, fn func(data []byte) ([]byte, error)) error
+	// restore writes record back as the live record and drops the trash
+	// entry. It returns ErrAlreadyExists when the live key is taken.
+	restore(ctx context.Context, kind, key string, record []byte) error
+	purge(ctx context.Context, kind, key string) error
+}
+
+type documentSongRepository struct {
+	documents documents
+}
+
+func newDocumentSongRepository(documents documents) SongRepository {
+	return &documentSongRepository{documents: documents}
+}
+
+func (r *documentSongRepository) ForTenant(tenantID string) SongRepository {
+	return &documentSongRepository{documents: r.documents.forTenant(tenantID)}
+}
+
+func (r *documentSongRepository) CreateSong(ctx context.Context, song *model.CreateSongRequest) (string, error) {
+	songJSON, err := json.Marshal(&song.Song)
+	if err != nil {
+		return "Error marshaling song data", err
+	}
+	if err := r.documents.put(ctx, kindSong, song.Song.Name, songJSON); err != nil {
+		return "Error creating song", err
+	}
+	return "success", nil
+}
+
+func (r *documentSongRepository) GetSong(ctx context.Context, name string) (*model.GetSongResponse, error) {
+	songJSON, err := r.documents.get(ctx, kindSong, name)
+	if err != nil {
+		return nil, err
+	}
+
+	var song model.Song
+	if err := json.Unmarshal(songJSON, &song); err != nil {
+		return nil, err
+	}
+	return &model.GetSongResponse{Song: &song}, nil
+}
+
+func (r *documentSongRepository) GetAllSongs(ctx context.Context) (*model.GetSongListResponse, error) {
+	records, err := r.documents.list(ctx, kindSong)
+	if err != nil {
+		return nil, err
+	}
+
+	var songs []*model.Song
+	for _, songJSON := range records {
+		var song model.Song
+		if err := json.Unmarshal(songJSON, &song); err != nil || song.Name == "" {
+			continue // Skip malformed data
+		}
+		songs = append(songs, &song)
+	}
+	return &model.GetSongListResponse{Songs: songs}, nil
+}
+
+func (r *documentSongRepository) UpdateSong(ctx context.Context, song *model.UpdateSongRequest) (string, error) {
+	// Use the Name from the path parameter if the song name is empty
+	name := song.Name
+	if name == "" && song.Song.Name != "" {
+		name = song.Song.Name
+	}
+
+	songJSON, err := json.Marshal(&song.Song)
+	if err != nil {
+		return "Error marshaling song data", err
+	}
+	if err := r.documents.put(ctx, kindSong, name, songJSON); err != nil {
+		return "Error updating song", err
+	}
+	return "success", nil
+}
+
+func (r *documentSongRepository) DeleteSong(ctx context.Context, name string) (string, error) {
+	if err := r.documents.delete(ctx, kindSong, name); err != nil {
+		return "Error deleting song", err
+	}
+	return "success", nil
+}
+
+func (r *documentSongRepository) TrashSong(ctx context.Context, entry *model.TrashedSong, ttl time.Duration) (string, error) {
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return "Error deleting song", err
+	}
+	if err := r.documents.trash(ctx, kindSong, entry.Song.Name, entryJSON, ttl); err != nil {
+		return "Error deleting song", err
+	}
+	return "success", nil
+}
+
+func (r *documentSongRepository) GetTrashedSong(ctx context.Context, name string) (*model.TrashedSong, error) {
+	entryJSON, err := r.documents.getTrash(ctx, kindSong, name)
+	if err != nil {
+		return nil, err
+	}
+	var entry model.TrashedSong
+	if err := json.Unmarshal(entryJSON, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *documentSongRepository) GetTrashedSongs(ctx context.Context) ([]*model.TrashedSong, error) {
+	records, err := r.documents.listTrash(ctx, kindSong)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*model.TrashedSong
+	for _, entryJSON := range records {
+		var entry model.TrashedSong
+		if err := json.Unmarshal(entryJSON, &entry); err != nil || entry.Song == nil {
+			continue // Malformed
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+func (r *documentSongRepository) ModifyTrashedSong(ctx context.Context, name string, fn func(entry *model.TrashedSong) bool) error {
+	return r.documents.modifyTrash(ctx, kindSong, name, func(entryJSON []byte) ([]byte, error) {
+		var entry model.TrashedSong
+		if err := json.Unmarshal(entryJSON, &entry); err != nil {
+			return nil, err
+		}
+		if !fn(&entry) {
+			return nil, nil
+		}
+		return json.Marshal(&entry)
+	})
+}
+
+func (r *documentSongRepository) RestoreSong(ctx context.Context, name string) (*model.TrashedSong, error) {
+	entry, err := r.GetTrashedSong(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	songJSON, err := json.Marshal(entry.Song)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.documents.restore(ctx, kindSong, name, songJSON); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *documentSongRepository) PurgeSong(ctx context.Context, name string) (string, error) {
+	if err := r.documents.purge(ctx, kindSong, name); err != nil {
+		return "Error purging song", err
+	}
+	return "success", nil
+}
+
+type documentUserRepository struct {
+	documents documents
+}
+
+func newDocumentUserRepository(documents documents) UserRepository {
+	return &documentUserRepository{documents: documents}
+}
+
+func (r *documentUserRepository) ForTenant(tenantID string) UserRepository {
+	return &documentUserRepository{documents: r.documents.forTenant(tenantID)}
+}
+
+func (r *documentUserRepository) CreateUser(ctx context.Context, user *model.CreateUserRequest) (string, error) {
+	userJSON, err := json.Marshal(user.User)
+	if err != nil {
+		return "Error marshaling user data", err
+	}
+	if err := r.documents.put(ctx, kindUser, user.User.ID, userJSON); err != nil {
+		return "Error creating user", err
+	}
+	return "success", nil
+}
+
+func (r *documentUserRepository) GetUser(ctx context.Context, id string) (*model.GetUserResponse, error) {
+	userJSON, err := r.documents.get(ctx, kindUser, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var user model.User
+	if err := json.Unmarshal(userJSON, &user); err != nil {
+		return nil, err
+	}
+	return &model.GetUserResponse{User: &user}, nil
+}
+
+func (r *documentUserRepository) GetAllUsers(ctx context.Context) (*model.GetUserListResponse, error) {
+	records, err := r.documents.list(ctx, kindUser)
+	if err != nil {
+		return nil, err
+	}
+
+	var users []*model.User
+	for _, userJSON := range records {
+		var user model.User
+		if err := json.Unmarshal(userJSON, &user); err != nil {
+			continue // Skip malformed data
+		}
+		users = append(users, &user)
+	}
+	return &model.GetUserListResponse{Users: users}, nil
+}
+
+func (r *documentUserRepository) UpdateUser(ctx context.Context, user *model.UpdateUserRequest) (string, error) {
+	// Set the ID from the path parameter if not already set
+	if user.User.ID == "" {
+		user.User.ID = user.ID
+	}
+
+	userJSON, err := json.Marshal(user.User)
+	if err != nil {
+		return "Error marshaling user data", err
+	}
+	if err := r.documents.put(ctx, kindUser, user.ID, userJSON); err != nil {
+		return "Error updating user", err
+	}
+	return "success", nil
+}
+
+func (r *documentUserRepository) ModifyUser(ctx context.Context, id string, fn func(user *model.User) bool) error {
+	return r.documents.modify(ctx, kindUser, id, func(userJSON []byte) ([]byte, error) {
+		var user model.User
+		if err := json.Unmarshal(userJSON, &user); err != nil {
+			return nil, err
+		}
+		if !fn(&user) {
+			return nil, nil
+		}
+		return json.Marshal(&user)
+	})
+}
+
+func (r *documentUserRepository) DeleteUser(ctx context.Context, id string) (string, error) {
+	if err := r.documents.delete(ctx, kindUser, id); err != nil {
+		return "Error deleting user", err
+	}
+	return "success", nil
+}
+
+func (r *documentUserRepository) TrashUser(ctx context.Context, entry *model.TrashedUser, ttl time.Duration) (string, error) {
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return "Error deleting user", err
+	}
+	if err := r.documents.trash(ctx, kindUser, entry.User.ID, entryJSON, ttl); err != nil {
+		return "Error deleting user", err
+	}
+	return "success", nil
+}
+
+func (r *documentUserRepository) GetTrashedUser(ctx context.Context, id string) (*model.TrashedUser, error) {
+	entryJSON, err := r.documents.getTrash(ctx, kindUser, id)
+	if err != nil {
+		return nil, err
+	}
+	var entry model.TrashedUser
+	if err := json.Unmarshal(entryJSON, &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *documentUserRepository) GetTrashedUsers(ctx context.Context) ([]*model.TrashedUser, error) {
+	records, err := r.documents.listTrash(ctx, kindUser)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*model.TrashedUser
+	for _, entryJSON := range records {
+		var entry model.TrashedUser
+		if err := json.Unmarshal(entryJSON, &entry); err != nil || entry.User == nil {
+			continue // Malformed
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+func (r *documentUserRepository) RestoreUser(ctx context.Context, id string) (*model.TrashedUser, error) {
+	entry, err := r.GetTrashedUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	userJSON, err := json.Marshal(entry.User)
+	if err != nil {
+		return nil, err
+	}
+	if err := r.documents.restore(ctx, kindUser, id, userJSON); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *documentUserRepository) PurgeUser(ctx context.Context, id string) (string, error) {
+	if err := r.documents.purge(ctx, kindUser, id); err != nil {
+		return "Error purging user", err
+	}
+	return "success", nil
+}
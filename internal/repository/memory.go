@@ -0,0 +1,237 @@
+package repository
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	// memoryDocuments keeps every tenant's records in maps guarded by one
+	// lock. Records are stored marshaled, so callers never share values.
+	memoryDocuments struct {
+		root     *memoryRoot
+		tenantID string
+	}
+
+	memoryRoot struct {
+		mu      sync.Mutex
+		tenants map[string]*memoryTenant
+	}
+
+	memoryTenant struct {
+		live  map[string]map[string][]byte // kind, key
+		trash map[string]map[string]memoryTrashEntry
+	}
+
+	memoryTrashEntry struct {
+		data      []byte
+		expiresAt time.Time
+	}
+)
+
+func newMemoryDocuments() *memoryDocuments {
+	return &memoryDocuments{root: &memoryRoot{tenants: map[string]*memoryTenant{}}}
+}
+
+func (d *memoryDocuments) forTenant(tenantID string) documents {
+	return &memoryDocuments{root: d.root, tenantID: tenantID}
+}
+
+// lock takes the lock unless ctx is already done, the maps themselves
+// never block
+func (d *memoryDocuments) lock(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	d.root.mu.Lock()
+	return nil
+}
+
+// tenant must be called with the lock held
+func (d *memoryDocuments) tenant() *memoryTenant {
+	t, ok := d.root.tenants[d.tenantID]
+	if !ok {
+		t = &memoryTenant{
+			live:  map[string]map[string][]byte{kindSong: {}, kindUser: {}},
+			trash: map[string]map[string]memoryTrashEntry{kindSong: {}, kindUser: {}},
+		}
+		d.root.tenants[d.tenantID] = t
+	}
+	return t
+}
+
+func (d *memoryDocuments) get(ctx context.Context, kind, key string) ([]byte, error) {
+	if err := d.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer d.root.mu.Unlock()
+
+	data, ok := d.tenant().live[kind][key]
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return data, nil
+}
+
+func (d *memoryDocuments) put(ctx context.Context, kind, key string, data []byte) error {
+	if err := d.lock(ctx); err != nil {
+		return err
+	}
+	defer d.root.mu.Unlock()
+
+	d.tenant().live[kind][key] = data
+	return nil
+}
+
+func (d *memoryDocuments) modify(ctx context.Context, kind, key string, fn func(data []byte) ([]byte, error)) error {
+	if err := d.lock(ctx); err != nil {
+		return err
+	}
+	defer d.root.mu.Unlock()
+
+	records := d.tenant().live[kind]
+	data, ok := records[key]
+	if !ok {
+		return ErrNotFound
+	}
+	updated, err := fn(data)
+	if err != nil || updated == nil {
+		return err
+	}
+	records[key] = updated
+	return nil
+}
+
+func (d *memoryDocuments) delete(ctx context.Context, kind, key string) error {
+	if err := d.lock(ctx); err != nil {
+		return err
+	}
+	defer d.root.mu.Unlock()
+
+	delete(d.tenant().live[kind], key)
+	return nil
+}
+
+func (d *memoryDocuments) list(ctx context.Context, kind string) ([][]byte, error) {
+	if err := d.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer d.root.mu.Unlock()
+
+	records := d.tenant().live[kind]
+	keys := make([]string, 0, len(records))
+	for key := range records {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = records[key]
+	}
+	return values, nil
+}
+
+func (d *memoryDocuments) trash(ctx context.Context, kind, key string, entry []byte, ttl time.Duration) error {
+	if err := d.lock(ctx); err != nil {
+		return err
+	}
+	defer d.root.mu.Unlock()
+
+	t := d.tenant()
+	t.trash[kind][key] = memoryTrashEntry{data: entry, expiresAt: time.Now().Add(ttl)}
+	delete(t.live[kind], key)
+	return nil
+}
+
+func (d *memoryDocuments) getTrash(ctx context.Context, kind, key string) ([]byte, error) {
+	if err := d.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer d.root.mu.Unlock()
+
+	entry, ok := d.liveTrash(kind, key)
+	if !ok {
+		return nil, ErrNotFound
+	}
+	return entry.data, nil
+}
+
+func (d *memoryDocuments) listTrash(ctx context.Context, kind string) ([][]byte, error) {
+	if err := d.lock(ctx); err != nil {
+		return nil, err
+	}
+	defer d.root.mu.Unlock()
+
+	entries := d.tenant().trash[kind]
+	keys := make([]string, 0, len(entries))
+	for key := range entries {
+		if _, ok := d.liveTrash(kind, key); ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	values := make([][]byte, len(keys))
+	for i, key := range keys {
+		values[i] = entries[key].data
+	}
+	return values, nil
+}
+
+func (d *memoryDocuments) modifyTrash(ctx context.Context, kind, key string, fn func(data []byte) ([]byte, error)) error {
+	if err := d.lock(ctx); err != nil {
+		return err
+	}
+	defer d.root.mu.Unlock()
+
+	entry, ok := d.liveTrash(kind, key)
+	if !ok {
+		return ErrNotFound
+	}
+	updated, err := fn(entry.data)
+	if err != nil || updated == nil {
+		return err
+	}
+	d.tenant().trash[kind][key] = memoryTrashEntry{data: updated, expiresAt: entry.expiresAt}
+	return nil
+}
+
+func (d *memoryDocuments) restore(ctx context.Context, kind, key string, record []byte) error {
+	if err := d.lock(ctx); err != nil {
+		return err
+	}
+	defer d.root.mu.Unlock()
+
+	t := d.tenant()
+	if _, ok := t.live[kind][key]; ok {
+		return ErrAlreadyExists
+	}
+	t.live[kind][key] = record
+	delete(t.trash[kind], key)
+	return nil
+}
+
+func (d *memoryDocuments) purge(ctx context.Context, kind, key string) error {
+	if err := d.lock(ctx); err != nil {
+		return err
+	}
+	defer d.root.mu.Unlock()
+
+	delete(d.tenant().trash[kind], key)
+	return nil
+}
+
+// liveTrash returns the trash entry unless it expired, dropping expired
+// entries as it finds them. It must be called with the lock held.
+func (d *memoryDocuments) liveTrash(kind, key string) (memoryTrashEntry, bool) {
+	entries := d.tenant().trash[kind]
+	entry, ok := entries[key]
+	if ok && !time.Now().Before(entry.expiresAt) {
+		delete(entries, key)
+		return memoryTrashEntry{}, false
+	}
+	return entry, ok
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"music-store/internal/model"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// newRedisRepositories returns repositories on a fresh miniredis, together
+// with the server so tests can write raw keys
+func newRedisRepositories(t *testing.T) (SongRepository, UserRepository, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewSongRepository(client), NewUserRepository(client), server
+}
+
+func TestRedisKeyLayout(t *testing.T) {
+	ctx := context.Background()
+	songs, users, server := newRedisRepositories(t)
+
+	mustSucceed(t, "CreateSong")(songs.CreateSong(ctx, &model.CreateSongRequest{Song: model.Song{Name: "a"}}))
+	mustSucceed(t, "CreateSong")(songs.ForTenant("acme").CreateSong(ctx, &model.CreateSongRequest{Song: model.Song{Name: "b"}}))
+	mustSucceed(t, "CreateUser")(users.CreateUser(ctx, &model.CreateUserRequest{User: model.User{ID: "u1"}}))
+	mustSucceed(t, "TrashUser")(users.TrashUser(ctx, &model.TrashedUser{User: &model.User{ID: "u1"}}, time.Hour))
+
+	for _, key := range []string{"song:a", "tenant:acme:song:b", "trash:user:u1"} {
+		if !server.Exists(key) {
+			t.Errorf("key %s is missing, have %v", key, server.Keys())
+		}
+	}
+	if server.Exists("user:u1") {
+		t.Error("trashed user is still live")
+	}
+	if ttl := server.TTL("trash:user:u1"); ttl != time.Hour {
+		t.Errorf("trash TTL = %s, want 1h", ttl)
+	}
+}
+
+func TestRedisMalformedRecords(t *testing.T) {
+	ctx := context.Background()
+	songs, users, server := newRedisRepositories(t)
+
+	mustSucceed(t, "CreateSong")(songs.CreateSong(ctx, &model.CreateSongRequest{Song: model.Song{Name: "a"}}))
+	mustSucceed(t, "CreateUser")(users.CreateUser(ctx, &model.CreateUserRequest{User: model.User{ID: "u1"}}))
+	server.Set("song:broken", "{not json")
+	server.Set("song:nameless", `{"artist":"Ann"}`)
+	server.Set("user:broken", "[]")
+	server.Set("trash:song:broken", "{not json")
+	server.Set("trash:user:empty", "{}")
+
+	// Listings skip what they cannot read
+	assertSongs(t, songs, model.Song{Name: "a"})
+	assertUsers(t, users, model.User{ID: "u1"})
+	if trashed, err := songs.GetTrashedSongs(ctx); err != nil || len(trashed) != 0 {
+		t.Fatalf("GetTrashedSongs = %v, %v, want none", trashed, err)
+	}
+	if trashed, err := users.GetTrashedUsers(ctx); err != nil || len(trashed) != 0 {
+		t.Fatalf("GetTrashedUsers = %v, %v, want none", trashed, err)
+	}
+
+	// Direct reads report them
+	if _, err := songs.GetSong(ctx, "broken"); err == nil || err == ErrNotFound {
+		t.Fatalf("GetSong(broken) error = %v, want a decoding error", err)
+	}
+	if _, err := users.GetUser(ctx, "broken"); err == nil || err == ErrNotFound {
+		t.Fatalf("GetUser(broken) error = %v, want a decoding error", err)
+	}
+	if _, err := songs.RestoreSong(ctx, "broken"); err == nil || err == ErrNotFound {
+		t.Fatalf("RestoreSong(broken) error = %v, want a decoding error", err)
+	}
+
+	// and are left alone by modifications
+	err := users.ModifyUser(ctx, "broken", func(*model.User) bool { return true })
+	if err == nil || err == ErrNotFound {
+		t.Fatalf("ModifyUser(broken) error = %v, want a decoding error", err)
+	}
+	if value, _ := server.Get("user:broken"); value != "[]" {
+		t.Fatalf("ModifyUser rewrote a malformed record to %q", value)
+	}
+}
+
+func TestRedisModifyUserRetriesOnConflict(t *testing.T) {
+	ctx := context.Background()
+	_, users, server := newRedisRepositories(t)
+	mustSucceed(t, "CreateUser")(users.CreateUser(ctx, &model.CreateUserRequest{User: model.User{ID: "u1"}}))
+
+	// The first attempt sees a write land between its read and its commit
+	attempts := 0
+	err := users.ModifyUser(ctx, "u1", func(user *model.User) bool {
+		attempts++
+		if attempts == 1 {
+			server.Set("user:u1", `{"id":"u1","name":"Concurrent"}`)
+		}
+		user.LikedSongs = append(user.LikedSongs, "a")
+		return true
+	})
+	if err != nil {
+		t.Fatalf("ModifyUser: %v", err)
+	}
+	if attempts != 2 {
+		t.Fatalf("fn ran %d times, want 2", attempts)
+	}
+	assertUsers(t, users, model.User{ID: "u1", Name: "Concurrent", LikedSongs: []string{"a"}})
+}
+
+// A user's list of token families expires with the last token and loses
+// the families that expired before
+func TestRedisRefreshTokenFamiliesExpire(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	tokens := NewRefreshTokenRepository(client)
+
+	save := func(id, family string, ttl time.Duration) {
+		t.Helper()
+		token := &model.RefreshToken{ID: id, UserID: "u1", Family: family, ExpiresAt: time.Now().Add(ttl)}
+		if err := tokens.SaveRefreshToken(ctx, token); err != nil {
+			t.Fatalf("SaveRefreshToken(%s): %v", id, err)
+		}
+	}
+	save("t1", "f1", time.Minute)
+	save("t2", "f2", time.Hour)
+	server.FastForward(2 * time.Minute)
+	save("t3", "f3", time.Hour)
+
+	families, err := server.Members("refresh:user:u1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(families) != 2 || families[0] != "f2" || families[1] != "f3" {
+		t.Fatalf("families = %v, want the expired f1 pruned", families)
+	}
+	if ttl := server.TTL("refresh:user:u1"); ttl <= 0 || ttl > time.Hour {
+		t.Fatalf("families TTL = %s, want the refresh TTL", ttl)
+	}
+}
+
+func TestRedisErrors(t *testing.T) {
+	ctx := context.Background()
+	songs, users, server := newRedisRepositories(t)
+	server.Close()
+
+	if _, err := songs.GetAllSongs(ctx); err == nil {
+		t.Error("GetAllSongs succeeded without Redis")
+	}
+	if msg, err := songs.CreateSong(ctx, &model.CreateSongRequest{Song: model.Song{Name: "a"}}); err == nil || msg != "Error creating song" {
+		t.Errorf("CreateSong = %q, %v, want an error", msg, err)
+	}
+	if msg, err := users.DeleteUser(ctx, "u1"); err == nil || msg != "Error deleting user" {
+		t.Errorf("DeleteUser = %q, %v, want an error", msg, err)
+	}
+	if err := users.ModifyUser(ctx, "u1", func(*model.User) bool { return true }); err == nil || err == ErrNotFound {
+		t.Errorf("ModifyUser error = %v, want a connection error", err)
+	}
+}
@@ -0,0 +1,173 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"music-store/internal/model"
+	"music-store/internal/tenant"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// RefreshTokenRepository keeps refresh tokens grouped into families. A
+// family starts at login and every rotation adds a token to it, so a reused
+// token can revoke the whole chain.
+type RefreshTokenRepository interface {
+	// ForTenant returns the repository scoped to the keyspace of a tenant
+	ForTenant(tenantID string) RefreshTokenRepository
+	SaveRefreshToken(ctx context.Context, token *model.RefreshToken) error
+	// ConsumeRefreshToken removes the token and remembers it as used until
+	// it would have expired. It returns redis.Nil for unknown tokens.
+	ConsumeRefreshToken(ctx context.Context, id string) (*model.RefreshToken, error)
+	// UsedRefreshTokenFamily returns the family of an already consumed
+	// token, or an empty string if the token was never consumed
+	UsedRefreshTokenFamily(ctx context.Context, id string) (string, error)
+	RevokeFamily(ctx context.Context, family string) error
+	RevokeUserTokens(ctx context.Context, userID string) error
+}
+
+type refreshTokenRepository struct {
+	redisClient redis.UniversalClient
+	prefix      string // Tenant key prefix, empty for the default tenant
+}
+
+func NewRefreshTokenRepository(redisClient redis.UniversalClient) RefreshTokenRepository {
+	return &refreshTokenRepository{redisClient: redisClient}
+}
+
+func (r *refreshTokenRepository) ForTenant(tenantID string) RefreshTokenRepository {
+	return &refreshTokenRepository{redisClient: r.redisClient, prefix: tenant.Prefix(tenantID)}
+}
+
+func (r *refreshTokenRepository) SaveRefreshToken(ctx context.Context, token *model.RefreshToken) error {
+	tokenJSON, err := json.Marshal(token)
+	if err != nil {
+		return err
+	}
+
+	ttl := time.Until(token.ExpiresAt)
+	_, err = r.redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, r.refreshTokenKey(token.ID), tokenJSON, ttl)
+		pipe.SAdd(ctx, r.refreshFamilyKey(token.Family), token.ID)
+		pipe.Expire(ctx, r.refreshFamilyKey(token.Family), ttl)
+		pipe.SAdd(ctx, r.refreshUserKey(token.UserID), token.Family)
+		// The newest token outlives every family listed before it
+		pipe.Expire(ctx, r.refreshUserKey(token.UserID), ttl)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	return r.pruneUserFamilies(ctx, token.UserID)
+}
+
+// pruneUserFamilies drops the families whose tokens have all expired, so
+// the set of a user who keeps logging in stays small
+func (r *refreshTokenRepository) pruneUserFamilies(ctx context.Context, userID string) error {
+	families, err := r.redisClient.SMembers(ctx, r.refreshUserKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+
+	// One EXISTS per family, they hash to different slots in cluster mode
+	exists := make([]*redis.IntCmd, len(families))
+	_, err = r.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for i, family := range families {
+			exists[i] = pipe.Exists(ctx, r.refreshFamilyKey(family))
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	var expired []interface{}
+	for i, family := range families {
+		if exists[i].Val() == 0 {
+			expired = append(expired, family)
+		}
+	}
+	if len(expired) == 0 {
+		return nil
+	}
+	return r.redisClient.SRem(ctx, r.refreshUserKey(userID), expired...).Err()
+}
+
+func (r *refreshTokenRepository) ConsumeRefreshToken(ctx context.Context, id string) (*model.RefreshToken, error) {
+	tokenJSON, err := r.redisClient.GetDel(ctx, r.refreshTokenKey(id)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	var token model.RefreshToken
+	if err := json.Unmarshal([]byte(tokenJSON), &token); err != nil {
+		return nil, err
+	}
+
+	if ttl := time.Until(token.ExpiresAt); ttl > 0 {
+		if err := r.redisClient.Set(ctx, r.refreshUsedKey(id), token.Family, ttl).Err(); err != nil {
+			return nil, err
+		}
+	}
+	return &token, nil
+}
+
+func (r *refreshTokenRepository) UsedRefreshTokenFamily(ctx context.Context, id string) (string, error) {
+	family, err := r.redisClient.Get(ctx, r.refreshUsedKey(id)).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return family, err
+}
+
+func (r *refreshTokenRepository) RevokeFamily(ctx context.Context, family string) error {
+	ids, err := r.redisClient.SMembers(ctx, r.refreshFamilyKey(family)).Result()
+	if err != nil {
+		return err
+	}
+
+	keys := []string{r.refreshFamilyKey(family)}
+	for _, id := range ids {
+		keys = append(keys, r.refreshTokenKey(id))
+	}
+	// One DEL per key, a multi-key DEL fails in cluster mode when the keys
+	// hash to different slots
+	_, err = r.redisClient.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+		for _, key := range keys {
+			pipe.Del(ctx, key)
+		}
+		return nil
+	})
+	return err
+}
+
+func (r *refreshTokenRepository) RevokeUserTokens(ctx context.Context, userID string) error {
+	families, err := r.redisClient.SMembers(ctx, r.refreshUserKey(userID)).Result()
+	if err != nil {
+		return err
+	}
+	for _, family := range families {
+		if err := r.RevokeFamily(ctx, family); err != nil {
+			return err
+		}
+	}
+	return r.redisClient.Del(ctx, r.refreshUserKey(userID)).Err()
+}
+
+func (r *refreshTokenRepository) refreshTokenKey(id string) string {
+	return r.prefix + fmt.Sprintf("refresh:%s", id)
+}
+
+func (r *refreshTokenRepository) refreshUsedKey(id string) string {
+	return r.prefix + fmt.Sprintf("refresh:used:%s", id)
+}
+
+func (r *refreshTokenRepository) refreshFamilyKey(family string) string {
+	return r.prefix + fmt.Sprintf("refresh:family:%s", family)
+}
+
+func (r *refreshTokenRepository) refreshUserKey(userID string) string {
+	return r.prefix + fmt.Sprintf("refresh:user:%s", userID)
+}
@@ -5,27 +5,52 @@ import (
 	"encoding/json"
 	"fmt"
 	"music-store/internal/model"
+	"music-store/internal/tenant"
+	"music-store/utils"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/redis/go-redis/v9"
 )
 
 type SongRepository interface {
-	CreateSong(song *model.CreateSongRequest) (string, error)
-	GetSong(name string) (*model.GetSongResponse, error)
-	GetAllSongs() (*model.GetSongListResponse, error)
-	UpdateSong(song *model.UpdateSongRequest) (string, error)
-	DeleteSong(name string) (string, error)
+	// ForTenant returns the repository scoped to the keyspace of a tenant.
+	// The repository from the constructor serves the default tenant.
+	ForTenant(tenantID string) SongRepository
+	CreateSong(ctx context.Context, song *model.CreateSongRequest) (string, error)
+	GetSong(ctx context.Context, name string) (*model.GetSongResponse, error)
+	GetAllSongs(ctx context.Context) (*model.GetSongListResponse, error)
+	UpdateSong(ctx context.Context, song *model.UpdateSongRequest) (string, error)
+	// DeleteSong removes the song for good, see TrashSong for soft deletes
+	DeleteSong(ctx context.Context, name string) (string, error)
+	// TrashSong moves the song into the trash, where it expires after ttl
+	TrashSong(ctx context.Context, entry *model.TrashedSong, ttl time.Duration) (string, error)
+	GetTrashedSong(ctx context.Context, name string) (*model.TrashedSong, error)
+	GetTrashedSongs(ctx context.Context) ([]*model.TrashedSong, error)
+	// ModifyTrashedSong applies fn to the trash entry like ModifyUser does
+	// to a user, keeping its expiry. It returns ErrNotFound for a missing
+	// or expired entry.
+	ModifyTrashedSong(ctx context.Context, name string, fn func(entry *model.TrashedSong) bool) error
+	// RestoreSong moves the song back out of the trash. It returns
+	// ErrAlreadyExists when a song of the same name was created since.
+	RestoreSong(ctx context.Context, name string) (*model.TrashedSong, error)
+	PurgeSong(ctx context.Context, name string) (string, error)
 }
 
 type songRepository struct {
-	redisClient *redis.Client
+	redisClient redis.UniversalClient
+	prefix      string // Tenant key prefix, empty for the default tenant
 }
 
-func NewSongRepository(redisClient *redis.Client) SongRepository {
+func NewSongRepository(redisClient redis.UniversalClient) SongRepository {
 	return &songRepository{redisClient: redisClient}
 }
 
-func (r *songRepository) CreateSong(song *model.CreateSongRequest) (string, error) {
+func (r *songRepository) ForTenant(tenantID string) SongRepository {
+	return &songRepository{redisClient: r.redisClient, prefix: tenant.Prefix(tenantID)}
+}
+
+func (r *songRepository) CreateSong(ctx context.Context, song *model.CreateSongRequest) (string, error) {
 	// Marshal the Song struct to JSON
 	songJSON, err := json.Marshal(&song.Song)
 	if err != nil {
@@ -33,18 +58,18 @@ func (r *songRepository) CreateSong(song *model.CreateSongRequest) (string, erro
 	}
 
 	// Store in Redis using namespaced key: song:{name}
-	key := fmt.Sprintf("song:%s", song.Song.Name)
-	_, err = r.redisClient.Set(context.Background(), key, songJSON, 0).Result()
+	key := r.prefix + fmt.Sprintf("song:%s", song.Song.Name)
+	_, err = r.redisClient.Set(ctx, key, songJSON, 0).Result()
 	if err != nil {
 		return "Error creating song", err
 	}
 	return "success", nil
 }
 
-func (r *songRepository) GetSong(name string) (*model.GetSongResponse, error) {
+func (r *songRepository) GetSong(ctx context.Context, name string) (*model.GetSongResponse, error) {
 	// Use namespaced key: song:{name}
-	key := fmt.Sprintf("song:%s", name)
-	songJSON, err := r.redisClient.Get(context.Background(), key).Result()
+	key := r.prefix + fmt.Sprintf("song:%s", name)
+	songJSON, err := r.redisClient.Get(ctx, key).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -56,17 +81,20 @@ func (r *songRepository) GetSong(name string) (*model.GetSongResponse, error) {
 	return &model.GetSongResponse{Song: &song}, nil
 }
 
-func (r *songRepository) GetAllSongs() (*model.GetSongListResponse, error) {
+func (r *songRepository) GetAllSongs(ctx context.Context) (*model.GetSongListResponse, error) {
 	// Get only song keys using pattern matching: song:*
-	keys, err := r.redisClient.Keys(context.Background(), "song:*").Result()
+	keys, err := utils.ScanKeys(ctx, r.redisClient, r.prefix+"song:*")
 	if err != nil {
 		return nil, err
 	}
 
 	var songs []*model.Song
 	for _, key := range keys {
-		songJSON, err := r.redisClient.Get(context.Background(), key).Result()
+		songJSON, err := r.redisClient.Get(ctx, key).Result()
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			continue // Skip keys that can't be retrieved
 		}
 
@@ -87,7 +115,7 @@ func (r *songRepository) GetAllSongs() (*model.GetSongListResponse, error) {
 	return &model.GetSongListResponse{Songs: songs}, nil
 }
 
-func (r *songRepository) UpdateSong(song *model.UpdateSongRequest) (string, error) {
+func (r *songRepository) UpdateSong(ctx context.Context, song *model.UpdateSongRequest) (string, error) {
 	// Use the Name from the path parameter if the song name is empty
 	name := song.Name
 	if name == "" && song.Song.Name != "" {
@@ -101,20 +129,109 @@ func (r *songRepository) UpdateSong(song *model.UpdateSongRequest) (string, erro
 	}
 
 	// Store in Redis using namespaced key: song:{name}
-	key := fmt.Sprintf("song:%s", name)
-	_, err = r.redisClient.Set(context.Background(), key, songJSON, 0).Result()
+	key := r.prefix + fmt.Sprintf("song:%s", name)
+	_, err = r.redisClient.Set(ctx, key, songJSON, 0).Result()
 	if err != nil {
 		return "Error updating song", err
 	}
 	return "success", nil
 }
 
-func (r *songRepository) DeleteSong(name string) (string, error) {
+func (r *songRepository) DeleteSong(ctx context.Context, name string) (string, error) {
 	// Use namespaced key: song:{name}
-	key := fmt.Sprintf("song:%s", name)
-	_, err := r.redisClient.Del(context.Background(), key).Result()
+	key := r.prefix + fmt.Sprintf("song:%s", name)
+	_, err := r.redisClient.Del(ctx, key).Result()
+	if err != nil {
+		return "Error deleting song", err
+	}
+	return "success", nil
+}
+
+func (r *songRepository) TrashSong(ctx context.Context, entry *model.TrashedSong, ttl time.Duration) (string, error) {
+	name := entry.Song.Name
+	err := moveToTrash(ctx, r.redisClient, r.prefix+fmt.Sprintf("song:%s", name), r.trashKey(name), entry, ttl)
 	if err != nil {
 		return "Error deleting song", err
 	}
 	return "success", nil
 }
+
+func (r *songRepository) GetTrashedSong(ctx context.Context, name string) (*model.TrashedSong, error) {
+	var entry model.TrashedSong
+	if err := getTrashEntry(ctx, r.redisClient, r.trashKey(name), &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *songRepository) GetTrashedSongs(ctx context.Context) ([]*model.TrashedSong, error) {
+	keys, err := trashKeys(ctx, r.redisClient, r.trashKey("*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*model.TrashedSong
+	for _, key := range keys {
+		var entry model.TrashedSong
+		if err := getTrashEntry(ctx, r.redisClient, key, &entry); err != nil || entry.Song == nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue // Expired or malformed
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+func (r *songRepository) ModifyTrashedSong(ctx context.Context, name string, fn func(entry *model.TrashedSong) bool) error {
+	key := r.trashKey(name)
+
+	for attempt := 0; attempt < maxModifyAttempts; attempt++ {
+		err := r.redisClient.Watch(ctx, func(tx *redis.Tx) error {
+			var entry model.TrashedSong
+			if err := getTrashEntry(ctx, tx, key, &entry); err != nil {
+				return err
+			}
+			if !fn(&entry) {
+				return nil
+			}
+
+			entryJSON, err := json.Marshal(&entry)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.SetArgs(ctx, key, entryJSON, redis.SetArgs{KeepTTL: true})
+				return nil
+			})
+			return err
+		}, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return errors.Errorf("trashed song %s kept changing, gave up after %d attempts", name, maxModifyAttempts)
+}
+
+func (r *songRepository) RestoreSong(ctx context.Context, name string) (*model.TrashedSong, error) {
+	entry, err := r.GetTrashedSong(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if err := restoreFromTrash(ctx, r.redisClient, r.prefix+fmt.Sprintf("song:%s", name), r.trashKey(name), entry.Song); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *songRepository) PurgeSong(ctx context.Context, name string) (string, error) {
+	if _, err := r.redisClient.Del(ctx, r.trashKey(name)).Result(); err != nil {
+		return "Error purging song", err
+	}
+	return "success", nil
+}
+
+func (r *songRepository) trashKey(name string) string {
+	return r.prefix + fmt.Sprintf("trash:song:%s", name)
+}
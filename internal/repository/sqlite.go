@@ -0,0 +1,259 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/pkg/errors"
+	_ "modernc.org/sqlite" // Registers the pure Go "sqlite" driver
+)
+
+// migrations are applied in order, each once. Append new ones, never edit
+// a migration that has shipped.
+var migrations = []string{
+	`CREATE TABLE records (
+		tenant TEXT NOT NULL,
+		kind   TEXT NOT NULL,
+		key    TEXT NOT NULL,
+		data   TEXT NOT NULL,
+		PRIMARY KEY (tenant, kind, key)
+	)`,
+	`CREATE TABLE trash (
+		tenant     TEXT NOT NULL,
+		kind       TEXT NOT NULL,
+		key        TEXT NOT NULL,
+		data       TEXT NOT NULL,
+		expires_at INTEGER NOT NULL, -- Unix nanoseconds
+		PRIMARY KEY (tenant, kind, key)
+	)`,
+	`CREATE INDEX trash_expires_at ON trash (expires_at)`,
+}
+
+// sqliteDocuments stores records as JSON rows keyed by tenant, kind and key
+type sqliteDocuments struct {
+	db       *sql.DB
+	tenantID string
+}
+
+func openSQLiteDocuments(path string) (*sqliteDocuments, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "opening %s", path)
+	}
+	// SQLite serializes writers anyway, one connection avoids SQLITE_BUSY
+	// and keeps an in-memory database alive
+	db.SetMaxOpenConns(1)
+
+	if err := migrate(db); err != nil {
+		db.Close()
+		return nil, errors.Wrapf(err, "migrating %s", path)
+	}
+	return &sqliteDocuments{db: db}, nil
+}
+
+func migrate(db *sql.DB) error {
+	if _, err := db.Exec(`CREATE TABLE IF NOT EXISTS schema_migrations (
+		version    INTEGER PRIMARY KEY,
+		applied_at TEXT NOT NULL
+	)`); err != nil {
+		return err
+	}
+
+	var applied int
+	if err := db.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&applied); err != nil {
+		return err
+	}
+
+	for version := applied + 1; version <= len(migrations); version++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return err
+		}
+		if _, err := tx.Exec(migrations[version-1]); err != nil {
+			tx.Rollback()
+			return errors.Wrapf(err, "migration %d", version)
+		}
+		if _, err := tx.Exec(`INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)`,
+			version, time.Now().UTC().Format(time.RFC3339)); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (d *sqliteDocuments) forTenant(tenantID string) documents {
+	return &sqliteDocuments{db: d.db, tenantID: tenantID}
+}
+
+func (d *sqliteDocuments) get(ctx context.Context, kind, key string) ([]byte, error) {
+	var data []byte
+	err := d.db.QueryRowContext(ctx, `SELECT data FROM records WHERE tenant = ? AND kind = ? AND key = ?`,
+		d.tenantID, kind, key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (d *sqliteDocuments) put(ctx context.Context, kind, key string, data []byte) error {
+	_, err := d.db.ExecContext(ctx, `INSERT INTO records (tenant, kind, key, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (tenant, kind, key) DO UPDATE SET data = excluded.data`,
+		d.tenantID, kind, key, string(data))
+	return err
+}
+
+// modify runs in a transaction, which the single connection keeps from
+// interleaving with other writes
+func (d *sqliteDocuments) modify(ctx context.Context, kind, key string, fn func(data []byte) ([]byte, error)) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var data []byte
+	err = tx.QueryRowContext(ctx, `SELECT data FROM records WHERE tenant = ? AND kind = ? AND key = ?`,
+		d.tenantID, kind, key).Scan(&data)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	updated, err := fn(data)
+	if err != nil || updated == nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE records SET data = ? WHERE tenant = ? AND kind = ? AND key = ?`,
+		string(updated), d.tenantID, kind, key); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *sqliteDocuments) delete(ctx context.Context, kind, key string) error {
+	_, err := d.db.ExecContext(ctx, `DELETE FROM records WHERE tenant = ? AND kind = ? AND key = ?`, d.tenantID, kind, key)
+	return err
+}
+
+func (d *sqliteDocuments) list(ctx context.Context, kind string) ([][]byte, error) {
+	return d.query(ctx, `SELECT data FROM records WHERE tenant = ? AND kind = ? ORDER BY key`, d.tenantID, kind)
+}
+
+func (d *sqliteDocuments) trash(ctx context.Context, kind, key string, entry []byte, ttl time.Duration) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `INSERT INTO trash (tenant, kind, key, data, expires_at) VALUES (?, ?, ?, ?, ?)
+		ON CONFLICT (tenant, kind, key) DO UPDATE SET data = excluded.data, expires_at = excluded.expires_at`,
+		d.tenantID, kind, key, string(entry), time.Now().Add(ttl).UnixNano()); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM records WHERE tenant = ? AND kind = ? AND key = ?`, d.tenantID, kind, key); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *sqliteDocuments) getTrash(ctx context.Context, kind, key string) ([]byte, error) {
+	var data []byte
+	err := d.db.QueryRowContext(ctx, `SELECT data FROM trash WHERE tenant = ? AND kind = ? AND key = ? AND expires_at > ?`,
+		d.tenantID, kind, key, time.Now().UnixNano()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+// listTrash also deletes the expired entries, which nothing else reads
+func (d *sqliteDocuments) listTrash(ctx context.Context, kind string) ([][]byte, error) {
+	now := time.Now().UnixNano()
+	if _, err := d.db.ExecContext(ctx, `DELETE FROM trash WHERE expires_at <= ?`, now); err != nil {
+		return nil, err
+	}
+	return d.query(ctx, `SELECT data FROM trash WHERE tenant = ? AND kind = ? AND expires_at > ? ORDER BY key`,
+		d.tenantID, kind, now)
+}
+
+func (d *sqliteDocuments) modifyTrash(ctx context.Context, kind, key string, fn func(data []byte) ([]byte, error)) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var data []byte
+	err = tx.QueryRowContext(ctx, `SELECT data FROM trash WHERE tenant = ? AND kind = ? AND key = ? AND expires_at > ?`,
+		d.tenantID, kind, key, time.Now().UnixNano()).Scan(&data)
+	if err == sql.ErrNoRows {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	updated, err := fn(data)
+	if err != nil || updated == nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `UPDATE trash SET data = ? WHERE tenant = ? AND kind = ? AND key = ?`,
+		string(updated), d.tenantID, kind, key); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *sqliteDocuments) restore(ctx context.Context, kind, key string, record []byte) error {
+	tx, err := d.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `INSERT INTO records (tenant, kind, key, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT (tenant, kind, key) DO NOTHING`, d.tenantID, kind, key, string(record))
+	if err != nil {
+		return err
+	}
+	if n, err := result.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return ErrAlreadyExists
+	}
+	if _, err := tx.ExecContext(ctx, `DELETE FROM trash WHERE tenant = ? AND kind = ? AND key = ?`, d.tenantID, kind, key); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func (d *sqliteDocuments) purge(ctx context.Context, kind, key string) error {
+	_, err := d.db.ExecContext(ctx, `DELETE FROM trash WHERE tenant = ? AND kind = ? AND key = ?`, d.tenantID, kind, key)
+	return err
+}
+
+func (d *sqliteDocuments) query(ctx context.Context, query string, args ...interface{}) ([][]byte, error) {
+	rows, err := d.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var values [][]byte
+	for rows.Next() {
+		var data []byte
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		values = append(values, data)
+	}
+	return values, rows.Err()
+}
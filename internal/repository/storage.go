@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"log/slog"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// Storage backends for songs and users
+const (
+	BackendRedis  = "redis"
+	BackendMemory = "memory" // Lost on restart, for tests and demos
+	BackendSQLite = "sqlite"
+)
+
+// ErrNotFound is returned by every backend for a missing song, user or
+// trash entry. It is redis.Nil so callers comparing against that keep
+// working.
+var ErrNotFound = redis.Nil
+
+type (
+	// Config selects the backend of the song and user repositories. Other
+	// stores, such as credentials and refresh tokens, always use Redis.
+	Config struct {
+		Backend    string
+		SQLitePath string
+	}
+
+	// Storage holds the song and user repositories of one backend
+	Storage struct {
+		Songs SongRepository
+		Users UserRepository
+		close func() error
+	}
+)
+
+// Open returns the repositories of the configured backend, migrating the
+// SQLite schema when needed
+func Open(config *Config, redisClient redis.UniversalClient) (*Storage, error) {
+	switch config.Backend {
+	case BackendRedis:
+		return &Storage{
+			Songs: NewSongRepository(redisClient),
+			Users: NewUserRepository(redisClient),
+			close: func() error { return nil },
+		}, nil
+	case BackendMemory:
+		slog.Warn("using the in-memory storage backend, songs and users are lost on restart")
+		documents := newMemoryDocuments()
+		return &Storage{
+			Songs: newDocumentSongRepository(documents),
+			Users: newDocumentUserRepository(documents),
+			close: func() error { return nil },
+		}, nil
+	case BackendSQLite:
+		documents, err := openSQLiteDocuments(config.SQLitePath)
+		if err != nil {
+			return nil, err
+		}
+		return &Storage{
+			Songs: newDocumentSongRepository(documents),
+			Users: newDocumentUserRepository(documents),
+			close: documents.db.Close,
+		}, nil
+	default:
+		return nil, errors.Errorf("unknown storage backend %q, expected redis, memory or sqlite", config.Backend)
+	}
+}
+
+// Close releases the backend, the Redis client is closed by its owner
+func (s *Storage) Close() error {
+	return s.close()
+}
@@ -0,0 +1,66 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"music-store/utils"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// ErrAlreadyExists is returned when restoring a record whose key was taken
+// again after it was deleted
+var ErrAlreadyExists = errors.New("already exists")
+
+// moveToTrash replaces the live record with the trash entry in one
+// transaction. In cluster mode the two keys usually sit in different slots
+// and each runs in its own transaction. Entries live under trash:{key},
+// which the live patterns such as song:* do not match, so they drop out of
+// every listing.
+func moveToTrash(ctx context.Context, redisClient redis.UniversalClient, liveKey, trashKey string, entry interface{}, ttl time.Duration) error {
+	entryJSON, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	_, err = redisClient.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.Set(ctx, trashKey, entryJSON, ttl)
+		pipe.Del(ctx, liveKey)
+		return nil
+	})
+	return err
+}
+
+// restoreFromTrash writes record back under liveKey unless the key was
+// taken, then drops the trash entry
+func restoreFromTrash(ctx context.Context, redisClient redis.UniversalClient, liveKey, trashKey string, record interface{}) error {
+	recordJSON, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	restored, err := redisClient.SetNX(ctx, liveKey, recordJSON, 0).Result()
+	if err != nil {
+		return err
+	}
+	if !restored {
+		return ErrAlreadyExists
+	}
+	return redisClient.Del(ctx, trashKey).Err()
+}
+
+// getTrashEntry reads one entry, it returns redis.Nil when there is none
+func getTrashEntry(ctx context.Context, redisClient redis.Cmdable, trashKey string, entry interface{}) error {
+	entryJSON, err := redisClient.Get(ctx, trashKey).Bytes()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(entryJSON, entry)
+}
+
+// trashKeys lists the trash entries matching pattern
+func trashKeys(ctx context.Context, redisClient redis.UniversalClient, pattern string) ([]string, error) {
+	return utils.ScanKeys(ctx, redisClient, pattern)
+}
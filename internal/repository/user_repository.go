@@ -5,27 +5,57 @@ import (
 	"encoding/json"
 	"fmt"
 	"music-store/internal/model"
+	"music-store/internal/tenant"
+	"music-store/utils"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/redis/go-redis/v9"
 )
 
 type UserRepository interface {
-	CreateUser(user *model.CreateUserRequest) (string, error)
-	GetUser(id string) (*model.GetUserResponse, error)
-	GetAllUsers() (*model.GetUserListResponse, error)
-	UpdateUser(user *model.UpdateUserRequest) (string, error)
-	DeleteUser(id string) (string, error)
+	// ForTenant returns the repository scoped to the keyspace of a tenant.
+	// The repository from the constructor serves the default tenant.
+	ForTenant(tenantID string) UserRepository
+	CreateUser(ctx context.Context, user *model.CreateUserRequest) (string, error)
+	GetUser(ctx context.Context, id string) (*model.GetUserResponse, error)
+	GetAllUsers(ctx context.Context) (*model.GetUserListResponse, error)
+	UpdateUser(ctx context.Context, user *model.UpdateUserRequest) (string, error)
+	// ModifyUser applies fn to the stored user and writes the result back
+	// unless fn returns false, retrying when the user changed in between so
+	// concurrent modifications are never lost. It returns ErrNotFound for a
+	// missing user.
+	ModifyUser(ctx context.Context, id string, fn func(user *model.User) bool) error
+	// DeleteUser removes the user for good, see TrashUser for soft deletes
+	DeleteUser(ctx context.Context, id string) (string, error)
+	// TrashUser moves the user into the trash, where it expires after ttl
+	TrashUser(ctx context.Context, entry *model.TrashedUser, ttl time.Duration) (string, error)
+	GetTrashedUser(ctx context.Context, id string) (*model.TrashedUser, error)
+	GetTrashedUsers(ctx context.Context) ([]*model.TrashedUser, error)
+	// RestoreUser moves the user back out of the trash. It returns
+	// ErrAlreadyExists when a user with the same ID was created since.
+	RestoreUser(ctx context.Context, id string) (*model.TrashedUser, error)
+	PurgeUser(ctx context.Context, id string) (string, error)
 }
 
+// maxModifyAttempts bounds the optimistic retries of ModifyUser and
+// ModifyTrashedSong
+const maxModifyAttempts = 50
+
 type userRepository struct {
-	redisClient *redis.Client
+	redisClient redis.UniversalClient
+	prefix      string // Tenant key prefix, empty for the default tenant
 }
 
-func NewUserRepository(redisClient *redis.Client) UserRepository {
+func NewUserRepository(redisClient redis.UniversalClient) UserRepository {
 	return &userRepository{redisClient: redisClient}
 }
 
-func (r *userRepository) CreateUser(user *model.CreateUserRequest) (string, error) {
+func (r *userRepository) ForTenant(tenantID string) UserRepository {
+	return &userRepository{redisClient: r.redisClient, prefix: tenant.Prefix(tenantID)}
+}
+
+func (r *userRepository) CreateUser(ctx context.Context, user *model.CreateUserRequest) (string, error) {
 	// Marshal the User struct to JSON
 	userJSON, err := json.Marshal(user.User)
 	if err != nil {
@@ -33,18 +63,18 @@ func (r *userRepository) CreateUser(user *model.CreateUserRequest) (string, erro
 	}
 
 	// Store in Redis using namespaced key: user:{id}
-	key := fmt.Sprintf("user:%s", user.User.ID)
-	_, err = r.redisClient.Set(context.Background(), key, userJSON, 0).Result()
+	key := r.prefix + fmt.Sprintf("user:%s", user.User.ID)
+	_, err = r.redisClient.Set(ctx, key, userJSON, 0).Result()
 	if err != nil {
 		return "Error creating user", err
 	}
 	return "success", nil
 }
 
-func (r *userRepository) GetUser(id string) (*model.GetUserResponse, error) {
+func (r *userRepository) GetUser(ctx context.Context, id string) (*model.GetUserResponse, error) {
 	// Use namespaced key: user:{id}
-	key := fmt.Sprintf("user:%s", id)
-	userJSON, err := r.redisClient.Get(context.Background(), key).Result()
+	key := r.prefix + fmt.Sprintf("user:%s", id)
+	userJSON, err := r.redisClient.Get(ctx, key).Result()
 	if err != nil {
 		return nil, err
 	}
@@ -58,17 +88,20 @@ func (r *userRepository) GetUser(id string) (*model.GetUserResponse, error) {
 	return &model.GetUserResponse{User: &user}, nil
 }
 
-func (r *userRepository) GetAllUsers() (*model.GetUserListResponse, error) {
+func (r *userRepository) GetAllUsers(ctx context.Context) (*model.GetUserListResponse, error) {
 	// Get only user keys using pattern matching: user:*
-	keys, err := r.redisClient.Keys(context.Background(), "user:*").Result()
+	keys, err := utils.ScanKeys(ctx, r.redisClient, r.prefix+"user:*")
 	if err != nil {
 		return nil, err
 	}
 
 	var users []*model.User
 	for _, key := range keys {
-		userJSON, err := r.redisClient.Get(context.Background(), key).Result()
+		userJSON, err := r.redisClient.Get(ctx, key).Result()
 		if err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
 			continue // Skip keys that can't be retrieved
 		}
 
@@ -84,7 +117,7 @@ func (r *userRepository) GetAllUsers() (*model.GetUserListResponse, error) {
 	return &model.GetUserListResponse{Users: users}, nil
 }
 
-func (r *userRepository) UpdateUser(user *model.UpdateUserRequest) (string, error) {
+func (r *userRepository) UpdateUser(ctx context.Context, user *model.UpdateUserRequest) (string, error) {
 	// Set the ID from the path parameter if not already set
 	if user.User.ID == "" {
 		user.User.ID = user.ID
@@ -97,20 +130,113 @@ func (r *userRepository) UpdateUser(user *model.UpdateUserRequest) (string, erro
 	}
 
 	// Use namespaced key: user:{id}
-	key := fmt.Sprintf("user:%s", user.ID)
-	_, err = r.redisClient.Set(context.Background(), key, userJSON, 0).Result()
+	key := r.prefix + fmt.Sprintf("user:%s", user.ID)
+	_, err = r.redisClient.Set(ctx, key, userJSON, 0).Result()
 	if err != nil {
 		return "Error updating user", err
 	}
 	return "success", nil
 }
 
-func (r *userRepository) DeleteUser(id string) (string, error) {
+func (r *userRepository) ModifyUser(ctx context.Context, id string, fn func(user *model.User) bool) error {
+	key := r.prefix + fmt.Sprintf("user:%s", id)
+
+	for attempt := 0; attempt < maxModifyAttempts; attempt++ {
+		err := r.redisClient.Watch(ctx, func(tx *redis.Tx) error {
+			userJSON, err := tx.Get(ctx, key).Bytes()
+			if err != nil {
+				return err
+			}
+			var user model.User
+			if err := json.Unmarshal(userJSON, &user); err != nil {
+				return err
+			}
+			if !fn(&user) {
+				return nil
+			}
+
+			userJSON, err = json.Marshal(&user)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, key, userJSON, 0)
+				return nil
+			})
+			return err
+		}, key)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return errors.Errorf("user %s kept changing, gave up after %d attempts", id, maxModifyAttempts)
+}
+
+func (r *userRepository) DeleteUser(ctx context.Context, id string) (string, error) {
 	// Use namespaced key: user:{id}
-	key := fmt.Sprintf("user:%s", id)
-	_, err := r.redisClient.Del(context.Background(), key).Result()
+	key := r.prefix + fmt.Sprintf("user:%s", id)
+	_, err := r.redisClient.Del(ctx, key).Result()
+	if err != nil {
+		return "Error deleting user", err
+	}
+	return "success", nil
+}
+
+func (r *userRepository) TrashUser(ctx context.Context, entry *model.TrashedUser, ttl time.Duration) (string, error) {
+	id := entry.User.ID
+	err := moveToTrash(ctx, r.redisClient, r.prefix+fmt.Sprintf("user:%s", id), r.trashKey(id), entry, ttl)
 	if err != nil {
 		return "Error deleting user", err
 	}
 	return "success", nil
 }
+
+func (r *userRepository) GetTrashedUser(ctx context.Context, id string) (*model.TrashedUser, error) {
+	var entry model.TrashedUser
+	if err := getTrashEntry(ctx, r.redisClient, r.trashKey(id), &entry); err != nil {
+		return nil, err
+	}
+	return &entry, nil
+}
+
+func (r *userRepository) GetTrashedUsers(ctx context.Context) ([]*model.TrashedUser, error) {
+	keys, err := trashKeys(ctx, r.redisClient, r.trashKey("*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*model.TrashedUser
+	for _, key := range keys {
+		var entry model.TrashedUser
+		if err := getTrashEntry(ctx, r.redisClient, key, &entry); err != nil || entry.User == nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			continue // Expired or malformed
+		}
+		entries = append(entries, &entry)
+	}
+	return entries, nil
+}
+
+func (r *userRepository) RestoreUser(ctx context.Context, id string) (*model.TrashedUser, error) {
+	entry, err := r.GetTrashedUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if err := restoreFromTrash(ctx, r.redisClient, r.prefix+fmt.Sprintf("user:%s", id), r.trashKey(id), entry.User); err != nil {
+		return nil, err
+	}
+	return entry, nil
+}
+
+func (r *userRepository) PurgeUser(ctx context.Context, id string) (string, error) {
+	if _, err := r.redisClient.Del(ctx, r.trashKey(id)).Result(); err != nil {
+		return "Error purging user", err
+	}
+	return "success", nil
+}
+
+func (r *userRepository) trashKey(id string) string {
+	return r.prefix + fmt.Sprintf("trash:user:%s", id)
+}
@@ -0,0 +1,114 @@
+package revision
+
+import (
+	"context"
+	"log/slog"
+	"music-store/internal/api"
+	"music-store/internal/audit"
+	"music-store/internal/auth"
+	"music-store/internal/model"
+	"music-store/internal/repository"
+	"music-store/internal/service"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrSongNotFound     error = api.NewError(404, "song not found")
+	ErrRevisionNotFound error = api.NewError(404, "revision not found")
+)
+
+type (
+	Service interface {
+		GetRevisions(ctx context.Context, name string) (*model.GetSongRevisionsResponse, error)
+		// Revert writes a revision back as an ordinary update, so the
+		// state it replaces becomes a revision in turn
+		Revert(ctx context.Context, name string, rev int64) (string, error)
+	}
+
+	revisionService struct {
+		store       Store
+		songService service.SongService
+	}
+)
+
+// NewService reverts through songService, which should be the fully
+// decorated service so reverts are audited and recorded like any update
+func NewService(store Store, songService service.SongService) Service {
+	return &revisionService{store: store, songService: songService}
+}
+
+func (s *revisionService) GetRevisions(ctx context.Context, name string) (*model.GetSongRevisionsResponse, error) {
+	revisions, err := s.store.List(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetSongRevisionsResponse{Revisions: revisions}, nil
+}
+
+func (s *revisionService) Revert(ctx context.Context, name string, rev int64) (string, error) {
+	if _, err := s.songService.GetSong(ctx, name); err == repository.ErrNotFound {
+		return "Error reverting song", ErrSongNotFound
+	} else if err != nil {
+		return "Error getting song", err
+	}
+
+	revision, err := s.store.Get(ctx, name, rev)
+	if err == redis.Nil {
+		return "Error reverting song", ErrRevisionNotFound
+	}
+	if err != nil {
+		return "Error getting revision", err
+	}
+	return s.songService.UpdateSong(ctx, &model.UpdateSongRequest{Name: name, Song: *revision.Song})
+}
+
+// trackedSongService keeps the prior state of a song on every update
+type trackedSongService struct {
+	service.SongService
+	store Store
+}
+
+func NewSongService(next service.SongService, store Store) service.SongService {
+	return &trackedSongService{SongService: next, store: store}
+}
+
+func (s *trackedSongService) UpdateSong(ctx context.Context, req *model.UpdateSongRequest) (string, error) {
+	name := req.Name
+	if name == "" {
+		name = req.Song.Name
+	}
+
+	// An update creating the song has no prior state to keep
+	before, _ := s.SongService.GetSong(ctx, name)
+	msg, err := s.SongService.UpdateSong(ctx, req)
+	if err != nil || msg != "success" || before == nil {
+		return msg, err
+	}
+
+	rev := &model.SongRevision{Song: before.Song, Timestamp: time.Now().UTC()}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		rev.Actor = principal.Subject
+	}
+	if rev.Changes, err = audit.Diff(before.Song, &req.Song); err != nil {
+		slog.ErrorContext(ctx, "revision diff failed", "song", name, "error", err)
+	}
+	// The update has happened, a lost revision is only logged
+	if err := s.store.Record(ctx, name, rev); err != nil {
+		slog.ErrorContext(ctx, "recording revision failed", "song", name, "error", err)
+	}
+	return msg, nil
+}
+
+// PurgeSong drops the history with the song, a trashed song keeps it until
+// then so a restore brings it back
+func (s *trackedSongService) PurgeSong(ctx context.Context, name string) (string, error) {
+	msg, err := s.SongService.PurgeSong(ctx, name)
+	if err == nil && msg == "success" {
+		if err := s.store.Delete(ctx, name); err != nil {
+			return "Error deleting revisions", err
+		}
+	}
+	return msg, err
+}
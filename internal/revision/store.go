@@ -0,0 +1,206 @@
+// Package revision keeps the prior versions of songs so catalog edits can be
+// reviewed and rolled back.
+package revision
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"music-store/internal/model"
+	"music-store/internal/tenant"
+	"music-store/utils"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+)
+
+// maxRecordAttempts bounds the retries of a revision racing other edits of
+// the same song
+const maxRecordAttempts = 50
+
+type (
+	// Config holds the revision settings
+	Config struct {
+		MaxRevisions int // Revisions kept per song, older ones are dropped
+	}
+
+	Store interface {
+		// Record stores rev as the newest revision of the song, assigning
+		// its number
+		Record(ctx context.Context, name string, rev *model.SongRevision) error
+		// List returns the revisions of a song newest first
+		List(ctx context.Context, name string) ([]*model.SongRevision, error)
+		// Get returns redis.Nil when the revision is unknown or was dropped
+		Get(ctx context.Context, name string, rev int64) (*model.SongRevision, error)
+		Delete(ctx context.Context, name string) error
+		// ByActor returns every revision made by actor
+		ByActor(ctx context.Context, actor string) ([]*model.SongRevision, error)
+		// ForgetActor removes actor from every revision, keeping the
+		// revisions themselves
+		ForgetActor(ctx context.Context, actor string) (int, error)
+	}
+
+	redisStore struct {
+		redisClient  redis.UniversalClient
+		maxRevisions int
+	}
+)
+
+func NewStore(config *Config, redisClient redis.UniversalClient) Store {
+	return &redisStore{redisClient: redisClient, maxRevisions: config.MaxRevisions}
+}
+
+// Record numbers and pushes the revision in one transaction, so concurrent
+// edits of a song land in the history in the order of their numbers
+func (s *redisStore) Record(ctx context.Context, name string, rev *model.SongRevision) error {
+	seqKey, listKey := seqKey(ctx, name), listKey(ctx, name)
+
+	for attempt := 0; attempt < maxRecordAttempts; attempt++ {
+		err := s.redisClient.Watch(ctx, func(tx *redis.Tx) error {
+			n, err := tx.Get(ctx, seqKey).Int64()
+			if err != nil && err != redis.Nil {
+				return err
+			}
+			rev.Rev = n + 1
+
+			data, err := json.Marshal(rev)
+			if err != nil {
+				return err
+			}
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Set(ctx, seqKey, rev.Rev, 0)
+				pipe.LPush(ctx, listKey, data)
+				pipe.LTrim(ctx, listKey, 0, int64(s.maxRevisions-1))
+				return nil
+			})
+			return err
+		}, seqKey)
+		if err != redis.TxFailedErr {
+			return err
+		}
+	}
+	return errors.Errorf("song %s kept changing, gave up recording its revision after %d attempts", name, maxRecordAttempts)
+}
+
+func (s *redisStore) List(ctx context.Context, name string) ([]*model.SongRevision, error) {
+	return s.list(ctx, listKey(ctx, name))
+}
+
+func (s *redisStore) Get(ctx context.Context, name string, rev int64) (*model.SongRevision, error) {
+	revisions, err := s.List(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range revisions {
+		if r.Rev == rev {
+			return r, nil
+		}
+	}
+	return nil, redis.Nil
+}
+
+// Delete drops the history of a song, the numbering carries on so a
+// recreated song never reuses a revision number
+func (s *redisStore) Delete(ctx context.Context, name string) error {
+	return s.redisClient.Del(ctx, listKey(ctx, name)).Err()
+}
+
+func (s *redisStore) ByActor(ctx context.Context, actor string) ([]*model.SongRevision, error) {
+	keys, err := utils.ScanKeys(ctx, s.redisClient, listKey(ctx, "*"))
+	if err != nil {
+		return nil, err
+	}
+
+	var revisions []*model.SongRevision
+	for _, key := range keys {
+		all, err := s.list(ctx, key)
+		if err != nil {
+			return nil, err
+		}
+		for _, rev := range all {
+			if rev.Actor == actor {
+				revisions = append(revisions, rev)
+			}
+		}
+	}
+	return revisions, nil
+}
+
+func (s *redisStore) ForgetActor(ctx context.Context, actor string) (int, error) {
+	keys, err := utils.ScanKeys(ctx, s.redisClient, listKey(ctx, "*"))
+	if err != nil {
+		return 0, err
+	}
+
+	forgotten := 0
+	for _, key := range keys {
+		// Rewrite the whole list, watching it so a concurrent update retries
+		// instead of being lost
+		var n int
+		err := s.redisClient.Watch(ctx, func(tx *redis.Tx) error {
+			values, err := tx.LRange(ctx, key, 0, -1).Result()
+			if err != nil {
+				return err
+			}
+
+			n = 0
+			rewritten := make([]interface{}, len(values))
+			for i, value := range values {
+				rewritten[i] = value
+				var rev model.SongRevision
+				if err := json.Unmarshal([]byte(value), &rev); err != nil || rev.Actor != actor {
+					continue
+				}
+				rev.Actor = ""
+				data, err := json.Marshal(&rev)
+				if err != nil {
+					return err
+				}
+				rewritten[i] = data
+				n++
+			}
+			if n == 0 {
+				return nil
+			}
+
+			_, err = tx.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.Del(ctx, key)
+				pipe.RPush(ctx, key, rewritten...)
+				return nil
+			})
+			return err
+		}, key)
+		if err != nil {
+			return forgotten, err
+		}
+		forgotten += n
+	}
+	return forgotten, nil
+}
+
+func (s *redisStore) list(ctx context.Context, key string) ([]*model.SongRevision, error) {
+	values, err := s.redisClient.LRange(ctx, key, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]*model.SongRevision, 0, len(values))
+	for _, value := range values {
+		var rev model.SongRevision
+		if err := json.Unmarshal([]byte(value), &rev); err != nil {
+			continue // Skip malformed data
+		}
+		revisions = append(revisions, &rev)
+	}
+	return revisions, nil
+}
+
+// listKey and seqKey share the song name as hash tag, Record updates both
+// in one transaction which Redis Cluster only allows within a slot
+func listKey(ctx context.Context, name string) string {
+	return tenant.Key(ctx, fmt.Sprintf("revisions:song:{%s}", name))
+}
+
+func seqKey(ctx context.Context, name string) string {
+	return tenant.Key(ctx, fmt.Sprintf("revision:seq:song:{%s}", name))
+}
@@ -0,0 +1,51 @@
+package revision
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+
+	"music-store/internal/model"
+)
+
+func TestRecordKeepsOrderUnderConcurrency(t *testing.T) {
+	mr := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: mr.Addr()})
+	t.Cleanup(func() { redisClient.Close() })
+
+	store := NewStore(&Config{MaxRevisions: 100}, redisClient)
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := store.Record(ctx, "song", &model.SongRevision{Actor: "u1"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	revisions, err := store.List(ctx, "song")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 20 {
+		t.Fatalf("got %d revisions, want 20", len(revisions))
+	}
+	for i, rev := range revisions {
+		if want := int64(20 - i); rev.Rev != want {
+			t.Fatalf("revision %d is number %d, want %d, the history is out of order", i, rev.Rev, want)
+		}
+	}
+
+	byActor, err := store.ByActor(ctx, "u1")
+	if err != nil || len(byActor) != 20 {
+		t.Errorf("ByActor = %d revisions, %v, want 20", len(byActor), err)
+	}
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"context"
+	"music-store/internal/auth"
+	"music-store/internal/model"
+	"music-store/internal/repository"
+	"music-store/internal/tenant"
+	"strings"
+	"time"
+
+	"github.com/gofrs/uuid"
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const minPasswordLength = 8
+
+var ErrInvalidSignup = errors.New("invalid signup")
+
+// dummyHash is compared against when the username does not exist, so that
+// unknown and known usernames take the same time to reject
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("music-store"), bcrypt.DefaultCost)
+
+type AuthService interface {
+	Signup(ctx context.Context, req *model.SignupRequest) (*model.SignupResponse, error)
+	Login(ctx context.Context, req *model.LoginRequest) (*model.AuthTokens, error)
+	Refresh(ctx context.Context, req *model.RefreshTokenRequest) (*model.AuthTokens, error)
+	Logout(ctx context.Context, req *model.LogoutRequest) (string, error)
+}
+
+type authService struct {
+	userService            UserService
+	credentialRepository   repository.CredentialRepository
+	refreshTokenRepository repository.RefreshTokenRepository
+	issuer                 *auth.TokenIssuer
+}
+
+// NewAuthService creates accounts through userService, which should be the
+// fully decorated service so signups are audited like any other new user
+func NewAuthService(
+	userService UserService,
+	credentialRepository repository.CredentialRepository,
+	refreshTokenRepository repository.RefreshTokenRepository,
+	issuer *auth.TokenIssuer,
+) AuthService {
+	return &authService{
+		userService:            userService,
+		credentialRepository:   credentialRepository,
+		refreshTokenRepository: refreshTokenRepository,
+		issuer:                 issuer,
+	}
+}
+
+func (s *authService) Signup(ctx context.Context, req *model.SignupRequest) (*model.SignupResponse, error) {
+	username := strings.TrimSpace(req.Username)
+	if username == "" {
+		return nil, errors.Wrap(ErrInvalidSignup, "username is required")
+	}
+	if len(req.Password) < minPasswordLength {
+		return nil, errors.Wrapf(ErrInvalidSignup, "password must be at least %d characters", minPasswordLength)
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
+	}
+	id, err := uuid.NewV4()
+	if err != nil {
+		return nil, err
+	}
+
+	// Claim the username first so two signups cannot race for it
+	credential := &model.Credential{
+		UserID:       id.String(),
+		Username:     username,
+		PasswordHash: string(hash),
+		CreatedAt:    time.Now().UTC(),
+	}
+	if _, err := s.credentials(ctx).CreateCredential(ctx, credential); err != nil {
+		return nil, err
+	}
+
+	name := req.Name
+	if name == "" {
+		name = username
+	}
+	// The new user is the one creating the account
+	user := &model.CreateUserRequest{User: model.User{ID: credential.UserID, Name: name, Username: username}}
+	userCtx := auth.WithPrincipal(ctx, &auth.Principal{Subject: credential.UserID, Kind: auth.KindUser, Tenant: tenant.ID(ctx)})
+	if _, err := s.userService.CreateUser(userCtx, user); err != nil {
+		// Release the username even when the request was cancelled
+		s.credentials(ctx).DeleteCredential(context.WithoutCancel(ctx), username)
+		return nil, err
+	}
+
+	tokens, err := s.issueTokens(ctx, credential.UserID, credential.Roles, "")
+	if err != nil {
+		return nil, err
+	}
+	return &model.SignupResponse{UserID: credential.UserID, Tokens: tokens}, nil
+}
+
+func (s *authService) Login(ctx context.Context, req *model.LoginRequest) (*model.AuthTokens, error) {
+	credential, err := s.credentials(ctx).GetCredential(ctx, strings.TrimSpace(req.Username))
+	if err == redis.Nil {
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(req.Password))
+		return nil, auth.ErrInvalidCredentials
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(credential.PasswordHash), []byte(req.Password)); err != nil {
+		return nil, auth.ErrInvalidCredentials
+	}
+	return s.issueTokens(ctx, credential.UserID, credential.Roles, "")
+}
+
+// Refresh rotates a refresh token. Presenting a token that was already
+// rotated means it leaked, so every token of its family is revoked.
+func (s *authService) Refresh(ctx context.Context, req *model.RefreshTokenRequest) (*model.AuthTokens, error) {
+	id := auth.HashToken(req.RefreshToken)
+
+	token, err := s.refreshTokens(ctx).ConsumeRefreshToken(ctx, id)
+	if err == redis.Nil {
+		family, err := s.refreshTokens(ctx).UsedRefreshTokenFamily(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		if family != "" {
+			if err := s.refreshTokens(ctx).RevokeFamily(ctx, family); err != nil {
+				return nil, err
+			}
+			return nil, errors.Wrap(auth.ErrInvalidCredentials, "refresh token reuse detected")
+		}
+		return nil, errors.Wrap(auth.ErrInvalidCredentials, "unknown refresh token")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return s.issueTokens(ctx, token.UserID, token.Roles, token.Family)
+}
+
+// Logout revokes the session the refresh token belongs to, or every session
+// of its user. Access tokens stay valid until they expire.
+func (s *authService) Logout(ctx context.Context, req *model.LogoutRequest) (string, error) {
+	token, err := s.refreshTokens(ctx).ConsumeRefreshToken(ctx, auth.HashToken(req.RefreshToken))
+	if err == redis.Nil {
+		return "Already logged out", nil
+	}
+	if err != nil {
+		return "Error logging out", err
+	}
+
+	if req.All {
+		err = s.refreshTokens(ctx).RevokeUserTokens(ctx, token.UserID)
+	} else {
+		err = s.refreshTokens(ctx).RevokeFamily(ctx, token.Family)
+	}
+	if err != nil {
+		return "Error logging out", err
+	}
+	return "success", nil
+}
+
+// issueTokens creates an access token bound to the tenant and a refresh
+// token. An empty family starts a new session.
+func (s *authService) issueTokens(ctx context.Context, userID string, roles []string, family string) (*model.AuthTokens, error) {
+	accessToken, err := s.issuer.IssueAccessToken(userID, tenant.ID(ctx), roles)
+	if err != nil {
+		return nil, err
+	}
+
+	plain, id, err := s.issuer.NewRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	if family == "" {
+		family = id
+	}
+
+	refreshToken := &model.RefreshToken{
+		ID:        id,
+		UserID:    userID,
+		Family:    family,
+		Roles:     roles,
+		ExpiresAt: time.Now().Add(s.issuer.RefreshTokenTTL()).UTC(),
+	}
+	if err := s.refreshTokens(ctx).SaveRefreshToken(ctx, refreshToken); err != nil {
+		return nil, err
+	}
+
+	return &model.AuthTokens{
+		AccessToken:  accessToken,
+		RefreshToken: plain,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.issuer.AccessTokenTTL().Seconds()),
+	}, nil
+}
+
+// Accounts and sessions live in the keyspace of the tenant serving the
+// request, a login only works on the storefront it signed up with
+func (s *authService) credentials(ctx context.Context) repository.CredentialRepository {
+	return s.credentialRepository.ForTenant(tenant.ID(ctx))
+}
+
+func (s *authService) refreshTokens(ctx context.Context) repository.RefreshTokenRepository {
+	return s.refreshTokenRepository.ForTenant(tenant.ID(ctx))
+}
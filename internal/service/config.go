@@ -0,0 +1,10 @@
+package service
+
+import (
+	"time"
+)
+
+// Config holds the service settings
+type Config struct {
+	TrashRetention time.Duration // How long deleted songs and users can be restored
+}
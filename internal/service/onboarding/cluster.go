@@ -0,0 +1,83 @@
+package onboarding
+
+import (
+	"math"
+	"music-store/internal/model"
+	"music-store/internal/service/recommender"
+)
+
+const kMeansIterations = 10
+
+// clusterSongs groups songs by embedding with k-means. Seeds are chosen by
+// farthest-point traversal starting from the first song, so the result only
+// depends on the input order.
+func clusterSongs(songs []*model.Song, k int) [][]*model.Song {
+	if len(songs) == 0 || k <= 0 {
+		return nil
+	}
+	if k > len(songs) {
+		k = len(songs)
+	}
+
+	centroids := [][]float64{songs[0].Embedding}
+	for len(centroids) < k {
+		var farthest *model.Song
+		best := -1.0
+		for _, song := range songs {
+			d := nearestDistance(song.Embedding, centroids)
+			if d > best {
+				best, farthest = d, song
+			}
+		}
+		centroids = append(centroids, farthest.Embedding)
+	}
+
+	assignment := make([]int, len(songs))
+	for iter := 0; iter < kMeansIterations; iter++ {
+		changed := iter == 0
+		for i, song := range songs {
+			if c := nearestCentroid(song.Embedding, centroids); c != assignment[i] {
+				assignment[i] = c
+				changed = true
+			}
+		}
+		if !changed {
+			break
+		}
+
+		members := make([][][]float64, k)
+		for i, song := range songs {
+			members[assignment[i]] = append(members[assignment[i]], song.Embedding)
+		}
+		for c := range centroids {
+			if len(members[c]) > 0 {
+				centroids[c] = recommender.Mean(members[c]...)
+			}
+		}
+	}
+
+	clusters := make([][]*model.Song, k)
+	for i, song := range songs {
+		clusters[assignment[i]] = append(clusters[assignment[i]], song)
+	}
+	return clusters
+}
+
+// distance is 1 - cosine similarity, matching how the recommender compares songs
+func distance(a, b []float64) float64 {
+	return 1 - recommender.Cosine(a, b)
+}
+
+func nearestCentroid(v []float64, centroids [][]float64) int {
+	nearest, best := 0, math.Inf(1)
+	for c, centroid := range centroids {
+		if d := distance(v, centroid); d < best {
+			nearest, best = c, d
+		}
+	}
+	return nearest
+}
+
+func nearestDistance(v []float64, centroids [][]float64) float64 {
+	return distance(v, centroids[nearestCentroid(v, centroids)])
+}
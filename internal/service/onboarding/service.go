@@ -0,0 +1,271 @@
+// Package onboarding gives new users something to react to before there is
+// any personal signal, and turns their first picks into likes and a taste
+// vector.
+package onboarding
+
+import (
+	"context"
+	"music-store/internal/model"
+	"music-store/internal/repository"
+	"music-store/internal/service"
+	"music-store/internal/service/recommender"
+	"music-store/internal/tenant"
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	defaultLimit = 12
+	maxLimit     = 50
+)
+
+var (
+	ErrNothingPicked = errors.New("pick at least one song or genre")
+	ErrUnknownSong   = errors.New("unknown song")
+)
+
+type Service interface {
+	GetPicks(ctx context.Context, req *model.GetOnboardingPicksRequest) (*model.GetOnboardingPicksResponse, error)
+	Onboard(ctx context.Context, req *model.OnboardUserRequest) (string, error)
+}
+
+type onboardingService struct {
+	songRepository repository.SongRepository
+	userRepository repository.UserRepository
+	userService    service.UserService
+}
+
+// NewService likes the picks through userService, which should be the fully
+// decorated service so onboarding likes are audited and tracked like any other
+func NewService(songRepository repository.SongRepository, userRepository repository.UserRepository, userService service.UserService) Service {
+	return &onboardingService{songRepository: songRepository, userRepository: userRepository, userService: userService}
+}
+
+// group is a pool of songs picks are drawn from, most popular first
+type group struct {
+	cluster int
+	songs   []*model.Song
+}
+
+// GetPicks spreads the picks over embedding clusters, taking one song per
+// cluster per round and preferring genres that are not represented yet
+func (s *onboardingService) GetPicks(ctx context.Context, req *model.GetOnboardingPicksRequest) (*model.GetOnboardingPicksResponse, error) {
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+
+	songs, popularity, err := s.loadCatalog(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Songs sharing the dominant embedding size are clustered, every other
+	// song is grouped by genre
+	var embedded, rest []*model.Song
+	dim := dominantDimension(songs)
+	for _, song := range songs {
+		if dim > 0 && len(song.Embedding) == dim {
+			embedded = append(embedded, song)
+		} else {
+			rest = append(rest, song)
+		}
+	}
+
+	var groups []*group
+	for i, cluster := range clusterSongs(embedded, limit) {
+		if len(cluster) > 0 {
+			groups = append(groups, &group{cluster: i, songs: cluster})
+		}
+	}
+	byGenre := make(map[string]*group)
+	for _, song := range rest {
+		g, ok := byGenre[song.Genre]
+		if !ok {
+			g = &group{cluster: -1}
+			byGenre[song.Genre] = g
+			groups = append(groups, g)
+		}
+		g.songs = append(g.songs, song)
+	}
+
+	for _, g := range groups {
+		sort.SliceStable(g.songs, func(i, j int) bool {
+			return popularity[g.songs[i].Name] > popularity[g.songs[j].Name]
+		})
+	}
+	// Larger clusters represent more of the catalog, visit them first
+	sort.SliceStable(groups, func(i, j int) bool {
+		return len(groups[i].songs) > len(groups[j].songs)
+	})
+
+	resp := &model.GetOnboardingPicksResponse{Genres: genres(songs)}
+	usedGenres := make(map[string]struct{})
+	for len(resp.Picks) < limit {
+		picked := false
+		for _, g := range groups {
+			if len(resp.Picks) == limit || len(g.songs) == 0 {
+				continue
+			}
+
+			// Prefer the most popular song of a genre we have not shown yet
+			idx := 0
+			for i, song := range g.songs {
+				if _, used := usedGenres[song.Genre]; !used && song.Genre != "" {
+					idx = i
+					break
+				}
+			}
+
+			song := g.songs[idx]
+			g.songs = append(g.songs[:idx], g.songs[idx+1:]...)
+			usedGenres[song.Genre] = struct{}{}
+			resp.Picks = append(resp.Picks, &model.OnboardingPick{Song: song, Cluster: g.cluster})
+			picked = true
+		}
+		if !picked {
+			break // Catalog exhausted
+		}
+	}
+	return resp, nil
+}
+
+// Onboard likes the picked songs, remembers the picked genres and seeds the
+// taste vector with the mean embedding of the picked songs and genres
+func (s *onboardingService) Onboard(ctx context.Context, req *model.OnboardUserRequest) (string, error) {
+	if len(req.SongNames) == 0 && len(req.Genres) == 0 {
+		return "Nothing picked", ErrNothingPicked
+	}
+
+	songs, _, err := s.loadCatalog(ctx)
+	if err != nil {
+		return "Error getting songs", err
+	}
+	corpus := recommender.NewCorpus(songs, nil)
+
+	// Every pick is checked before the first one is liked
+	var vectors [][]float64
+	for _, name := range req.SongNames {
+		song := corpus.Song(name)
+		if song == nil {
+			return "Unknown song", errors.Wrap(ErrUnknownSong, name)
+		}
+		if len(song.Embedding) > 0 {
+			vectors = append(vectors, song.Embedding)
+		}
+	}
+
+	for _, genre := range req.Genres {
+		var members [][]float64
+		for _, song := range songs {
+			if song.Genre == genre && len(song.Embedding) > 0 {
+				members = append(members, song.Embedding)
+			}
+		}
+		if len(members) > 0 {
+			vectors = append(vectors, recommender.Mean(members...))
+		}
+	}
+
+	// Likes go through the user service, which also clears earlier dislikes
+	for _, name := range req.SongNames {
+		if _, err := s.userService.LikeSong(ctx, req.UserID, name); err != nil {
+			return "Error liking song", err
+		}
+	}
+
+	// Genres and the taste vector are written atomically, so likes arriving
+	// meanwhile are kept
+	taste := recommender.Mean(vectors...)
+	err = s.users(ctx).ModifyUser(ctx, req.UserID, func(user *model.User) bool {
+		for _, genre := range req.Genres {
+			if !contains(user.Genres, genre) {
+				user.Genres = append(user.Genres, genre)
+			}
+		}
+		if len(taste) > 0 {
+			user.Embedding = taste
+		}
+		return true
+	})
+	if err == repository.ErrNotFound {
+		return "Error getting user", err
+	}
+	if err != nil {
+		return "Error updating user", err
+	}
+	return "success", nil
+}
+
+// loadCatalog returns all songs sorted by name together with their like counts
+func (s *onboardingService) loadCatalog(ctx context.Context) ([]*model.Song, map[string]int, error) {
+	songResp, err := s.songs(ctx).GetAllSongs(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	userResp, err := s.users(ctx).GetAllUsers(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	songs := songResp.Songs
+	sort.Slice(songs, func(i, j int) bool { return songs[i].Name < songs[j].Name })
+
+	popularity := make(map[string]int)
+	for _, user := range userResp.Users {
+		for _, name := range user.LikedSongs {
+			popularity[name]++
+		}
+	}
+	return songs, popularity, nil
+}
+
+func (s *onboardingService) songs(ctx context.Context) repository.SongRepository {
+	return s.songRepository.ForTenant(tenant.ID(ctx))
+}
+
+func (s *onboardingService) users(ctx context.Context) repository.UserRepository {
+	return s.userRepository.ForTenant(tenant.ID(ctx))
+}
+
+func dominantDimension(songs []*model.Song) int {
+	counts := make(map[int]int)
+	dim := 0
+	for _, song := range songs {
+		n := len(song.Embedding)
+		if n == 0 {
+			continue
+		}
+		counts[n]++
+		if counts[n] > counts[dim] || (counts[n] == counts[dim] && n < dim) {
+			dim = n
+		}
+	}
+	return dim
+}
+
+func genres(songs []*model.Song) []string {
+	seen := make(map[string]struct{})
+	var out []string
+	for _, song := range songs {
+		if _, ok := seen[song.Genre]; !ok && song.Genre != "" {
+			seen[song.Genre] = struct{}{}
+			out = append(out, song.Genre)
+		}
+	}
+	sort.Strings(out)
+	return out
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package recommender
+
+import "music-store/internal/model"
+
+// Corpus is the catalog and user base recommendations are computed against
+type Corpus struct {
+	Songs []*model.Song
+	Users []*model.User
+
+	songsByName map[string]*model.Song
+}
+
+func NewCorpus(songs []*model.Song, users []*model.User) *Corpus {
+	byName := make(map[string]*model.Song, len(songs))
+	for _, song := range songs {
+		byName[song.Name] = song
+	}
+	return &Corpus{Songs: songs, Users: users, songsByName: byName}
+}
+
+// Song returns the song with the given name, or nil if it is not in the catalog
+func (c *Corpus) Song(name string) *model.Song {
+	return c.songsByName[name]
+}
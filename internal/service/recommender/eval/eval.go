@@ -0,0 +1,215 @@
+// Package eval measures recommendation quality offline by hiding the most
+// recent likes of every user and checking how many of them the recommender
+// finds again.
+package eval
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"music-store/internal/model"
+	"music-store/internal/service/recommender"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+type (
+	Config struct {
+		K        int   // Recommendation cutoff for every metric
+		HoldOut  int   // Number of most recent likes hidden per user
+		Seed     int64 // Seeds the user sampling order
+		MaxUsers int   // Evaluate at most this many users, 0 means all
+		// Recommender sizes the candidate pools like the service does, nil
+		// means recommender.DefaultConfig
+		Recommender *recommender.Config
+	}
+
+	// Snapshot is the frozen state of the catalog and the users' likes an
+	// evaluation runs against
+	Snapshot struct {
+		Songs []*model.Song `json:"songs"`
+		Users []*model.User `json:"users"`
+	}
+
+	Report struct {
+		Users           int     `json:"users"`
+		K               int     `json:"k"`
+		HoldOut         int     `json:"hold_out"`
+		Seed            int64   `json:"seed"`
+		RecallAtK       float64 `json:"recall_at_k"`
+		NDCGAtK         float64 `json:"ndcg_at_k"`
+		CatalogCoverage float64 `json:"catalog_coverage"`
+		Novelty         float64 `json:"novelty"`
+	}
+)
+
+var errNoUsers = errors.New("no user has enough likes for the requested hold-out")
+
+// LoadSnapshot reads a snapshot previously written by Snapshot.Save
+func LoadSnapshot(path string) (*Snapshot, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var snapshot Snapshot
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		return nil, err
+	}
+	return &snapshot, nil
+}
+
+func (s *Snapshot) Save(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0o644)
+}
+
+// Run performs a leave-last-N-out evaluation of the pipeline. LikedSongs are
+// append-only, so the tail of the list holds the most recent likes.
+func Run(ctx context.Context, pipeline *recommender.Pipeline, snapshot *Snapshot, weights recommender.Weights, cfg Config) (*Report, error) {
+	if cfg.K <= 0 || cfg.HoldOut <= 0 {
+		return nil, fmt.Errorf("k and hold-out must be positive, got k=%d hold-out=%d", cfg.K, cfg.HoldOut)
+	}
+
+	// Users need at least one training like left after the split
+	trainUsers := make([]*model.User, 0, len(snapshot.Users))
+	heldOut := make(map[string][]string)
+	for _, user := range snapshot.Users {
+		train := *user
+		// A stored embedding may already encode the held-out likes
+		train.Embedding = nil
+
+		if n := len(user.LikedSongs); n > cfg.HoldOut {
+			train.LikedSongs = append([]string(nil), user.LikedSongs[:n-cfg.HoldOut]...)
+			heldOut[user.ID] = user.LikedSongs[n-cfg.HoldOut:]
+		}
+		trainUsers = append(trainUsers, &train)
+	}
+
+	var evaluated []*model.User
+	for _, user := range trainUsers {
+		if _, ok := heldOut[user.ID]; ok {
+			evaluated = append(evaluated, user)
+		}
+	}
+	if len(evaluated) == 0 {
+		return nil, errNoUsers
+	}
+
+	// Sort first so the seeded shuffle does not depend on snapshot order
+	sort.Slice(evaluated, func(i, j int) bool { return evaluated[i].ID < evaluated[j].ID })
+	rand.New(rand.NewSource(cfg.Seed)).Shuffle(len(evaluated), func(i, j int) {
+		evaluated[i], evaluated[j] = evaluated[j], evaluated[i]
+	})
+	if cfg.MaxUsers > 0 && len(evaluated) > cfg.MaxUsers {
+		evaluated = evaluated[:cfg.MaxUsers]
+	}
+
+	corpus := recommender.NewCorpus(snapshot.Songs, trainUsers)
+	popularity := likeCounts(trainUsers)
+
+	tuning := cfg.Recommender
+	if tuning == nil {
+		tuning = recommender.DefaultConfig()
+	}
+
+	var recallSum, ndcgSum, noveltySum float64
+	var recommendedCount int
+	recommended := make(map[string]struct{})
+	for _, user := range evaluated {
+		q := &recommender.Query{
+			User:           user,
+			Corpus:         corpus,
+			Limit:          cfg.K,
+			CandidateLimit: tuning.CandidateLimit(cfg.K),
+		}
+		recs, err := pipeline.Run(ctx, q, weights)
+		if err != nil {
+			return nil, err
+		}
+
+		relevant := make(map[string]struct{}, len(heldOut[user.ID]))
+		for _, name := range heldOut[user.ID] {
+			relevant[name] = struct{}{}
+		}
+
+		var hits int
+		var dcg float64
+		for i, rec := range recs {
+			recommended[rec.SongName] = struct{}{}
+			recommendedCount++
+			noveltySum += selfInformation(popularity[rec.SongName], len(trainUsers))
+
+			if _, ok := relevant[rec.SongName]; ok {
+				hits++
+				dcg += 1 / math.Log2(float64(i+2))
+			}
+		}
+
+		var idcg float64
+		for i := 0; i < len(relevant) && i < cfg.K; i++ {
+			idcg += 1 / math.Log2(float64(i+2))
+		}
+
+		recallSum += float64(hits) / float64(len(relevant))
+		ndcgSum += dcg / idcg
+	}
+
+	report := &Report{
+		Users:     len(evaluated),
+		K:         cfg.K,
+		HoldOut:   cfg.HoldOut,
+		Seed:      cfg.Seed,
+		RecallAtK: recallSum / float64(len(evaluated)),
+		NDCGAtK:   ndcgSum / float64(len(evaluated)),
+	}
+	if len(snapshot.Songs) > 0 {
+		report.CatalogCoverage = float64(len(recommended)) / float64(len(snapshot.Songs))
+	}
+	if recommendedCount > 0 {
+		report.Novelty = noveltySum / float64(recommendedCount)
+	}
+	return report, nil
+}
+
+func likeCounts(users []*model.User) map[string]int {
+	counts := make(map[string]int)
+	for _, user := range users {
+		for _, name := range user.LikedSongs {
+			counts[name]++
+		}
+	}
+	return counts
+}
+
+// selfInformation is -log2 of the share of users liking a song, smoothed so
+// songs nobody has liked yet stay finite
+func selfInformation(likes, users int) float64 {
+	return -math.Log2(float64(likes+1) / float64(users+1))
+}
+
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(r)
+}
+
+func (r *Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "users\t%d\n", r.Users)
+	fmt.Fprintf(tw, "k\t%d\n", r.K)
+	fmt.Fprintf(tw, "hold-out\t%d\n", r.HoldOut)
+	fmt.Fprintf(tw, "seed\t%d\n", r.Seed)
+	fmt.Fprintf(tw, "recall@%d\t%.4f\n", r.K, r.RecallAtK)
+	fmt.Fprintf(tw, "ndcg@%d\t%.4f\n", r.K, r.NDCGAtK)
+	fmt.Fprintf(tw, "catalog coverage\t%.4f\n", r.CatalogCoverage)
+	fmt.Fprintf(tw, "novelty\t%.4f\n", r.Novelty)
+	return tw.Flush()
+}
@@ -0,0 +1,114 @@
+package eval
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"music-store/internal/model"
+	"music-store/internal/service/recommender"
+)
+
+// testSnapshot is a small catalog of three genres whose users mostly like
+// songs of one genre, so held-out likes can be found again
+func testSnapshot() *Snapshot {
+	song := func(name, artist, genre, released string, embedding ...float64) *model.Song {
+		return &model.Song{Name: name, Artist: artist, Genre: genre, ReleaseDate: released, Embedding: embedding}
+	}
+	user := func(id string, likes ...string) *model.User {
+		return &model.User{ID: id, LikedSongs: likes}
+	}
+	return &Snapshot{
+		Songs: []*model.Song{
+			song("j1", "Ann", "jazz", "2020-01-01", 1, 0, 0),
+			song("j2", "Ann", "jazz", "2021-01-01", 0.9, 0.1, 0),
+			song("j3", "Bob", "jazz", "2022-01-01", 0.8, 0.2, 0),
+			song("j4", "Bob", "jazz", "2024-01-01", 0.9, 0, 0.1),
+			song("r1", "Cat", "rock", "2020-01-01", 0, 1, 0),
+			song("r2", "Cat", "rock", "2021-01-01", 0.1, 0.9, 0),
+			song("r3", "Dan", "rock", "2023-01-01", 0, 0.8, 0.2),
+			song("r4", "Dan", "rock", "2024-06-01", 0, 0.9, 0.1),
+			song("p1", "Eve", "pop", "2020-01-01", 0, 0, 1),
+			song("p2", "Eve", "pop", "2022-01-01", 0.1, 0, 0.9),
+			song("p3", "Fay", "pop", "2023-01-01", 0, 0.1, 0.9),
+			song("p4", "Fay", "pop", "2024-09-01", 0.2, 0, 0.8),
+		},
+		Users: []*model.User{
+			user("u1", "j1", "j2", "j3"),
+			user("u2", "j2", "j3", "j4"),
+			user("u3", "j1", "j4", "r1", "j3"),
+			user("u4", "r1", "r2", "r3"),
+			user("u5", "r2", "r3", "r4"),
+			user("u6", "p1", "p2", "p3"),
+			user("u7", "p2", "p3", "p4", "j1"),
+			user("u8", "p1"), // Too few likes to be evaluated
+		},
+	}
+}
+
+func TestRunIsDeterministic(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "snapshot.json")
+	if err := testSnapshot().Save(path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	snapshot, err := LoadSnapshot(path)
+	if err != nil {
+		t.Fatalf("LoadSnapshot: %v", err)
+	}
+
+	pipeline := recommender.NewDefaultPipeline(recommender.DefaultWeights())
+	config := Config{K: 3, HoldOut: 1, Seed: 42, MaxUsers: 5}
+	first, err := Run(ctx, pipeline, snapshot, nil, config)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	second, err := Run(ctx, pipeline, testSnapshot(), nil, config)
+	if err != nil {
+		t.Fatalf("second Run: %v", err)
+	}
+	if !reflect.DeepEqual(first, second) {
+		t.Fatalf("runs with the same seed differ:\n%+v\n%+v", first, second)
+	}
+
+	// A change to the pipeline that moves these numbers should be
+	// deliberate, update them along with it
+	want := &Report{Users: 5, K: 3, HoldOut: 1, Seed: 42, RecallAtK: 0.8, NDCGAtK: 0.578558, CatalogCoverage: 10.0 / 12, Novelty: 2.069273}
+	if first.Users != want.Users || first.K != want.K || first.HoldOut != want.HoldOut || first.Seed != want.Seed ||
+		!near(first.RecallAtK, want.RecallAtK) || !near(first.NDCGAtK, want.NDCGAtK) ||
+		!near(first.CatalogCoverage, want.CatalogCoverage) || !near(first.Novelty, want.Novelty) {
+		t.Fatalf("report = %+v, want %+v", first, want)
+	}
+}
+
+// Candidate pools are sized by the recommender configuration, as they are
+// when serving
+func TestRunUsesCandidateLimits(t *testing.T) {
+	pipeline := recommender.NewDefaultPipeline(recommender.DefaultWeights())
+	config := Config{K: 3, HoldOut: 1, Seed: 42, MaxUsers: 5, Recommender: &recommender.Config{CandidateFactor: 1, MinCandidates: 1}}
+	report, err := Run(context.Background(), pipeline, testSnapshot(), nil, config)
+	if err != nil {
+		t.Fatalf("Run: %v", err)
+	}
+	if !near(report.CatalogCoverage, 8.0/12) {
+		t.Fatalf("coverage with three candidates per source = %f, want %f", report.CatalogCoverage, 8.0/12)
+	}
+}
+
+func near(got, want float64) bool {
+	return math.Abs(got-want) < 1e-6
+}
+
+func TestRunErrors(t *testing.T) {
+	ctx := context.Background()
+	pipeline := recommender.NewDefaultPipeline(recommender.DefaultWeights())
+
+	if _, err := Run(ctx, pipeline, testSnapshot(), nil, Config{K: 0, HoldOut: 1}); err == nil {
+		t.Error("Run with k=0 succeeded")
+	}
+	if _, err := Run(ctx, pipeline, testSnapshot(), nil, Config{K: 3, HoldOut: 10}); err != errNoUsers {
+		t.Errorf("Run with a hold-out larger than every history error = %v, want errNoUsers", err)
+	}
+}
@@ -0,0 +1,71 @@
+package recommender
+
+import (
+	"context"
+	"music-store/internal/model"
+	"sort"
+)
+
+// blendRanker normalises each source's scores to [0, 1] by dividing by the
+// source's best score, then sums them up weighted per source
+type blendRanker struct{}
+
+func NewBlendRanker() Ranker {
+	return &blendRanker{}
+}
+
+func (r *blendRanker) Rank(ctx context.Context, q *Query, sets []CandidateSet, weights Weights) []*model.Recommendation {
+	byName := make(map[string]*model.Recommendation)
+	var ordered []*model.Recommendation
+
+	for _, set := range sets {
+		weight := weights[set.Source]
+
+		var best float64
+		for _, c := range set.Candidates {
+			if c.Score > best {
+				best = c.Score
+			}
+		}
+		if best <= 0 {
+			continue
+		}
+
+		for _, c := range set.Candidates {
+			component := weight * c.Score / best
+
+			rec, ok := byName[c.SongName]
+			if !ok {
+				rec = &model.Recommendation{SongName: c.SongName, Components: map[string]float64{}}
+				byName[c.SongName] = rec
+				ordered = append(ordered, rec)
+			}
+			rec.Score += component
+			rec.Components[set.Source] = component
+			rec.Sources = append(rec.Sources, set.Source)
+		}
+	}
+
+	sort.SliceStable(ordered, func(i, j int) bool {
+		if ordered[i].Score != ordered[j].Score {
+			return ordered[i].Score > ordered[j].Score
+		}
+		return ordered[i].SongName < ordered[j].SongName
+	})
+	if q.Limit > 0 && len(ordered) > q.Limit {
+		ordered = ordered[:q.Limit]
+	}
+
+	for _, rec := range ordered {
+		if !q.Debug {
+			rec.Sources = nil
+			rec.Components = nil
+			continue
+		}
+		// Strongest contributor first
+		sort.SliceStable(rec.Sources, func(i, j int) bool {
+			return rec.Components[rec.Sources[i]] > rec.Components[rec.Sources[j]]
+		})
+	}
+	return ordered
+}
@@ -0,0 +1,197 @@
+package recommender
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"music-store/internal/model"
+	"strconv"
+	"strings"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Names of the built-in candidate sources, also used as weight keys
+const (
+	SourceEmbeddingKNN = "embedding_knn"
+	SourceCoLikes      = "co_likes"
+	SourcePopularity   = "popularity"
+	SourceNewReleases  = "new_releases"
+)
+
+type (
+	// CandidateSource produces scored candidate songs for a single user.
+	// Scores only need to be comparable within one source, the Ranker
+	// takes care of bringing them onto a common scale.
+	CandidateSource interface {
+		Name() string
+		Candidates(ctx context.Context, q *Query) ([]Candidate, error)
+	}
+
+	// Ranker scores and blends the candidate sets of all sources into
+	// the final, ordered list of recommendations
+	Ranker interface {
+		Rank(ctx context.Context, q *Query, sets []CandidateSet, weights Weights) []*model.Recommendation
+	}
+
+	Candidate struct {
+		SongName string
+		Score    float64
+	}
+
+	CandidateSet struct {
+		Source     string
+		Candidates []Candidate
+	}
+
+	// Weights maps a source name to its contribution to the blended score
+	Weights map[string]float64
+
+	// Query carries everything a source needs to generate candidates
+	Query struct {
+		User           *model.User
+		Corpus         *Corpus
+		Limit          int // Number of recommendations to return
+		CandidateLimit int // Number of candidates each source may return
+		Debug          bool
+
+		exclude map[string]struct{}
+	}
+)
+
+// Excluded reports whether a song must not be recommended to the user
+func (q *Query) Excluded(songName string) bool {
+	_, ok := q.exclude[songName]
+	return ok
+}
+
+// Merge returns a copy of w with the given overrides applied
+func (w Weights) Merge(overrides Weights) Weights {
+	merged := make(Weights, len(w)+len(overrides))
+	for name, weight := range w {
+		merged[name] = weight
+	}
+	for name, weight := range overrides {
+		merged[name] = weight
+	}
+	return merged
+}
+
+// ParseWeights reads weights in the form "embedding_knn:1,popularity:0.5"
+func ParseWeights(value string) (Weights, error) {
+	weights := Weights{}
+	for _, pair := range strings.Split(value, ",") {
+		source, raw, found := strings.Cut(pair, ":")
+		if !found {
+			return nil, fmt.Errorf("weight %q must be source:value", pair)
+		}
+		// NaN would poison every blended score and break the ranking
+		weight, err := strconv.ParseFloat(raw, 64)
+		if err != nil || math.IsNaN(weight) || math.IsInf(weight, 0) || weight < 0 {
+			return nil, fmt.Errorf("weight for %q must be a finite number, zero or more", source)
+		}
+		weights[strings.TrimSpace(source)] = weight
+	}
+	return weights, nil
+}
+
+// DefaultWeights favours personal signals over global ones
+func DefaultWeights() Weights {
+	return Weights{
+		SourceEmbeddingKNN: 1.0,
+		SourceCoLikes:      0.8,
+		SourcePopularity:   0.3,
+		SourceNewReleases:  0.2,
+	}
+}
+
+type Pipeline struct {
+	sources []CandidateSource
+	ranker  Ranker
+	weights Weights
+}
+
+func NewPipeline(ranker Ranker, weights Weights, sources ...CandidateSource) *Pipeline {
+	return &Pipeline{sources: sources, ranker: ranker, weights: weights}
+}
+
+// NewDefaultPipeline wires every built-in source into a BlendRanker
+func NewDefaultPipeline(weights Weights) *Pipeline {
+	return NewPipeline(
+		NewBlendRanker(),
+		weights,
+		NewEmbeddingKNNSource(),
+		NewCoLikesSource(),
+		NewPopularitySource(),
+		NewNewReleasesSource(),
+	)
+}
+
+// Run collects candidates from every source with a positive weight and
+// hands them to the ranker. Songs the user already likes or dislikes, and
+// songs by artists the user has hidden, are never returned.
+func (p *Pipeline) Run(ctx context.Context, q *Query, overrides Weights) ([]*model.Recommendation, error) {
+	weights := p.weights.Merge(overrides)
+
+	q.exclude = excludedSongs(q.User, q.Corpus)
+
+	sets, err := p.candidates(ctx, q, weights)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, span := startSpan(ctx, "recommender.rank")
+	defer span.End()
+	recs := p.ranker.Rank(ctx, q, sets, weights)
+	span.SetAttributes(attribute.Int("recommender.recommendations", len(recs)))
+	return recs, nil
+}
+
+// candidates asks every source with a positive weight, one span each
+func (p *Pipeline) candidates(ctx context.Context, q *Query, weights Weights) (sets []CandidateSet, err error) {
+	ctx, span := startSpan(ctx, "recommender.candidates")
+	defer func() { endSpan(span, err) }()
+
+	sets = make([]CandidateSet, 0, len(p.sources))
+	for _, source := range p.sources {
+		if weights[source.Name()] <= 0 {
+			continue // Disabled for this request
+		}
+
+		sourceCtx, sourceSpan := startSpan(ctx, "recommender.source "+source.Name(),
+			trace.WithAttributes(attribute.String("recommender.source", source.Name())))
+		candidates, err := source.Candidates(sourceCtx, q)
+		sourceSpan.SetAttributes(attribute.Int("recommender.candidates", len(candidates)))
+		endSpan(sourceSpan, err)
+		if err != nil {
+			return nil, err
+		}
+		sets = append(sets, CandidateSet{Source: source.Name(), Candidates: candidates})
+	}
+	return sets, nil
+}
+
+func excludedSongs(user *model.User, corpus *Corpus) map[string]struct{} {
+	exclude := make(map[string]struct{}, len(user.LikedSongs)+len(user.DislikedSongs))
+	for _, liked := range user.LikedSongs {
+		exclude[liked] = struct{}{}
+	}
+	for _, disliked := range user.DislikedSongs {
+		exclude[disliked] = struct{}{}
+	}
+
+	if len(user.HiddenArtists) == 0 {
+		return exclude
+	}
+	hidden := make(map[string]struct{}, len(user.HiddenArtists))
+	for _, artist := range user.HiddenArtists {
+		hidden[strings.ToLower(artist)] = struct{}{}
+	}
+	for _, song := range corpus.Songs {
+		if _, ok := hidden[strings.ToLower(song.Artist)]; ok && song.Artist != "" {
+			exclude[song.Name] = struct{}{}
+		}
+	}
+	return exclude
+}
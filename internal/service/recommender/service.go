@@ -0,0 +1,132 @@
+package recommender
+
+import (
+	"context"
+	"music-store/internal/experiment"
+	"music-store/internal/model"
+	"music-store/internal/repository"
+	"music-store/internal/tenant"
+
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	defaultLimit = 10
+	maxLimit     = 100
+)
+
+// Config tunes candidate generation. Each source over-fetches so blending
+// has enough overlap to work with.
+type Config struct {
+	Weights         Weights // Source weights before experiment and request overrides
+	CandidateFactor int     // Candidates per source for each recommendation asked for
+	MinCandidates   int     // Candidates per source however few are asked for
+}
+
+// DefaultConfig returns the built-in tuning
+func DefaultConfig() *Config {
+	return &Config{Weights: DefaultWeights(), CandidateFactor: 5, MinCandidates: 50}
+}
+
+// CandidateLimit is the number of candidates each source returns when limit
+// recommendations are asked for
+func (c *Config) CandidateLimit(limit int) int {
+	if n := limit * c.CandidateFactor; n > c.MinCandidates {
+		return n
+	}
+	return c.MinCandidates
+}
+
+type Service interface {
+	Recommend(ctx context.Context, req *model.GetRecommendationsRequest) (*model.GetRecommendationsResponse, error)
+}
+
+type service struct {
+	songRepository repository.SongRepository
+	userRepository repository.UserRepository
+	pipeline       *Pipeline
+	experiments    experiment.Service
+	config         *Config
+}
+
+func NewService(songRepository repository.SongRepository, userRepository repository.UserRepository, pipeline *Pipeline, experiments experiment.Service, config *Config) Service {
+	return &service{songRepository: songRepository, userRepository: userRepository, pipeline: pipeline, experiments: experiments, config: config}
+}
+
+func (s *service) Recommend(ctx context.Context, req *model.GetRecommendationsRequest) (*model.GetRecommendationsResponse, error) {
+	userCtx, span := startSpan(ctx, "recommender.fetch_user")
+	userResp, err := s.users(userCtx).GetUser(userCtx, req.UserID)
+	endSpan(span, err)
+	if err != nil {
+		return nil, err
+	}
+
+	corpus, err := s.loadCorpus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	if limit > maxLimit {
+		limit = maxLimit
+	}
+	q := &Query{
+		User:           userResp.User,
+		Corpus:         corpus,
+		Limit:          limit,
+		CandidateLimit: s.config.CandidateLimit(limit),
+		Debug:          req.Debug,
+	}
+
+	// Variant weights replace the defaults, explicit request weights win over both
+	assignments := s.experiments.Assign(req.UserID)
+	weights := Weights{}
+	for _, a := range assignments {
+		weights = weights.Merge(a.Variant.Weights)
+	}
+	weights = weights.Merge(req.Weights)
+
+	recs, err := s.pipeline.Run(ctx, q, weights)
+	if err != nil {
+		return nil, err
+	}
+
+	songNames := make([]string, len(recs))
+	for i, rec := range recs {
+		songNames[i] = rec.SongName
+	}
+	s.experiments.LogImpressions(ctx, req.UserID, assignments, songNames)
+
+	return &model.GetRecommendationsResponse{
+		Recommendations: recs,
+		Experiments:     experiment.ToModel(assignments),
+	}, nil
+}
+
+func (s *service) loadCorpus(ctx context.Context) (corpus *Corpus, err error) {
+	ctx, span := startSpan(ctx, "recommender.load_corpus")
+	defer func() { endSpan(span, err) }()
+
+	songs, err := s.songs(ctx).GetAllSongs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	users, err := s.users(ctx).GetAllUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	span.SetAttributes(attribute.Int("recommender.songs", len(songs.Songs)), attribute.Int("recommender.users", len(users.Users)))
+	return NewCorpus(songs.Songs, users.Users), nil
+}
+
+// Each tenant has its own catalog, so the corpus is per tenant as well
+func (s *service) songs(ctx context.Context) repository.SongRepository {
+	return s.songRepository.ForTenant(tenant.ID(ctx))
+}
+
+func (s *service) users(ctx context.Context) repository.UserRepository {
+	return s.userRepository.ForTenant(tenant.ID(ctx))
+}
@@ -0,0 +1,167 @@
+package recommender
+
+import (
+	"context"
+	"math"
+	"sort"
+)
+
+// topCandidates sorts candidates by score and keeps at most limit of them.
+// Ties are broken by name so results are stable across requests.
+func topCandidates(candidates []Candidate, limit int) []Candidate {
+	sort.Slice(candidates, func(i, j int) bool {
+		if candidates[i].Score != candidates[j].Score {
+			return candidates[i].Score > candidates[j].Score
+		}
+		return candidates[i].SongName < candidates[j].SongName
+	})
+	if limit > 0 && len(candidates) > limit {
+		candidates = candidates[:limit]
+	}
+	return candidates
+}
+
+// embeddingKNNSource finds the songs closest to the user's taste vector
+type embeddingKNNSource struct{}
+
+func NewEmbeddingKNNSource() CandidateSource {
+	return &embeddingKNNSource{}
+}
+
+func (s *embeddingKNNSource) Name() string { return SourceEmbeddingKNN }
+
+func (s *embeddingKNNSource) Candidates(ctx context.Context, q *Query) ([]Candidate, error) {
+	taste := TasteVector(q.User, q.Corpus)
+	if len(taste) == 0 {
+		return nil, nil // Nothing to compare against yet
+	}
+
+	var candidates []Candidate
+	for _, song := range q.Corpus.Songs {
+		if q.Excluded(song.Name) {
+			continue
+		}
+		similarity := Cosine(taste, song.Embedding)
+		if similarity <= 0 {
+			continue
+		}
+		candidates = append(candidates, Candidate{SongName: song.Name, Score: similarity})
+	}
+	return topCandidates(candidates, q.CandidateLimit), nil
+}
+
+// coLikesSource recommends songs liked by users whose likes overlap with
+// the current user's, weighted by how strong that overlap is
+type coLikesSource struct{}
+
+func NewCoLikesSource() CandidateSource {
+	return &coLikesSource{}
+}
+
+func (s *coLikesSource) Name() string { return SourceCoLikes }
+
+func (s *coLikesSource) Candidates(ctx context.Context, q *Query) ([]Candidate, error) {
+	if len(q.User.LikedSongs) == 0 {
+		return nil, nil
+	}
+
+	liked := make(map[string]struct{}, len(q.User.LikedSongs))
+	for _, name := range q.User.LikedSongs {
+		liked[name] = struct{}{}
+	}
+
+	scores := make(map[string]float64)
+	for _, other := range q.Corpus.Users {
+		if other.ID == q.User.ID || len(other.LikedSongs) == 0 {
+			continue
+		}
+
+		overlap := 0
+		for _, name := range other.LikedSongs {
+			if _, ok := liked[name]; ok {
+				overlap++
+			}
+		}
+		if overlap == 0 {
+			continue
+		}
+
+		// Cosine similarity between the two binary like vectors
+		similarity := float64(overlap) / math.Sqrt(float64(len(liked)*len(other.LikedSongs)))
+		for _, name := range other.LikedSongs {
+			if q.Excluded(name) || q.Corpus.Song(name) == nil {
+				continue
+			}
+			scores[name] += similarity
+		}
+	}
+
+	candidates := make([]Candidate, 0, len(scores))
+	for name, score := range scores {
+		candidates = append(candidates, Candidate{SongName: name, Score: score})
+	}
+	return topCandidates(candidates, q.CandidateLimit), nil
+}
+
+// popularitySource ranks songs by how many users like them
+type popularitySource struct{}
+
+func NewPopularitySource() CandidateSource {
+	return &popularitySource{}
+}
+
+func (s *popularitySource) Name() string { return SourcePopularity }
+
+func (s *popularitySource) Candidates(ctx context.Context, q *Query) ([]Candidate, error) {
+	counts := make(map[string]float64)
+	for _, user := range q.Corpus.Users {
+		for _, name := range user.LikedSongs {
+			if q.Excluded(name) || q.Corpus.Song(name) == nil {
+				continue
+			}
+			counts[name]++
+		}
+	}
+
+	candidates := make([]Candidate, 0, len(counts))
+	for name, count := range counts {
+		candidates = append(candidates, Candidate{SongName: name, Score: count})
+	}
+	return topCandidates(candidates, q.CandidateLimit), nil
+}
+
+// newReleasesSource surfaces the most recently released songs. The score
+// decays with the position in the release order.
+type newReleasesSource struct{}
+
+func NewNewReleasesSource() CandidateSource {
+	return &newReleasesSource{}
+}
+
+func (s *newReleasesSource) Name() string { return SourceNewReleases }
+
+func (s *newReleasesSource) Candidates(ctx context.Context, q *Query) ([]Candidate, error) {
+	var released []Candidate
+	dates := make(map[string]string)
+	for _, song := range q.Corpus.Songs {
+		if song.ReleaseDate == "" || q.Excluded(song.Name) {
+			continue
+		}
+		released = append(released, Candidate{SongName: song.Name})
+		dates[song.Name] = song.ReleaseDate
+	}
+
+	// YYYY-MM-DD sorts lexicographically
+	sort.Slice(released, func(i, j int) bool {
+		di, dj := dates[released[i].SongName], dates[released[j].SongName]
+		if di != dj {
+			return di > dj
+		}
+		return released[i].SongName < released[j].SongName
+	})
+
+	for i := range released {
+		released[i].Score = 1 / float64(i+1)
+	}
+	return topCandidates(released, q.CandidateLimit), nil
+}
@@ -0,0 +1,88 @@
+package recommender
+
+import (
+	"math"
+	"music-store/internal/model"
+)
+
+// Cosine returns the cosine similarity of a and b, or 0 when the vectors
+// differ in length or either of them is zero
+func Cosine(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// Mean returns the element-wise mean of all vectors sharing the
+// dimension of the first one
+func Mean(vectors ...[]float64) []float64 {
+	if len(vectors) == 0 {
+		return nil
+	}
+
+	dim := len(vectors[0])
+	mean := make([]float64, dim)
+	count := 0
+	for _, v := range vectors {
+		if len(v) != dim {
+			continue // Skip mismatched embeddings
+		}
+		for i := range v {
+			mean[i] += v[i]
+		}
+		count++
+	}
+	for i := range mean {
+		mean[i] /= float64(count)
+	}
+	return mean
+}
+
+// dislikePenalty controls how far disliked songs push the taste vector away
+const dislikePenalty = 0.5
+
+// TasteVector returns the embedding that represents the user's taste. An
+// explicit user embedding wins, otherwise the liked songs are averaged. The
+// mean of the disliked songs is then subtracted so that neighbours of those
+// songs score lower.
+func TasteVector(user *model.User, corpus *Corpus) []float64 {
+	taste := user.Embedding
+	if len(taste) == 0 {
+		taste = Mean(songEmbeddings(user.LikedSongs, corpus)...)
+	}
+	if len(taste) == 0 {
+		return nil
+	}
+
+	disliked := Mean(songEmbeddings(user.DislikedSongs, corpus)...)
+	if len(disliked) != len(taste) {
+		return taste
+	}
+
+	adjusted := make([]float64, len(taste))
+	for i := range taste {
+		adjusted[i] = taste[i] - dislikePenalty*disliked[i]
+	}
+	return adjusted
+}
+
+func songEmbeddings(names []string, corpus *Corpus) [][]float64 {
+	var embeddings [][]float64
+	for _, name := range names {
+		if song := corpus.Song(name); song != nil && len(song.Embedding) > 0 {
+			embeddings = append(embeddings, song.Embedding)
+		}
+	}
+	return embeddings
+}
@@ -0,0 +1,38 @@
+package recommender
+
+import (
+	"reflect"
+	"testing"
+
+	"music-store/internal/model"
+)
+
+// Dislikes adjust the taste when recommending and never the stored embedding
+func TestTasteVectorAppliesDislikes(t *testing.T) {
+	corpus := NewCorpus([]*model.Song{
+		{Name: "a", Embedding: []float64{1, 0}},
+		{Name: "b", Embedding: []float64{0, 1}},
+		{Name: "c", Embedding: []float64{1, 1}},
+	}, nil)
+
+	tests := []struct {
+		name string
+		user *model.User
+		want []float64
+	}{
+		{name: "stored embedding", user: &model.User{Embedding: []float64{1, 0}}, want: []float64{1, 0}},
+		{name: "stored embedding with a dislike", user: &model.User{Embedding: []float64{1, 0}, DislikedSongs: []string{"b"}}, want: []float64{1, -0.5}},
+		{name: "liked songs with a dislike", user: &model.User{LikedSongs: []string{"a", "c"}, DislikedSongs: []string{"b"}}, want: []float64{1, 0}},
+		{name: "unknown disliked song", user: &model.User{Embedding: []float64{1, 0}, DislikedSongs: []string{"missing"}}, want: []float64{1, 0}},
+		{name: "nothing to go on", user: &model.User{DislikedSongs: []string{"b"}}, want: nil},
+	}
+	for _, test := range tests {
+		stored := append([]float64(nil), test.user.Embedding...)
+		if got := TasteVector(test.user, corpus); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("%s: taste = %v, want %v", test.name, got, test.want)
+		}
+		if len(stored) > 0 && !reflect.DeepEqual(test.user.Embedding, stored) {
+			t.Errorf("%s: stored embedding changed to %v", test.name, test.user.Embedding)
+		}
+	}
+}
@@ -4,6 +4,8 @@ import (
 	"context"
 	"music-store/internal/model"
 	"music-store/internal/repository"
+	"music-store/internal/tenant"
+	"time"
 )
 
 type SongService interface {
@@ -11,33 +13,55 @@ type SongService interface {
 	GetSong(ctx context.Context, name string) (*model.GetSongResponse, error)
 	GetAllSongs(ctx context.Context) (*model.GetSongListResponse, error)
 	UpdateSong(ctx context.Context, song *model.UpdateSongRequest) (string, error)
+	// DeleteSong moves the song to the trash, see RestoreSong
 	DeleteSong(ctx context.Context, name string) (string, error)
+	RestoreSong(ctx context.Context, name string) (string, error)
+	GetTrashedSongs(ctx context.Context) (*model.GetTrashResponse, error)
+	PurgeSong(ctx context.Context, name string) (string, error)
 }
 
 type songService struct {
 	songRepository repository.SongRepository
+	userRepository repository.UserRepository
+	trashRetention time.Duration
 }
 
-func NewSongService(songRepository repository.SongRepository) SongService {
-	return &songService{songRepository: songRepository}
+func NewSongService(songRepository repository.SongRepository, userRepository repository.UserRepository, config *Config) SongService {
+	return &songService{songRepository: songRepository, userRepository: userRepository, trashRetention: config.TrashRetention}
 }
 
 func (s *songService) CreateSong(ctx context.Context, song *model.CreateSongRequest) (string, error) {
-	return s.songRepository.CreateSong(song)
+	return s.songs(ctx).CreateSong(ctx, song)
 }
 
 func (s *songService) GetSong(ctx context.Context, name string) (*model.GetSongResponse, error) {
-	return s.songRepository.GetSong(name)
+	return s.songs(ctx).GetSong(ctx, name)
 }
 
 func (s *songService) GetAllSongs(ctx context.Context) (*model.GetSongListResponse, error) {
-	return s.songRepository.GetAllSongs()
+	return s.songs(ctx).GetAllSongs(ctx)
 }
 
 func (s *songService) UpdateSong(ctx context.Context, song *model.UpdateSongRequest) (string, error) {
-	return s.songRepository.UpdateSong(song)
+	return s.songs(ctx).UpdateSong(ctx, song)
 }
 
 func (s *songService) DeleteSong(ctx context.Context, name string) (string, error) {
-	return s.songRepository.DeleteSong(name)
+	song, err := s.songs(ctx).GetSong(ctx, name)
+	if err == repository.ErrNotFound {
+		return "success", nil // Nothing to delete
+	}
+	if err != nil {
+		return "Error getting song", err
+	}
+	return s.trashSong(ctx, song.Song)
+}
+
+// songs returns the repository of the tenant serving the request
+func (s *songService) songs(ctx context.Context) repository.SongRepository {
+	return s.songRepository.ForTenant(tenant.ID(ctx))
+}
+
+func (s *songService) users(ctx context.Context) repository.UserRepository {
+	return s.userRepository.ForTenant(tenant.ID(ctx))
 }
@@ -0,0 +1,147 @@
+package service
+
+import (
+	"context"
+	"music-store/internal/api"
+	"music-store/internal/auth"
+	"music-store/internal/model"
+	"music-store/internal/repository"
+	"time"
+)
+
+var (
+	ErrNotInTrash      error = api.NewError(404, "not found in trash")
+	ErrRestoreConflict error = api.NewError(409, "a record with the same key exists")
+)
+
+// restoreError maps the repository errors of a restore onto API errors
+func restoreError(err error) error {
+	switch err {
+	case repository.ErrNotFound:
+		return ErrNotInTrash
+	case repository.ErrAlreadyExists:
+		return ErrRestoreConflict
+	default:
+		return err
+	}
+}
+
+// deletedBy names the caller for trash entries
+func deletedBy(ctx context.Context) string {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		return principal.Subject
+	}
+	return ""
+}
+
+func (s *songService) RestoreSong(ctx context.Context, name string) (string, error) {
+	entry, err := s.songs(ctx).RestoreSong(ctx, name)
+	if err != nil {
+		return "Error restoring song", restoreError(err)
+	}
+
+	// Give the likes back to the users that still exist
+	for _, userID := range entry.LikedBy {
+		err := s.users(ctx).ModifyUser(ctx, userID, func(user *model.User) bool {
+			// A dislike made since the deletion wins
+			if contains(user.DislikedSongs, name) {
+				return false
+			}
+			var added bool
+			user.LikedSongs, added = appendUnique(user.LikedSongs, name, sameName)
+			return added
+		})
+		if err != nil && err != repository.ErrNotFound {
+			return "Error restoring likes", err
+		}
+	}
+	return "success", nil
+}
+
+func (s *songService) GetTrashedSongs(ctx context.Context) (*model.GetTrashResponse, error) {
+	songs, err := s.songs(ctx).GetTrashedSongs(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetTrashResponse{Songs: songs}, nil
+}
+
+func (s *songService) PurgeSong(ctx context.Context, name string) (string, error) {
+	return s.songs(ctx).PurgeSong(ctx, name)
+}
+
+// trashSong moves the song to the trash and takes it out of every user's
+// likes, remembering who liked it
+func (s *songService) trashSong(ctx context.Context, song *model.Song) (string, error) {
+	users, err := s.users(ctx).GetAllUsers(ctx)
+	if err != nil {
+		return "Error getting users", err
+	}
+
+	entry := &model.TrashedSong{
+		Song:      song,
+		DeletedAt: time.Now().UTC(),
+		DeletedBy: deletedBy(ctx),
+		ExpiresAt: time.Now().Add(s.trashRetention).UTC(),
+	}
+	for _, user := range users.Users {
+		if contains(user.LikedSongs, song.Name) {
+			entry.LikedBy = append(entry.LikedBy, user.ID)
+		}
+	}
+
+	// Trash first, a failure below then only leaves dangling likes
+	if msg, err := s.songs(ctx).TrashSong(ctx, entry, s.trashRetention); err != nil {
+		return msg, err
+	}
+	for _, userID := range entry.LikedBy {
+		err := s.users(ctx).ModifyUser(ctx, userID, func(user *model.User) bool {
+			var removed bool
+			user.LikedSongs, removed = removeValue(user.LikedSongs, song.Name, sameName)
+			return removed
+		})
+		if err != nil && err != repository.ErrNotFound {
+			return "Error removing likes", err
+		}
+	}
+	return "success", nil
+}
+
+// RestoreUser takes the user back out of the trash. Users may undo their own
+// deletion, one made by somebody else takes an admin to undo.
+func (s *userService) RestoreUser(ctx context.Context, id string) (string, error) {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok && !principal.HasRole(auth.RoleAdmin) {
+		entry, err := s.users(ctx).GetTrashedUser(ctx, id)
+		if err != nil {
+			return "Error restoring user", restoreError(err)
+		}
+		if entry.DeletedBy != principal.Subject {
+			return "Error restoring user", auth.ErrForbidden
+		}
+	}
+	if _, err := s.users(ctx).RestoreUser(ctx, id); err != nil {
+		return "Error restoring user", restoreError(err)
+	}
+	return "success", nil
+}
+
+func (s *userService) GetTrashedUsers(ctx context.Context) (*model.GetTrashResponse, error) {
+	users, err := s.users(ctx).GetTrashedUsers(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return &model.GetTrashResponse{Users: users}, nil
+}
+
+func (s *userService) PurgeUser(ctx context.Context, id string) (string, error) {
+	return s.users(ctx).PurgeUser(ctx, id)
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -4,6 +4,9 @@ import (
 	"context"
 	"music-store/internal/model"
 	"music-store/internal/repository"
+	"music-store/internal/tenant"
+	"strings"
+	"time"
 )
 
 type UserService interface {
@@ -15,121 +18,209 @@ type UserService interface {
 	LikeSong(ctx context.Context, userID, songName string) (string, error)
 	UnlikeSong(ctx context.Context, userID, songName string) (string, error)
 	GetLikedSongs(ctx context.Context, userID string) ([]string, error)
+	DislikeSong(ctx context.Context, userID, songName string) (string, error)
+	UndislikeSong(ctx context.Context, userID, songName string) (string, error)
+	HideArtist(ctx context.Context, userID, artist string) (string, error)
+	UnhideArtist(ctx context.Context, userID, artist string) (string, error)
+	GetNegativeFeedback(ctx context.Context, userID string) (*model.GetNegativeFeedbackResponse, error)
+	RestoreUser(ctx context.Context, id string) (string, error)
+	GetTrashedUsers(ctx context.Context) (*model.GetTrashResponse, error)
+	PurgeUser(ctx context.Context, id string) (string, error)
 }
 
 type userService struct {
 	userRepository repository.UserRepository
+	trashRetention time.Duration
 }
 
-func NewUserService(userRepository repository.UserRepository) UserService {
-	return &userService{userRepository: userRepository}
+func NewUserService(userRepository repository.UserRepository, config *Config) UserService {
+	return &userService{userRepository: userRepository, trashRetention: config.TrashRetention}
 }
 
 func (s *userService) CreateUser(ctx context.Context, user *model.CreateUserRequest) (string, error) {
-	return s.userRepository.CreateUser(user)
+	return s.users(ctx).CreateUser(ctx, user)
 }
 
 func (s *userService) GetUser(ctx context.Context, id string) (*model.GetUserResponse, error) {
-	return s.userRepository.GetUser(id)
+	return s.users(ctx).GetUser(ctx, id)
 }
 
 func (s *userService) GetAllUsers(ctx context.Context) (*model.GetUserListResponse, error) {
-	return s.userRepository.GetAllUsers()
+	return s.users(ctx).GetAllUsers(ctx)
 }
 
 func (s *userService) UpdateUser(ctx context.Context, user *model.UpdateUserRequest) (string, error) {
-	return s.userRepository.UpdateUser(user)
+	return s.users(ctx).UpdateUser(ctx, user)
 }
 
+// DeleteUser moves the user to the trash, likes and all, see RestoreUser
 func (s *userService) DeleteUser(ctx context.Context, id string) (string, error) {
-	return s.userRepository.DeleteUser(id)
+	user, err := s.users(ctx).GetUser(ctx, id)
+	if err == repository.ErrNotFound {
+		return "success", nil // Nothing to delete
+	}
+	if err != nil {
+		return "Error getting user", err
+	}
+
+	now := time.Now().UTC()
+	return s.users(ctx).TrashUser(ctx, &model.TrashedUser{
+		User:      user.User,
+		DeletedAt: now,
+		DeletedBy: deletedBy(ctx),
+		ExpiresAt: now.Add(s.trashRetention),
+	}, s.trashRetention)
 }
 
 func (s *userService) LikeSong(ctx context.Context, userID, songName string) (string, error) {
-	// Fetch user
-	userResp, err := s.userRepository.GetUser(userID)
+	return s.modifyUser(ctx, userID, func(user *model.User) string {
+		var added bool
+		if user.LikedSongs, added = appendUnique(user.LikedSongs, songName, sameName); !added {
+			return "Song already liked"
+		}
+		// A like overrides an earlier dislike
+		user.DislikedSongs, _ = removeValue(user.DislikedSongs, songName, sameName)
+		return ""
+	})
+}
+
+func (s *userService) UnlikeSong(ctx context.Context, userID, songName string) (string, error) {
+	return s.modifyUser(ctx, userID, func(user *model.User) string {
+		var removed bool
+		if user.LikedSongs, removed = removeValue(user.LikedSongs, songName, sameName); !removed {
+			return "Song was not liked"
+		}
+		return ""
+	})
+}
+
+func (s *userService) GetLikedSongs(ctx context.Context, userID string) ([]string, error) {
+	userResp, err := s.users(ctx).GetUser(ctx, userID)
 	if err != nil {
-		return "Error getting user", err
+		return nil, err
 	}
 	if userResp == nil || userResp.User == nil {
-		return "Error getting user", nil
+		return []string{}, nil
 	}
-
-	user := userResp.User
-
-	// Initialize LikedSongs if nil
-	if user.LikedSongs == nil {
-		user.LikedSongs = []string{}
+	if userResp.User.LikedSongs == nil {
+		return []string{}, nil
 	}
+	return userResp.User.LikedSongs, nil
+}
 
-	// Check duplicate
-	for _, liked := range user.LikedSongs {
-		if liked == songName {
-			return "Song already liked", nil
+// DislikeSong only records the dislike, the stored embedding stays as it is.
+// recommender.TasteVector moves the taste away from disliked songs at query
+// time, so undisliking needs no embedding update either.
+func (s *userService) DislikeSong(ctx context.Context, userID, songName string) (string, error) {
+	return s.modifyUser(ctx, userID, func(user *model.User) string {
+		var added bool
+		if user.DislikedSongs, added = appendUnique(user.DislikedSongs, songName, sameName); !added {
+			return "Song already disliked"
 		}
-	}
+		// A dislike overrides an earlier like
+		user.LikedSongs, _ = removeValue(user.LikedSongs, songName, sameName)
+		return ""
+	})
+}
 
-	// Append and persist
-	user.LikedSongs = append(user.LikedSongs, songName)
+func (s *userService) UndislikeSong(ctx context.Context, userID, songName string) (string, error) {
+	return s.modifyUser(ctx, userID, func(user *model.User) string {
+		var removed bool
+		if user.DislikedSongs, removed = removeValue(user.DislikedSongs, songName, sameName); !removed {
+			return "Song was not disliked"
+		}
+		return ""
+	})
+}
 
-	updateReq := &model.UpdateUserRequest{ID: userID, User: *user}
-	if _, err := s.userRepository.UpdateUser(updateReq); err != nil {
-		return "Error updating user", err
-	}
-	return "success", nil
+func (s *userService) HideArtist(ctx context.Context, userID, artist string) (string, error) {
+	return s.modifyUser(ctx, userID, func(user *model.User) string {
+		var added bool
+		if user.HiddenArtists, added = appendUnique(user.HiddenArtists, artist, strings.EqualFold); !added {
+			return "Artist already hidden"
+		}
+		return ""
+	})
 }
 
-func (s *userService) UnlikeSong(ctx context.Context, userID, songName string) (string, error) {
-	// Fetch user
-	userResp, err := s.userRepository.GetUser(userID)
+func (s *userService) UnhideArtist(ctx context.Context, userID, artist string) (string, error) {
+	return s.modifyUser(ctx, userID, func(user *model.User) string {
+		var removed bool
+		if user.HiddenArtists, removed = removeValue(user.HiddenArtists, artist, strings.EqualFold); !removed {
+			return "Artist was not hidden"
+		}
+		return ""
+	})
+}
+
+func (s *userService) GetNegativeFeedback(ctx context.Context, userID string) (*model.GetNegativeFeedbackResponse, error) {
+	userResp, err := s.users(ctx).GetUser(ctx, userID)
 	if err != nil {
-		return "Error getting user", err
+		return nil, err
 	}
+
+	resp := &model.GetNegativeFeedbackResponse{DislikedSongs: []string{}, HiddenArtists: []string{}}
 	if userResp == nil || userResp.User == nil {
-		return "Error getting user", nil
+		return resp, nil
 	}
-
-	user := userResp.User
-
-	// Initialize LikedSongs if nil
-	if user.LikedSongs == nil {
-		user.LikedSongs = []string{}
+	if userResp.User.DislikedSongs != nil {
+		resp.DislikedSongs = userResp.User.DislikedSongs
 	}
-
-	// Remove if present
-	updated := make([]string, 0, len(user.LikedSongs))
-	found := false
-	for _, liked := range user.LikedSongs {
-		if liked != songName {
-			updated = append(updated, liked)
-		} else {
-			found = true
-		}
+	if userResp.User.HiddenArtists != nil {
+		resp.HiddenArtists = userResp.User.HiddenArtists
 	}
+	return resp, nil
+}
 
-	if !found {
-		return "Song was not liked", nil
+// modifyUser applies fn to a user and persists the result atomically, so
+// concurrent likes and feedback never overwrite each other. When fn returns
+// a non-empty message nothing is written and that message is returned.
+func (s *userService) modifyUser(ctx context.Context, userID string, fn func(user *model.User) string) (string, error) {
+	var msg string
+	err := s.users(ctx).ModifyUser(ctx, userID, func(user *model.User) bool {
+		// fn runs again when the user changed under it
+		msg = fn(user)
+		return msg == ""
+	})
+	if err == repository.ErrNotFound {
+		return "Error getting user", err
 	}
-
-	user.LikedSongs = updated
-
-	updateReq := &model.UpdateUserRequest{ID: userID, User: *user}
-	if _, err := s.userRepository.UpdateUser(updateReq); err != nil {
+	if err != nil {
 		return "Error updating user", err
 	}
+	if msg != "" {
+		return msg, nil
+	}
 	return "success", nil
 }
 
-func (s *userService) GetLikedSongs(ctx context.Context, userID string) ([]string, error) {
-	userResp, err := s.userRepository.GetUser(userID)
-	if err != nil {
-		return nil, err
-	}
-	if userResp == nil || userResp.User == nil {
-		return []string{}, nil
+// users returns the repository of the tenant serving the request
+func (s *userService) users(ctx context.Context) repository.UserRepository {
+	return s.userRepository.ForTenant(tenant.ID(ctx))
+}
+
+// sameName compares song names, which are case-sensitive keys. Artists are
+// compared with strings.EqualFold, as the recommender filters them.
+func sameName(a, b string) bool { return a == b }
+
+func appendUnique(values []string, value string, equal func(a, b string) bool) ([]string, bool) {
+	for _, v := range values {
+		if equal(v, value) {
+			return values, false
+		}
 	}
-	if userResp.User.LikedSongs == nil {
-		return []string{}, nil
+	return append(values, value), true
+}
+
+func removeValue(values []string, value string, equal func(a, b string) bool) ([]string, bool) {
+	updated := make([]string, 0, len(values))
+	found := false
+	for _, v := range values {
+		if !equal(v, value) {
+			updated = append(updated, v)
+		} else {
+			found = true
+		}
 	}
-	return userResp.User.LikedSongs, nil
+	return updated, found
 }
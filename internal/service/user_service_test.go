@@ -0,0 +1,272 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"music-store/internal/auth"
+	"music-store/internal/model"
+	"music-store/internal/repository"
+	"music-store/internal/tenant"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func newTestUserService(t *testing.T) (UserService, *miniredis.Miniredis) {
+	t.Helper()
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	t.Cleanup(func() { client.Close() })
+	return NewUserService(repository.NewUserRepository(client), &Config{TrashRetention: time.Hour}), server
+}
+
+// call asserts the message of a service call that must not fail
+func call(t *testing.T, want string) func(string, error) {
+	t.Helper()
+	return func(msg string, err error) {
+		t.Helper()
+		if err != nil || msg != want {
+			t.Fatalf("got %q, %v, want %q", msg, err, want)
+		}
+	}
+}
+
+func getUser(t *testing.T, s UserService, ctx context.Context, id string) *model.User {
+	t.Helper()
+	resp, err := s.GetUser(ctx, id)
+	if err != nil {
+		t.Fatalf("GetUser(%s): %v", id, err)
+	}
+	return resp.User
+}
+
+func TestUserServiceCRUD(t *testing.T) {
+	s, _ := newTestUserService(t)
+	ctx := context.Background()
+
+	if _, err := s.GetUser(ctx, "u1"); err != repository.ErrNotFound {
+		t.Fatalf("GetUser(missing) error = %v, want ErrNotFound", err)
+	}
+
+	call(t, "success")(s.CreateUser(ctx, &model.CreateUserRequest{User: model.User{ID: "u1", Name: "One"}}))
+	call(t, "success")(s.CreateUser(ctx, &model.CreateUserRequest{User: model.User{ID: "u2", Name: "Two"}}))
+	call(t, "success")(s.UpdateUser(ctx, &model.UpdateUserRequest{ID: "u1", User: model.User{Name: "Uno"}}))
+
+	if got := getUser(t, s, ctx, "u1"); !reflect.DeepEqual(got, &model.User{ID: "u1", Name: "Uno"}) {
+		t.Fatalf("GetUser after update = %+v", got)
+	}
+	all, err := s.GetAllUsers(ctx)
+	if err != nil || len(all.Users) != 2 {
+		t.Fatalf("GetAllUsers = %v, %v, want 2 users", all, err)
+	}
+}
+
+func TestUserServiceLikes(t *testing.T) {
+	s, _ := newTestUserService(t)
+	ctx := context.Background()
+
+	// Every operation on a missing user fails with ErrNotFound
+	if msg, err := s.LikeSong(ctx, "ghost", "a"); err != repository.ErrNotFound || msg != "Error getting user" {
+		t.Fatalf("LikeSong(missing user) = %q, %v", msg, err)
+	}
+	if msg, err := s.UnlikeSong(ctx, "ghost", "a"); err != repository.ErrNotFound || msg != "Error getting user" {
+		t.Fatalf("UnlikeSong(missing user) = %q, %v", msg, err)
+	}
+	if _, err := s.GetLikedSongs(ctx, "ghost"); err != repository.ErrNotFound {
+		t.Fatalf("GetLikedSongs(missing user) error = %v", err)
+	}
+
+	call(t, "success")(s.CreateUser(ctx, &model.CreateUserRequest{User: model.User{ID: "u1"}}))
+
+	liked, err := s.GetLikedSongs(ctx, "u1")
+	if err != nil || liked == nil || len(liked) != 0 {
+		t.Fatalf("GetLikedSongs of a new user = %#v, %v, want an empty list", liked, err)
+	}
+
+	call(t, "Song was not liked")(s.UnlikeSong(ctx, "u1", "a"))
+	call(t, "success")(s.LikeSong(ctx, "u1", "a"))
+	call(t, "Song already liked")(s.LikeSong(ctx, "u1", "a"))
+	call(t, "success")(s.LikeSong(ctx, "u1", "b"))
+
+	if liked, _ := s.GetLikedSongs(ctx, "u1"); !reflect.DeepEqual(liked, []string{"a", "b"}) {
+		t.Fatalf("GetLikedSongs = %v, want [a b]", liked)
+	}
+
+	call(t, "success")(s.UnlikeSong(ctx, "u1", "a"))
+	call(t, "Song was not liked")(s.UnlikeSong(ctx, "u1", "a"))
+	if liked, _ := s.GetLikedSongs(ctx, "u1"); !reflect.DeepEqual(liked, []string{"b"}) {
+		t.Fatalf("GetLikedSongs = %v, want [b]", liked)
+	}
+
+	// Song names are taken as they come, likes do not check the catalog
+	call(t, "success")(s.LikeSong(ctx, "u1", "no such song"))
+}
+
+func TestUserServiceNegativeFeedback(t *testing.T) {
+	s, _ := newTestUserService(t)
+	ctx := context.Background()
+
+	if _, err := s.GetNegativeFeedback(ctx, "ghost"); err != repository.ErrNotFound {
+		t.Fatalf("GetNegativeFeedback(missing user) error = %v", err)
+	}
+
+	call(t, "success")(s.CreateUser(ctx, &model.CreateUserRequest{User: model.User{ID: "u1"}}))
+	feedback, err := s.GetNegativeFeedback(ctx, "u1")
+	if err != nil || feedback.DislikedSongs == nil || feedback.HiddenArtists == nil {
+		t.Fatalf("GetNegativeFeedback = %+v, %v, want empty lists", feedback, err)
+	}
+
+	call(t, "success")(s.LikeSong(ctx, "u1", "a"))
+	call(t, "success")(s.DislikeSong(ctx, "u1", "a"))
+	call(t, "Song already disliked")(s.DislikeSong(ctx, "u1", "a"))
+	if liked, _ := s.GetLikedSongs(ctx, "u1"); len(liked) != 0 {
+		t.Fatalf("a dislike left the like in place: %v", liked)
+	}
+	call(t, "success")(s.LikeSong(ctx, "u1", "b"))
+	call(t, "success")(s.DislikeSong(ctx, "u1", "b"))
+	call(t, "success")(s.LikeSong(ctx, "u1", "b"))
+	if feedback, _ := s.GetNegativeFeedback(ctx, "u1"); !reflect.DeepEqual(feedback.DislikedSongs, []string{"a"}) {
+		t.Fatalf("a like left the dislike in place: %v", feedback.DislikedSongs)
+	}
+	call(t, "success")(s.UnlikeSong(ctx, "u1", "b"))
+
+	// Artists match regardless of case, as the recommender filters them
+	call(t, "success")(s.HideArtist(ctx, "u1", "Ann"))
+	call(t, "Artist already hidden")(s.HideArtist(ctx, "u1", "Ann"))
+	call(t, "Artist already hidden")(s.HideArtist(ctx, "u1", "ann"))
+
+	feedback, _ = s.GetNegativeFeedback(ctx, "u1")
+	want := &model.GetNegativeFeedbackResponse{DislikedSongs: []string{"a"}, HiddenArtists: []string{"Ann"}}
+	if !reflect.DeepEqual(feedback, want) {
+		t.Fatalf("GetNegativeFeedback = %+v, want %+v", feedback, want)
+	}
+
+	call(t, "success")(s.UndislikeSong(ctx, "u1", "a"))
+	call(t, "Song was not disliked")(s.UndislikeSong(ctx, "u1", "a"))
+	call(t, "success")(s.UnhideArtist(ctx, "u1", "ANN"))
+	call(t, "Artist was not hidden")(s.UnhideArtist(ctx, "u1", "Ann"))
+
+	feedback, _ = s.GetNegativeFeedback(ctx, "u1")
+	if len(feedback.DislikedSongs) != 0 || len(feedback.HiddenArtists) != 0 {
+		t.Fatalf("GetNegativeFeedback after undo = %+v", feedback)
+	}
+}
+
+func TestUserServiceTrash(t *testing.T) {
+	s, server := newTestUserService(t)
+	ctx := auth.WithPrincipal(context.Background(), &auth.Principal{Subject: "admin"})
+
+	// Deleting a missing user is a no-op
+	call(t, "success")(s.DeleteUser(ctx, "ghost"))
+
+	call(t, "success")(s.CreateUser(ctx, &model.CreateUserRequest{User: model.User{ID: "u1"}}))
+	call(t, "success")(s.LikeSong(ctx, "u1", "a"))
+	call(t, "success")(s.DeleteUser(ctx, "u1"))
+
+	if _, err := s.GetUser(ctx, "u1"); err != repository.ErrNotFound {
+		t.Fatalf("GetUser of a deleted user error = %v", err)
+	}
+	trash, err := s.GetTrashedUsers(ctx)
+	if err != nil || len(trash.Users) != 1 {
+		t.Fatalf("GetTrashedUsers = %+v, %v, want one user", trash, err)
+	}
+	if entry := trash.Users[0]; entry.DeletedBy != "admin" || !entry.ExpiresAt.Equal(entry.DeletedAt.Add(time.Hour)) {
+		t.Fatalf("trash entry = %+v, want deleted by admin expiring after an hour", entry)
+	}
+	if ttl := server.TTL("trash:user:u1"); ttl != time.Hour {
+		t.Fatalf("trash TTL = %s, want the retention", ttl)
+	}
+
+	call(t, "success")(s.RestoreUser(ctx, "u1"))
+	if liked, _ := s.GetLikedSongs(ctx, "u1"); !reflect.DeepEqual(liked, []string{"a"}) {
+		t.Fatalf("restored user likes = %v, want [a]", liked)
+	}
+	if _, err := s.RestoreUser(ctx, "u1"); err != ErrNotInTrash {
+		t.Fatalf("second RestoreUser error = %v, want ErrNotInTrash", err)
+	}
+
+	call(t, "success")(s.DeleteUser(ctx, "u1"))
+	call(t, "success")(s.CreateUser(ctx, &model.CreateUserRequest{User: model.User{ID: "u1"}}))
+	if _, err := s.RestoreUser(ctx, "u1"); err != ErrRestoreConflict {
+		t.Fatalf("RestoreUser over a new user error = %v, want ErrRestoreConflict", err)
+	}
+
+	call(t, "success")(s.PurgeUser(ctx, "u1"))
+	if trash, _ := s.GetTrashedUsers(ctx); len(trash.Users) != 0 {
+		t.Fatalf("trash after purge = %+v", trash.Users)
+	}
+}
+
+func TestUserServiceTenants(t *testing.T) {
+	s, server := newTestUserService(t)
+	acme := tenant.WithTenant(context.Background(), &tenant.Tenant{ID: "acme"})
+
+	call(t, "success")(s.CreateUser(acme, &model.CreateUserRequest{User: model.User{ID: "u1"}}))
+	call(t, "success")(s.LikeSong(acme, "u1", "a"))
+
+	if !server.Exists("tenant:acme:user:u1") {
+		t.Fatalf("tenant user stored under %v", server.Keys())
+	}
+	if _, err := s.GetUser(context.Background(), "u1"); err != repository.ErrNotFound {
+		t.Fatalf("default tenant sees acme's user, error = %v", err)
+	}
+}
+
+// TestUserServiceConcurrentLikes guards against lost updates, each like
+// reads and rewrites the whole user
+func TestUserServiceConcurrentLikes(t *testing.T) {
+	s, _ := newTestUserService(t)
+	ctx := context.Background()
+	call(t, "success")(s.CreateUser(ctx, &model.CreateUserRequest{User: model.User{ID: "u1"}}))
+
+	const songs = 25
+	var wg sync.WaitGroup
+	for i := 0; i < songs; i++ {
+		wg.Add(1)
+		go func(song string) {
+			defer wg.Done()
+			if msg, err := s.LikeSong(ctx, "u1", song); err != nil || msg != "success" {
+				t.Errorf("LikeSong(%s) = %q, %v", song, msg, err)
+			}
+		}(fmt.Sprintf("song-%02d", i))
+	}
+	wg.Wait()
+
+	liked, err := s.GetLikedSongs(ctx, "u1")
+	if err != nil || len(liked) != songs {
+		t.Fatalf("got %d likes after %d concurrent likes: %v, %v", len(liked), songs, liked, err)
+	}
+
+	// Racing likes of one song record it once, the others see it liked
+	results := make(chan string, 10)
+	for i := 0; i < cap(results); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			msg, err := s.LikeSong(ctx, "u1", "contested")
+			if err != nil {
+				t.Errorf("LikeSong: %v", err)
+			}
+			results <- msg
+		}()
+	}
+	wg.Wait()
+	close(results)
+
+	successes := 0
+	for msg := range results {
+		if msg == "success" {
+			successes++
+		} else if msg != "Song already liked" {
+			t.Errorf("unexpected LikeSong message %q", msg)
+		}
+	}
+	if successes != 1 {
+		t.Fatalf("%d racing likes of one song succeeded, want 1", successes)
+	}
+}
@@ -0,0 +1,104 @@
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	net_http "net/http"
+	"strings"
+
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+// HeaderName carries the tenant ID explicitly
+const HeaderName = "X-Tenant-ID"
+
+// NewHandlerOption resolves the tenant from the X-Tenant-ID header or the
+// host name, falling back to the registry default. It must come before the
+// auth filter, API keys are stored per tenant. Requests that name no tenant
+// may still get one from their token, see auth.NewHandlerOption.
+func NewHandlerOption(registry *Registry) http.HandlerOption {
+	return http.HandlerWithFilter(func(next net_http.Handler) net_http.Handler {
+		return net_http.HandlerFunc(func(w net_http.ResponseWriter, r *net_http.Request) {
+			t, defaulted, err := registry.resolve(r)
+			if err != nil {
+				writeError(w, net_http.StatusNotFound, err.Error())
+				return
+			}
+			if t != nil {
+				ctx := WithTenant(r.Context(), t)
+				if defaulted {
+					ctx = context.WithValue(ctx, defaultedKey{}, true)
+				}
+				r = r.WithContext(ctx)
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+// resolve returns nil when the request names no tenant and there is no
+// default, defaulted is set when the request named none but there is
+func (r *Registry) resolve(req *net_http.Request) (t *Tenant, defaulted bool, err error) {
+	if !r.MultiTenant() {
+		return defaultTenant, false, nil
+	}
+
+	if id := req.Header.Get(HeaderName); id != "" {
+		if t, ok := r.byID[id]; ok {
+			return t, false, nil
+		}
+		return nil, false, ErrUnknownTenant
+	}
+
+	host := strings.ToLower(req.Host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if t, ok := r.byHost[host]; ok {
+		return t, false, nil
+	}
+	if sub, _, ok := strings.Cut(host, "."); ok && net.ParseIP(host) == nil {
+		if t, ok := r.byID[sub]; ok {
+			return t, false, nil
+		}
+	}
+
+	if r.Default != "" {
+		return r.byID[r.Default], true, nil
+	}
+	return nil, false, nil
+}
+
+// Required rejects requests without a tenant, for routes that are not
+// behind the auth filter
+func Required() http.HandlerOption {
+	return http.HandlerWithFilter(func(next net_http.Handler) net_http.Handler {
+		return net_http.HandlerFunc(func(w net_http.ResponseWriter, r *net_http.Request) {
+			if _, ok := Resolved(r.Context()); !ok {
+				writeError(w, net_http.StatusBadRequest, ErrTenantRequired.Error())
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+// RequireFeature answers 404 on routes of a feature the tenant switched off
+func RequireFeature(feature string) http.HandlerOption {
+	return http.HandlerWithFilter(func(next net_http.Handler) net_http.Handler {
+		return net_http.HandlerFunc(func(w net_http.ResponseWriter, r *net_http.Request) {
+			if !FromContext(r.Context()).Enabled(feature) {
+				writeError(w, net_http.StatusNotFound, feature+" is not available")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+func writeError(w net_http.ResponseWriter, code int, msg string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(map[string]string{"error": msg})
+}
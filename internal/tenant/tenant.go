@@ -0,0 +1,178 @@
+// Package tenant resolves which storefront a request belongs to and scopes
+// Redis keys to it. Every store runs on the same deployment, isolated by key
+// prefix.
+package tenant
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Features that can be switched off per tenant
+const (
+	FeatureAccounts        = "accounts" // Signup and login
+	FeatureRecommendations = "recommendations"
+	FeatureOnboarding      = "onboarding"
+	FeatureExperiments     = "experiments"
+)
+
+type (
+	Tenant struct {
+		ID    string   `json:"id"`
+		Name  string   `json:"name"`
+		Hosts []string `json:"hosts,omitempty"` // Host names served, besides {id}.<domain>
+		// Limits overrides rate limit groups, e.g. {"write": "10/1m"}
+		Limits     map[string]string `json:"limits,omitempty"`
+		DailyQuota *int              `json:"daily_quota,omitempty"`
+		// Features lists switched features, anything not listed is enabled
+		Features map[string]bool `json:"features,omitempty"`
+	}
+
+	// Registry holds the configured tenants. Without tenants every request
+	// belongs to the default tenant, whose keys carry no prefix.
+	Registry struct {
+		Tenants []*Tenant `json:"tenants"`
+		Default string    `json:"default,omitempty"` // Used when a request names no tenant
+
+		byID   map[string]*Tenant
+		byHost map[string]*Tenant
+	}
+
+	tenantKey    struct{}
+	defaultedKey struct{}
+)
+
+var (
+	ErrUnknownTenant  = errors.New("unknown tenant")
+	ErrTenantRequired = errors.New("tenant required")
+
+	// defaultTenant serves single-tenant deployments
+	defaultTenant = &Tenant{}
+)
+
+// LoadFile reads the registry from a JSON file
+func LoadFile(path string) (*Registry, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var registry Registry
+	if err := json.Unmarshal(data, &registry); err != nil {
+		return nil, err
+	}
+	if err := registry.index(); err != nil {
+		return nil, err
+	}
+	return &registry, nil
+}
+
+// Load reads the registry at path. Without a path the deployment is
+// single-tenant.
+func Load(path string) (*Registry, error) {
+	if path != "" {
+		return LoadFile(path)
+	}
+	registry := &Registry{}
+	return registry, registry.index()
+}
+
+func (r *Registry) index() error {
+	r.byID = map[string]*Tenant{}
+	r.byHost = map[string]*Tenant{}
+	for _, t := range r.Tenants {
+		if t.ID == "" || strings.ContainsAny(t.ID, ":*?[]{} ") {
+			return errors.Errorf("invalid tenant id %q", t.ID)
+		}
+		if _, ok := r.byID[t.ID]; ok {
+			return errors.Errorf("duplicate tenant %q", t.ID)
+		}
+		r.byID[t.ID] = t
+		for _, host := range t.Hosts {
+			r.byHost[strings.ToLower(host)] = t
+		}
+	}
+	if r.Default != "" && r.byID[r.Default] == nil {
+		return errors.Errorf("default tenant %q is not configured", r.Default)
+	}
+	return nil
+}
+
+// MultiTenant reports whether requests have to name a tenant
+func (r *Registry) MultiTenant() bool {
+	return len(r.Tenants) > 0
+}
+
+// IDs lists the configured tenants, or the default tenant without any
+func (r *Registry) IDs() []string {
+	if !r.MultiTenant() {
+		return []string{""}
+	}
+	ids := make([]string, len(r.Tenants))
+	for i, t := range r.Tenants {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+func (r *Registry) Get(id string) (*Tenant, bool) {
+	if !r.MultiTenant() && id == "" {
+		return defaultTenant, true
+	}
+	t, ok := r.byID[id]
+	return t, ok
+}
+
+// Enabled reports whether feature is switched on for the tenant
+func (t *Tenant) Enabled(feature string) bool {
+	enabled, ok := t.Features[feature]
+	return !ok || enabled
+}
+
+func WithTenant(ctx context.Context, t *Tenant) context.Context {
+	return context.WithValue(ctx, tenantKey{}, t)
+}
+
+// Resolved returns the tenant stored in ctx by the filters
+func Resolved(ctx context.Context) (*Tenant, bool) {
+	t, ok := ctx.Value(tenantKey{}).(*Tenant)
+	return t, ok && t != nil
+}
+
+// Defaulted reports whether the tenant in ctx is the registry default,
+// resolved because the request named none
+func Defaulted(ctx context.Context) bool {
+	defaulted, _ := ctx.Value(defaultedKey{}).(bool)
+	return defaulted
+}
+
+// FromContext returns the tenant resolved for the request, or the default
+// tenant outside of requests
+func FromContext(ctx context.Context) *Tenant {
+	if t, ok := Resolved(ctx); ok {
+		return t
+	}
+	return defaultTenant
+}
+
+// ID returns the ID of the tenant in ctx, empty for the default tenant
+func ID(ctx context.Context) string {
+	return FromContext(ctx).ID
+}
+
+// Prefix returns the key prefix of a tenant. The default tenant has none,
+// so single-tenant data keeps its keys.
+func Prefix(id string) string {
+	if id == "" {
+		return ""
+	}
+	return "tenant:" + id + ":"
+}
+
+// Key scopes a Redis key to the tenant in ctx
+func Key(ctx context.Context, key string) string {
+	return Prefix(ID(ctx)) + key
+}
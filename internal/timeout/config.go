@@ -0,0 +1,68 @@
+// Package timeout puts a deadline on every request. The deadline travels
+// with the request context down to Redis, so work done for a request that
+// ran out of time is abandoned instead of finished for nobody.
+package timeout
+
+import (
+	"strings"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// Config holds the timeout of every route. Routes are keyed by method and
+// pattern as they are bound, such as "GET /songs/:name", and override
+// Default. A timeout of 0 leaves the route unbounded.
+type Config struct {
+	Default time.Duration
+	Routes  map[string]time.Duration
+}
+
+// Route is the key of the route bound to pattern for method
+func Route(method, pattern string) string {
+	return method + " " + pattern
+}
+
+// For returns the timeout of the route bound to pattern for method
+func (c *Config) For(method, pattern string) time.Duration {
+	if d, ok := c.Routes[Route(method, pattern)]; ok {
+		return d
+	}
+	return c.Default
+}
+
+// ParseRoutes parses "<METHOD> <pattern>=<timeout>" pairs separated by
+// commas, such as "GET /songs=30s,POST /songs=5s"
+func ParseRoutes(s string) (map[string]time.Duration, error) {
+	routes := map[string]time.Duration{}
+	for _, pair := range strings.Split(s, ",") {
+		if pair = strings.TrimSpace(pair); pair == "" {
+			continue
+		}
+		route, raw, ok := strings.Cut(pair, "=")
+		if !ok {
+			return nil, errors.Errorf("route timeout %q must be <METHOD> <pattern>=<timeout>", pair)
+		}
+		route, err := CheckRoute(route)
+		if err != nil {
+			return nil, err
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(raw))
+		if err != nil || d < 0 {
+			return nil, errors.Errorf("invalid timeout %q for %s", raw, route)
+		}
+		routes[route] = d
+	}
+	return routes, nil
+}
+
+// CheckRoute normalizes the spacing of a route key and rejects keys that
+// cannot match a bound route
+func CheckRoute(route string) (string, error) {
+	method, pattern, ok := strings.Cut(strings.TrimSpace(route), " ")
+	pattern = strings.TrimSpace(pattern)
+	if !ok || method != strings.ToUpper(method) || !strings.HasPrefix(pattern, "/") {
+		return "", errors.Errorf("route %q must be <METHOD> <pattern>, such as \"GET /songs/:name\"", route)
+	}
+	return Route(method, pattern), nil
+}
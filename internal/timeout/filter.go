@@ -0,0 +1,74 @@
+package timeout
+
+import (
+	"context"
+	"encoding/json"
+	"music-store/internal/metrics"
+	net_http "net/http"
+
+	tmux "github.com/dimfeld/httptreemux/v5"
+	"github.com/unbxd/go-base/kit/transport/http"
+)
+
+// deadlineWriter answers 504 in place of whatever the handler writes once
+// the deadline has passed. Most handlers report errors in a 200 body, so
+// the status cannot be left to them.
+type deadlineWriter struct {
+	net_http.ResponseWriter
+	ctx         context.Context
+	wroteHeader bool
+	timedOut    bool
+}
+
+func (w *deadlineWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	w.wroteHeader = true
+	if w.ctx.Err() != context.DeadlineExceeded {
+		w.ResponseWriter.WriteHeader(code)
+		return
+	}
+
+	w.timedOut = true
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Del("Content-Length")
+	w.ResponseWriter.WriteHeader(net_http.StatusGatewayTimeout)
+	json.NewEncoder(w.ResponseWriter).Encode(map[string]string{"error": "request timed out"})
+}
+
+func (w *deadlineWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(net_http.StatusOK)
+	}
+	if w.timedOut {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// NewHandlerOption sets the deadline of the matched route on the request
+// context. It must come first so that the Redis calls of the other filters
+// are bounded too.
+func NewHandlerOption(config *Config) http.HandlerOption {
+	return http.HandlerWithFilter(func(next net_http.Handler) net_http.Handler {
+		return net_http.HandlerFunc(func(w net_http.ResponseWriter, r *net_http.Request) {
+			ctx := r.Context()
+			pattern := tmux.ContextRoute(ctx)
+
+			if d := config.For(r.Method, pattern); d > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, d)
+				defer cancel()
+				r = r.WithContext(ctx)
+				w = &deadlineWriter{ResponseWriter: w, ctx: ctx}
+			}
+
+			next.ServeHTTP(w, r)
+
+			if reason, ok := metrics.Reason(ctx); ok {
+				metrics.RequestAborted(pattern, r.Method, reason)
+			}
+		})
+	})
+}
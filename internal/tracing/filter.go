@@ -0,0 +1,66 @@
+package tracing
+
+import (
+	net_http "net/http"
+
+	tmux "github.com/dimfeld/httptreemux/v5"
+	"github.com/unbxd/go-base/kit/transport/http"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// statusWriter remembers the status code the handler answered with
+type statusWriter struct {
+	net_http.ResponseWriter
+	status int
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.status == 0 {
+		w.status = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = net_http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+// NewHandlerOption starts the span of a request, continuing the trace of
+// its traceparent header. It must come first so that the other filters
+// run inside the span.
+func NewHandlerOption() http.HandlerOption {
+	return http.HandlerWithFilter(func(next net_http.Handler) net_http.Handler {
+		return net_http.HandlerFunc(func(w net_http.ResponseWriter, r *net_http.Request) {
+			ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+			route := tmux.ContextRoute(ctx)
+
+			ctx, span := otel.Tracer(Name).Start(ctx, r.Method+" "+route,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(
+					attribute.String("http.request.method", r.Method),
+					attribute.String("http.route", route),
+					attribute.String("url.path", r.URL.Path),
+				),
+			)
+			defer span.End()
+
+			sw := &statusWriter{ResponseWriter: w}
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			if sw.status == 0 {
+				sw.status = net_http.StatusOK
+			}
+			span.SetAttributes(attribute.Int("http.response.status_code", sw.status))
+			if sw.status >= net_http.StatusInternalServerError {
+				span.SetStatus(codes.Error, net_http.StatusText(sw.status))
+			}
+		})
+	})
+}
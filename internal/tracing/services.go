@@ -0,0 +1,287 @@
+package tracing
+
+import (
+	"context"
+	"music-store/internal/model"
+	"music-store/internal/privacy"
+	"music-store/internal/revision"
+	"music-store/internal/service"
+	"music-store/internal/service/onboarding"
+	"music-store/internal/service/recommender"
+)
+
+// The decorators below record a span named after the interface and method
+// for every call. Arguments are left out, they are mostly user data.
+
+type songService struct {
+	next service.SongService
+}
+
+// NewSongService records a span for every call to next
+func NewSongService(next service.SongService) service.SongService {
+	return &songService{next: next}
+}
+
+func (s *songService) CreateSong(ctx context.Context, req *model.CreateSongRequest) (msg string, err error) {
+	ctx, span := Start(ctx, "SongService.CreateSong")
+	defer func() { End(span, err) }()
+	return s.next.CreateSong(ctx, req)
+}
+
+func (s *songService) GetSong(ctx context.Context, name string) (resp *model.GetSongResponse, err error) {
+	ctx, span := Start(ctx, "SongService.GetSong")
+	defer func() { End(span, err) }()
+	return s.next.GetSong(ctx, name)
+}
+
+func (s *songService) GetAllSongs(ctx context.Context) (resp *model.GetSongListResponse, err error) {
+	ctx, span := Start(ctx, "SongService.GetAllSongs")
+	defer func() { End(span, err) }()
+	return s.next.GetAllSongs(ctx)
+}
+
+func (s *songService) UpdateSong(ctx context.Context, req *model.UpdateSongRequest) (msg string, err error) {
+	ctx, span := Start(ctx, "SongService.UpdateSong")
+	defer func() { End(span, err) }()
+	return s.next.UpdateSong(ctx, req)
+}
+
+func (s *songService) DeleteSong(ctx context.Context, name string) (msg string, err error) {
+	ctx, span := Start(ctx, "SongService.DeleteSong")
+	defer func() { End(span, err) }()
+	return s.next.DeleteSong(ctx, name)
+}
+
+func (s *songService) RestoreSong(ctx context.Context, name string) (msg string, err error) {
+	ctx, span := Start(ctx, "SongService.RestoreSong")
+	defer func() { End(span, err) }()
+	return s.next.RestoreSong(ctx, name)
+}
+
+func (s *songService) GetTrashedSongs(ctx context.Context) (resp *model.GetTrashResponse, err error) {
+	ctx, span := Start(ctx, "SongService.GetTrashedSongs")
+	defer func() { End(span, err) }()
+	return s.next.GetTrashedSongs(ctx)
+}
+
+func (s *songService) PurgeSong(ctx context.Context, name string) (msg string, err error) {
+	ctx, span := Start(ctx, "SongService.PurgeSong")
+	defer func() { End(span, err) }()
+	return s.next.PurgeSong(ctx, name)
+}
+
+type userService struct {
+	next service.UserService
+}
+
+// NewUserService records a span for every call to next
+func NewUserService(next service.UserService) service.UserService {
+	return &userService{next: next}
+}
+
+func (s *userService) CreateUser(ctx context.Context, req *model.CreateUserRequest) (msg string, err error) {
+	ctx, span := Start(ctx, "UserService.CreateUser")
+	defer func() { End(span, err) }()
+	return s.next.CreateUser(ctx, req)
+}
+
+func (s *userService) GetUser(ctx context.Context, id string) (resp *model.GetUserResponse, err error) {
+	ctx, span := Start(ctx, "UserService.GetUser")
+	defer func() { End(span, err) }()
+	return s.next.GetUser(ctx, id)
+}
+
+func (s *userService) GetAllUsers(ctx context.Context) (resp *model.GetUserListResponse, err error) {
+	ctx, span := Start(ctx, "UserService.GetAllUsers")
+	defer func() { End(span, err) }()
+	return s.next.GetAllUsers(ctx)
+}
+
+func (s *userService) UpdateUser(ctx context.Context, req *model.UpdateUserRequest) (msg string, err error) {
+	ctx, span := Start(ctx, "UserService.UpdateUser")
+	defer func() { End(span, err) }()
+	return s.next.UpdateUser(ctx, req)
+}
+
+func (s *userService) DeleteUser(ctx context.Context, id string) (msg string, err error) {
+	ctx, span := Start(ctx, "UserService.DeleteUser")
+	defer func() { End(span, err) }()
+	return s.next.DeleteUser(ctx, id)
+}
+
+func (s *userService) LikeSong(ctx context.Context, userID, songName string) (msg string, err error) {
+	ctx, span := Start(ctx, "UserService.LikeSong")
+	defer func() { End(span, err) }()
+	return s.next.LikeSong(ctx, userID, songName)
+}
+
+func (s *userService) UnlikeSong(ctx context.Context, userID, songName string) (msg string, err error) {
+	ctx, span := Start(ctx, "UserService.UnlikeSong")
+	defer func() { End(span, err) }()
+	return s.next.UnlikeSong(ctx, userID, songName)
+}
+
+func (s *userService) GetLikedSongs(ctx context.Context, userID string) (songs []string, err error) {
+	ctx, span := Start(ctx, "UserService.GetLikedSongs")
+	defer func() { End(span, err) }()
+	return s.next.GetLikedSongs(ctx, userID)
+}
+
+func (s *userService) DislikeSong(ctx context.Context, userID, songName string) (msg string, err error) {
+	ctx, span := Start(ctx, "UserService.DislikeSong")
+	defer func() { End(span, err) }()
+	return s.next.DislikeSong(ctx, userID, songName)
+}
+
+func (s *userService) UndislikeSong(ctx context.Context, userID, songName string) (msg string, err error) {
+	ctx, span := Start(ctx, "UserService.UndislikeSong")
+	defer func() { End(span, err) }()
+	return s.next.UndislikeSong(ctx, userID, songName)
+}
+
+func (s *userService) HideArtist(ctx context.Context, userID, artist string) (msg string, err error) {
+	ctx, span := Start(ctx, "UserService.HideArtist")
+	defer func() { End(span, err) }()
+	return s.next.HideArtist(ctx, userID, artist)
+}
+
+func (s *userService) UnhideArtist(ctx context.Context, userID, artist string) (msg string, err error) {
+	ctx, span := Start(ctx, "UserService.UnhideArtist")
+	defer func() { End(span, err) }()
+	return s.next.UnhideArtist(ctx, userID, artist)
+}
+
+func (s *userService) GetNegativeFeedback(ctx context.Context, userID string) (resp *model.GetNegativeFeedbackResponse, err error) {
+	ctx, span := Start(ctx, "UserService.GetNegativeFeedback")
+	defer func() { End(span, err) }()
+	return s.next.GetNegativeFeedback(ctx, userID)
+}
+
+func (s *userService) RestoreUser(ctx context.Context, id string) (msg string, err error) {
+	ctx, span := Start(ctx, "UserService.RestoreUser")
+	defer func() { End(span, err) }()
+	return s.next.RestoreUser(ctx, id)
+}
+
+func (s *userService) GetTrashedUsers(ctx context.Context) (resp *model.GetTrashResponse, err error) {
+	ctx, span := Start(ctx, "UserService.GetTrashedUsers")
+	defer func() { End(span, err) }()
+	return s.next.GetTrashedUsers(ctx)
+}
+
+func (s *userService) PurgeUser(ctx context.Context, id string) (msg string, err error) {
+	ctx, span := Start(ctx, "UserService.PurgeUser")
+	defer func() { End(span, err) }()
+	return s.next.PurgeUser(ctx, id)
+}
+
+type authService struct {
+	next service.AuthService
+}
+
+// NewAuthService records a span for every call to next
+func NewAuthService(next service.AuthService) service.AuthService {
+	return &authService{next: next}
+}
+
+func (s *authService) Signup(ctx context.Context, req *model.SignupRequest) (resp *model.SignupResponse, err error) {
+	ctx, span := Start(ctx, "AuthService.Signup")
+	defer func() { End(span, err) }()
+	return s.next.Signup(ctx, req)
+}
+
+func (s *authService) Login(ctx context.Context, req *model.LoginRequest) (tokens *model.AuthTokens, err error) {
+	ctx, span := Start(ctx, "AuthService.Login")
+	defer func() { End(span, err) }()
+	return s.next.Login(ctx, req)
+}
+
+func (s *authService) Refresh(ctx context.Context, req *model.RefreshTokenRequest) (tokens *model.AuthTokens, err error) {
+	ctx, span := Start(ctx, "AuthService.Refresh")
+	defer func() { End(span, err) }()
+	return s.next.Refresh(ctx, req)
+}
+
+func (s *authService) Logout(ctx context.Context, req *model.LogoutRequest) (msg string, err error) {
+	ctx, span := Start(ctx, "AuthService.Logout")
+	defer func() { End(span, err) }()
+	return s.next.Logout(ctx, req)
+}
+
+type recommendationService struct {
+	next recommender.Service
+}
+
+// NewRecommendationService records a span for every call to next
+func NewRecommendationService(next recommender.Service) recommender.Service {
+	return &recommendationService{next: next}
+}
+
+func (s *recommendationService) Recommend(ctx context.Context, req *model.GetRecommendationsRequest) (resp *model.GetRecommendationsResponse, err error) {
+	ctx, span := Start(ctx, "RecommendationService.Recommend")
+	defer func() { End(span, err) }()
+	return s.next.Recommend(ctx, req)
+}
+
+type onboardingService struct {
+	next onboarding.Service
+}
+
+// NewOnboardingService records a span for every call to next
+func NewOnboardingService(next onboarding.Service) onboarding.Service {
+	return &onboardingService{next: next}
+}
+
+func (s *onboardingService) GetPicks(ctx context.Context, req *model.GetOnboardingPicksRequest) (resp *model.GetOnboardingPicksResponse, err error) {
+	ctx, span := Start(ctx, "OnboardingService.GetPicks")
+	defer func() { End(span, err) }()
+	return s.next.GetPicks(ctx, req)
+}
+
+func (s *onboardingService) Onboard(ctx context.Context, req *model.OnboardUserRequest) (msg string, err error) {
+	ctx, span := Start(ctx, "OnboardingService.Onboard")
+	defer func() { End(span, err) }()
+	return s.next.Onboard(ctx, req)
+}
+
+type revisionService struct {
+	next revision.Service
+}
+
+// NewRevisionService records a span for every call to next
+func NewRevisionService(next revision.Service) revision.Service {
+	return &revisionService{next: next}
+}
+
+func (s *revisionService) GetRevisions(ctx context.Context, name string) (resp *model.GetSongRevisionsResponse, err error) {
+	ctx, span := Start(ctx, "RevisionService.GetRevisions")
+	defer func() { End(span, err) }()
+	return s.next.GetRevisions(ctx, name)
+}
+
+func (s *revisionService) Revert(ctx context.Context, name string, rev int64) (msg string, err error) {
+	ctx, span := Start(ctx, "RevisionService.Revert")
+	defer func() { End(span, err) }()
+	return s.next.Revert(ctx, name, rev)
+}
+
+type privacyService struct {
+	next privacy.Service
+}
+
+// NewPrivacyService records a span for every call to next
+func NewPrivacyService(next privacy.Service) privacy.Service {
+	return &privacyService{next: next}
+}
+
+func (s *privacyService) Export(ctx context.Context, userID string) (export *model.UserExport, err error) {
+	ctx, span := Start(ctx, "PrivacyService.Export")
+	defer func() { End(span, err) }()
+	return s.next.Export(ctx, userID)
+}
+
+func (s *privacyService) Erase(ctx context.Context, userID string) (tombstone *model.ErasureTombstone, err error) {
+	ctx, span := Start(ctx, "PrivacyService.Erase")
+	defer func() { End(span, err) }()
+	return s.next.Erase(ctx, userID)
+}
@@ -0,0 +1,114 @@
+// Package tracing records OpenTelemetry spans for requests, service calls
+// and Redis commands. Spans go to the global tracer provider, which Setup
+// installs, so they cost next to nothing while tracing is off.
+package tracing
+
+import (
+	"context"
+	"io"
+	"music-store/internal/repository"
+	"os"
+
+	"github.com/pkg/errors"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Exporters spans can be sent to
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"   // OTLP over HTTP to Endpoint
+	ExporterStdout = "stdout" // Pretty printed JSON, for local runs
+	ExporterFile   = "file"   // One JSON span per line appended to File
+)
+
+// Name is the instrumentation scope of the spans of the music store
+const Name = "music-store"
+
+type Config struct {
+	Exporter    string
+	Endpoint    string // URL of the OTLP collector, such as http://localhost:4318
+	File        string
+	ServiceName string
+	// SampleRatio is the share of new traces recorded. Requests carrying a
+	// traceparent follow the decision of their caller.
+	SampleRatio float64
+}
+
+// Setup installs the tracer provider of config and the W3C trace context
+// propagator. shutdown flushes the spans still buffered, it must run
+// before the process exits.
+func Setup(ctx context.Context, config *Config) (shutdown func(context.Context) error, err error) {
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	var exporter sdktrace.SpanExporter
+	var file io.Closer
+	switch config.Exporter {
+	case ExporterNone, "":
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx, otlptracehttp.WithEndpointURL(config.Endpoint))
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithPrettyPrint())
+	case ExporterFile:
+		f, openErr := os.OpenFile(config.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if openErr != nil {
+			return nil, errors.Wrap(openErr, "opening trace file")
+		}
+		file = f
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(f))
+	default:
+		return nil, errors.Errorf("unknown exporter %q", config.Exporter)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "creating %s exporter", config.Exporter)
+	}
+
+	res, err := resource.Merge(resource.Default(),
+		resource.NewSchemaless(attribute.String("service.name", config.ServiceName)))
+	if err != nil {
+		return nil, errors.Wrap(err, "describing the service")
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(config.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		err := provider.Shutdown(ctx)
+		if file != nil {
+			if closeErr := file.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		return err
+	}, nil
+}
+
+// Start begins a span that is a child of the one in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(Name).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End ends span, marking it failed by err. Missing records and conflicts
+// are answers, not failures.
+func End(span trace.Span, err error) {
+	switch err {
+	case nil, redis.Nil, repository.ErrNotFound, repository.ErrAlreadyExists, repository.ErrUsernameTaken:
+	default:
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
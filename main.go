@@ -1,59 +1,143 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"log"
-	"music-store/internal/controller"
-	"music-store/internal/repository"
-	"music-store/internal/service"
+	"log/slog"
+	"music-store/internal/app"
+	"music-store/internal/config"
+	"music-store/internal/logging"
+	"music-store/internal/tracing"
 	"music-store/utils"
+	"net"
+	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/unbxd/go-base/kit/transport/http"
 )
 
 func main() {
-	// Initialize Redis connection with default configuration
-	err := utils.InitRedisWithDefaults()
+	args := os.Args[1:]
+	if len(args) > 0 {
+		switch args[0] {
+		case "eval":
+			if err := runEval(os.Args[2:]); err != nil {
+				log.Fatalf("Evaluation failed: %v", err)
+			}
+			return
+		case "apikey":
+			if err := runAPIKey(os.Args[2:]); err != nil {
+				log.Fatalf("API key command failed: %v", err)
+			}
+			return
+		case "config":
+			if err := runConfig(os.Args[2:]); err != nil {
+				log.Fatalf("Config command failed: %v", err)
+			}
+			return
+		case "serve":
+			args = args[1:]
+		}
+	}
+
+	if err := serve(args); err != nil {
+		slog.Error("server failed", "error", err)
+		os.Exit(1)
+	}
+	slog.Info("server stopped")
+}
+
+// serve runs the HTTP server until SIGINT or SIGTERM, then drains it and
+// releases everything it holds. args are configuration flags such as
+// -config or -server.port. Errors are returned rather than fatal so the
+// deferred cleanup runs, a failed drain or cleanup fails the process.
+func serve(args []string) (err error) {
+	settings, err := loadSettings(args)
 	if err != nil {
-		log.Fatalf("Failed to initialize Redis: %v", err)
+		return errors.Wrap(err, "loading configuration")
 	}
+	logging.Setup(settings.LoggingConfig())
 
-	// Get Redis client for use in your application
-	redisClient := utils.GetRedisClient()
-	if redisClient == nil {
-		log.Fatal("Redis client is nil")
+	// Spans still buffered are exported once everything else is closed
+	shutdownTracing, err := tracing.Setup(context.Background(), settings.TracingConfig())
+	if err != nil {
+		return errors.Wrap(err, "initializing tracing")
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if shutdownErr := shutdownTracing(ctx); shutdownErr != nil && err == nil {
+			err = errors.Wrap(shutdownErr, "flushing traces")
+		}
+	}()
 
-	// Initialize dependencies
-	userRepo := repository.NewUserRepository(redisClient)
-	userSvc := service.NewUserService(userRepo)
-	userController := controller.NewUserController(userSvc)
+	// Initialize Redis connection
+	if err := utils.InitRedis(settings.RedisConfig()); err != nil {
+		return errors.Wrap(err, "initializing Redis")
+	}
+	defer func() {
+		if closeErr := utils.CloseRedis(); closeErr != nil && err == nil {
+			err = errors.Wrap(closeErr, "closing Redis")
+		}
+	}()
+	redisClient := utils.GetRedisClient()
 
-	songRepo := repository.NewSongRepository(redisClient)
-	songSvc := service.NewSongService(songRepo)
-	songController := controller.NewSongController(songSvc)
+	config, err := app.LoadConfig(settings, redisClient)
+	if err != nil {
+		return errors.Wrap(err, "loading configuration")
+	}
 
 	// Initialize HTTP transport
-	transport, err := http.NewTransport("0.0.0.0", "8080")
+	transport, err := http.NewTransport(settings.Server.Host, strconv.Itoa(settings.Server.Port))
 	if err != nil {
-		log.Fatalf("Failed to initialize HTTP transport: %v", err)
+		return errors.Wrap(err, "initializing HTTP transport")
 	}
 
-	// Bind user routes
-	userController.Bind(transport, []http.HandlerOption{})
-	songController.Bind(transport, []http.HandlerOption{})
-
-	// Close Redis connection when done
+	// Bind every route
+	application, err := app.New(config, redisClient, transport)
+	if err != nil {
+		return errors.Wrap(err, "initializing application")
+	}
+	// Runs after the server drained, so events of the last requests are
+	// flushed before Redis is closed
 	defer func() {
-		if err := utils.CloseRedis(); err != nil {
-			log.Printf("Error closing Redis connection: %v", err)
+		if closeErr := application.Close(); closeErr != nil && err == nil {
+			err = errors.Wrap(closeErr, "closing application")
 		}
 	}()
 
-	log.Println("Music Store application started successfully!")
+	listener, err := net.Listen("tcp", settings.Server.Address())
+	if err != nil {
+		return errors.Wrap(err, "listening")
+	}
 
-	// Start the HTTP server
-	log.Println("Starting HTTP server on :8080...")
-	if err := transport.ListenAndServe(); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+	slog.Info("music store started", "address", settings.Server.Address())
+
+	// A second signal during the drain kills the process
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	return app.Serve(ctx, transport, listener, time.Duration(settings.Server.ShutdownTimeout))
+}
+
+// loadSettings reads and validates the configuration. Subcommands pass no
+// args and so read only CONFIG_FILE and the environment.
+func loadSettings(args []string) (*config.Config, error) {
+	settings, err := config.Load(flag.NewFlagSet("music-store", flag.ContinueOnError), args)
+	if err != nil {
+		return nil, err
+	}
+	if err := settings.Validate(); err != nil {
+		return nil, err
 	}
+	return settings, nil
 }
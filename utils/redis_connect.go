@@ -2,74 +2,207 @@ package utils
 
 import (
 	"context"
-	"log"
+	"crypto/tls"
+	"crypto/x509"
+	"log/slog"
 	"os"
+	"sync"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/redis/go-redis/v9"
 )
 
-var (
-	RedisClient *redis.Client
-	ctx         = context.Background()
+var RedisClient redis.UniversalClient
+
+// RedisMode selects how the client reaches Redis
+type RedisMode string
+
+const (
+	RedisStandalone RedisMode = "standalone"
+	RedisSentinel   RedisMode = "sentinel"
+	RedisCluster    RedisMode = "cluster"
 )
 
 // RedisConfig holds Redis connection configuration
 type RedisConfig struct {
-	Address  string
-	Password string
-	Database int
+	Mode       RedisMode
+	Addresses  []string // The server, the sentinels or the cluster seed nodes
+	MasterName string   // Sentinel only
+	Username   string   // ACL user, empty for the default user
+	Password   string
+	Database   int // Must be 0 in cluster mode
+
+	SentinelPassword string // Sentinel only, when the sentinels require auth
+
+	TLS *RedisTLSConfig // nil disables TLS
+
+	PoolSize        int
+	MinIdleConns    int
+	DialTimeout     time.Duration
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	PoolTimeout     time.Duration
+	MaxRetries      int
+	MinRetryBackoff time.Duration
+	MaxRetryBackoff time.Duration
 }
 
-// GetDefaultRedisConfig returns default Redis configuration
-func GetDefaultRedisConfig() *RedisConfig {
-	address := os.Getenv("REDIS_ADDR")
-	if address == "" {
-		address = "localhost:6379" // Default for local development
+// RedisTLSConfig holds the TLS settings, CAFile falls back to the system
+// pool and CertFile/KeyFile are only needed for mutual TLS
+type RedisTLSConfig struct {
+	CAFile             string
+	CertFile           string
+	KeyFile            string
+	ServerName         string
+	InsecureSkipVerify bool
+}
+
+// NewRedisClient builds the client for the configured mode without
+// connecting. Commands give up when their context is cancelled or its
+// deadline passes, not only at the read and write timeouts.
+func NewRedisClient(config *RedisConfig) (redis.UniversalClient, error) {
+	if len(config.Addresses) == 0 {
+		return nil, errors.New("no Redis address configured")
+	}
+
+	var tlsConfig *tls.Config
+	if config.TLS != nil {
+		var err error
+		if tlsConfig, err = config.TLS.build(); err != nil {
+			return nil, err
+		}
+	}
+
+	switch config.Mode {
+	case RedisStandalone:
+		if len(config.Addresses) > 1 {
+			return nil, errors.Errorf("standalone mode takes one address, got %d", len(config.Addresses))
+		}
+		return redis.NewClient(&redis.Options{
+			Addr:                  config.Addresses[0],
+			Username:              config.Username,
+			Password:              config.Password,
+			DB:                    config.Database,
+			TLSConfig:             tlsConfig,
+			DialTimeout:           config.DialTimeout,
+			ReadTimeout:           config.ReadTimeout,
+			WriteTimeout:          config.WriteTimeout,
+			PoolSize:              config.PoolSize,
+			MinIdleConns:          config.MinIdleConns,
+			PoolTimeout:           config.PoolTimeout,
+			MaxRetries:            config.MaxRetries,
+			MinRetryBackoff:       config.MinRetryBackoff,
+			MaxRetryBackoff:       config.MaxRetryBackoff,
+			ContextTimeoutEnabled: true,
+		}), nil
+	case RedisSentinel:
+		if config.MasterName == "" {
+			return nil, errors.New("sentinel mode needs REDIS_MASTER_NAME")
+		}
+		return redis.NewFailoverClient(&redis.FailoverOptions{
+			MasterName:            config.MasterName,
+			SentinelAddrs:         config.Addresses,
+			SentinelPassword:      config.SentinelPassword,
+			Username:              config.Username,
+			Password:              config.Password,
+			DB:                    config.Database,
+			TLSConfig:             tlsConfig,
+			DialTimeout:           config.DialTimeout,
+			ReadTimeout:           config.ReadTimeout,
+			WriteTimeout:          config.WriteTimeout,
+			PoolSize:              config.PoolSize,
+			MinIdleConns:          config.MinIdleConns,
+			PoolTimeout:           config.PoolTimeout,
+			MaxRetries:            config.MaxRetries,
+			MinRetryBackoff:       config.MinRetryBackoff,
+			MaxRetryBackoff:       config.MaxRetryBackoff,
+			ContextTimeoutEnabled: true,
+		}), nil
+	case RedisCluster:
+		if config.Database != 0 {
+			return nil, errors.New("cluster mode only has database 0")
+		}
+		return redis.NewClusterClient(&redis.ClusterOptions{
+			Addrs:                 config.Addresses,
+			Username:              config.Username,
+			Password:              config.Password,
+			TLSConfig:             tlsConfig,
+			DialTimeout:           config.DialTimeout,
+			ReadTimeout:           config.ReadTimeout,
+			WriteTimeout:          config.WriteTimeout,
+			PoolSize:              config.PoolSize,
+			MinIdleConns:          config.MinIdleConns,
+			PoolTimeout:           config.PoolTimeout,
+			MaxRetries:            config.MaxRetries,
+			MinRetryBackoff:       config.MinRetryBackoff,
+			MaxRetryBackoff:       config.MaxRetryBackoff,
+			ContextTimeoutEnabled: true,
+		}), nil
+	default:
+		return nil, errors.Errorf("unknown Redis mode %q", config.Mode)
+	}
+}
+
+func (c *RedisTLSConfig) build() (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		ServerName:         c.ServerName,
+		InsecureSkipVerify: c.InsecureSkipVerify,
+	}
+
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "reading Redis CA file")
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, errors.Errorf("no certificates found in %s", c.CAFile)
+		}
+		tlsConfig.RootCAs = pool
 	}
 
-	password := os.Getenv("REDIS_PASSWORD")
-	return &RedisConfig{
-		Address:  address,
-		Password: password,
-		Database: 0, // Default database
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, errors.Wrap(err, "loading Redis client certificate")
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
 	}
+	return tlsConfig, nil
 }
 
 // InitRedis initializes Redis connection
 func InitRedis(config *RedisConfig) error {
-	RedisClient = redis.NewClient(&redis.Options{
-		Addr:         config.Address,
-		Password:     config.Password,
-		DB:           config.Database,
-		DialTimeout:  10 * time.Second,
-		ReadTimeout:  30 * time.Second,
-		WriteTimeout: 30 * time.Second,
-		PoolSize:     10,
-		PoolTimeout:  30 * time.Second,
-	})
+	client, err := NewRedisClient(config)
+	if err != nil {
+		slog.Error("invalid Redis configuration", "error", err)
+		return err
+	}
+	RedisClient = client
 
-	// Test the connection
-	_, err := RedisClient.Ping(ctx).Result()
+	// Test the connection, bounded like a dial so startup does not hang
+	timeout := config.DialTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second // go-redis' default
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	_, err = RedisClient.Ping(ctx).Result()
 	if err != nil {
-		log.Printf("failed to connect to Redis: %v", err)
+		slog.Error("failed to connect to Redis", "error", err)
 		return err
 	}
 
-	log.Println("Successfully connected to Redis")
+	slog.Info("connected to Redis", "mode", config.Mode)
 	return nil
 }
 
-// InitRedisWithDefaults initializes Redis with default configuration
-func InitRedisWithDefaults() error {
-	config := GetDefaultRedisConfig()
-	return InitRedis(config)
-}
-
 // GetRedisClient returns the Redis client instance
-func GetRedisClient() *redis.Client {
+func GetRedisClient() redis.UniversalClient {
 	if RedisClient == nil {
-		log.Println("Redis client is not initialized. Call InitRedis first.")
+		slog.Error("Redis client is not initialized, call InitRedis first")
 	}
 	return RedisClient
 }
@@ -79,15 +212,51 @@ func CloseRedis() error {
 	if RedisClient != nil {
 		err := RedisClient.Close()
 		if err != nil {
-			log.Printf("failed to close Redis connection: %v", err)
+			slog.Error("failed to close Redis connection", "error", err)
 			return err
 		}
-		log.Println("Redis connection closed")
+		slog.Info("Redis connection closed")
 	}
 	return nil
 }
 
-// GetContext returns the context for Redis operations
-func GetContext() context.Context {
-	return ctx
+// ScanKeys returns every key matching pattern. KEYS and SCAN only see one
+// node of a cluster, so in cluster mode each master is scanned.
+func ScanKeys(ctx context.Context, client redis.UniversalClient, pattern string) ([]string, error) {
+	cluster, ok := client.(*redis.ClusterClient)
+	if !ok {
+		return scanNode(ctx, client, pattern)
+	}
+
+	// ForEachMaster runs the masters concurrently
+	var (
+		mu   sync.Mutex
+		keys []string
+	)
+	err := cluster.ForEachMaster(ctx, func(ctx context.Context, node *redis.Client) error {
+		found, err := scanNode(ctx, node, pattern)
+		if err != nil {
+			return err
+		}
+		mu.Lock()
+		keys = append(keys, found...)
+		mu.Unlock()
+		return nil
+	})
+	return keys, err
+}
+
+// scanNode walks one node with SCAN, which may return a key twice while the
+// keyspace is rehashed
+func scanNode(ctx context.Context, client redis.Cmdable, pattern string) ([]string, error) {
+	var keys []string
+	seen := make(map[string]bool)
+	iter := client.Scan(ctx, 0, pattern, 100).Iterator()
+	for iter.Next(ctx) {
+		if key := iter.Val(); !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+	return keys, iter.Err()
 }
@@ -0,0 +1,67 @@
+package utils
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestNewRedisClientModes(t *testing.T) {
+	tests := []struct {
+		config *RedisConfig
+		want   string
+	}{
+		{&RedisConfig{Mode: RedisStandalone, Addresses: []string{"a:6379"}}, "*redis.Client"},
+		{&RedisConfig{Mode: RedisSentinel, Addresses: []string{"a:26379"}, MasterName: "main"}, "*redis.Client"},
+		{&RedisConfig{Mode: RedisCluster, Addresses: []string{"a:7000", "b:7000"}, TLS: &RedisTLSConfig{ServerName: "redis.internal"}}, "*redis.ClusterClient"},
+	}
+	for _, test := range tests {
+		client, err := NewRedisClient(test.config)
+		if err != nil {
+			t.Fatalf("%s: %v", test.config.Mode, err)
+		}
+		if got := fmt.Sprintf("%T", client); got != test.want {
+			t.Errorf("%s: client is %s, want %s", test.config.Mode, got, test.want)
+		}
+		client.Close()
+	}
+}
+
+func TestNewRedisClientRejects(t *testing.T) {
+	tests := map[string]*RedisConfig{
+		"no address":          {Mode: RedisStandalone},
+		"unknown mode":        {Mode: "ring", Addresses: []string{"a:6379"}},
+		"standalone many":     {Mode: RedisStandalone, Addresses: []string{"a:6379", "b:6379"}},
+		"sentinel no master":  {Mode: RedisSentinel, Addresses: []string{"a:26379"}},
+		"cluster database":    {Mode: RedisCluster, Addresses: []string{"a:7000"}, Database: 2},
+		"missing ca file":     {Mode: RedisStandalone, Addresses: []string{"a:6379"}, TLS: &RedisTLSConfig{CAFile: "/nonexistent/ca.pem"}},
+		"missing client cert": {Mode: RedisStandalone, Addresses: []string{"a:6379"}, TLS: &RedisTLSConfig{CertFile: "/nonexistent/cert.pem"}},
+	}
+	for name, config := range tests {
+		if _, err := NewRedisClient(config); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestScanKeys(t *testing.T) {
+	server := miniredis.RunT(t)
+	client := redis.NewClient(&redis.Options{Addr: server.Addr()})
+	defer client.Close()
+
+	for i := 0; i < 250; i++ {
+		server.Set(fmt.Sprintf("song:%d", i), "{}")
+	}
+	server.Set("user:1", "{}")
+
+	keys, err := ScanKeys(context.Background(), client, "song:*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(keys) != 250 {
+		t.Errorf("got %d keys, want 250", len(keys))
+	}
+}